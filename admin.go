@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/PhiloTFarnsworth/FantasySportsAF/config"
+	"github.com/PhiloTFarnsworth/FantasySportsAF/store"
+	"github.com/PhiloTFarnsworth/FantasySportsAF/store/playerimport"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/term"
+)
+
+//The commands for looking after a site from the command line.  They go through the same repositories the handlers
+//do, so the database is left the way the site would have left it.
+
+var stdin = bufio.NewReader(os.Stdin)
+
+//ask prints a prompt and reads back a line.
+func ask(prompt string) (string, error) {
+	fmt.Print(prompt)
+	line, err := stdin.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+//askSecret is ask without echoing what's typed, when there's a terminal to type it in.  A password piped in is read
+//like any other answer.
+func askSecret(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return ask(prompt)
+	}
+	fmt.Print(prompt)
+	secret, err := term.ReadPassword(fd)
+	fmt.Println()
+	return string(secret), err
+}
+
+//askPassword asks for a password twice, and hashes it the way register does.
+func askPassword() ([]byte, error) {
+	password, err := askSecret("Password: ")
+	if err != nil {
+		return nil, err
+	}
+	if password == "" {
+		return nil, errors.New("password can't be empty")
+	}
+	again, err := askSecret("Password again: ")
+	if err != nil {
+		return nil, err
+	}
+	if password != again {
+		return nil, errors.New("passwords don't match")
+	}
+	return bcrypt.GenerateFromPassword([]byte(password), 10)
+}
+
+//leagueID reads the league ID off the arguments, and checks there's nothing after it.
+func leagueID(args []string) (int64, error) {
+	if len(args) != 1 {
+		return 0, errUsage
+	}
+	return strconv.ParseInt(args[0], 10, 64)
+}
+
+func importPlayers(args []string) error {
+	path := config.Current().PlayerCSV
+	switch len(args) {
+	case 0:
+		if path == "" {
+			return errors.New("no player CSV, pass one or set nflcsv")
+		}
+	case 1:
+		path = args[0]
+	default:
+		return errUsage
+	}
+	added, err := playerimport.ImportFile(store.GetDB(), path)
+	fmt.Println("Added", added, "players")
+	return err
+}
+
+func importStats(args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	written, err := playerimport.ImportStats(store.GetDB(), args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Println("Wrote", written, "stat lines")
+	return nil
+}
+
+//createAdmin makes an account an admin, making the account first if there isn't one by that name.
+func createAdmin(args []string) error {
+	if len(args) != 2 {
+		return errUsage
+	}
+	repos := store.NewSQL(store.GetDB())
+	u, err := repos.Users.ByName(args[0])
+	switch err {
+	case nil:
+		if u.Email != args[1] {
+			return fmt.Errorf("%s already exists with the email %s", u.Name, u.Email)
+		}
+		fmt.Println(u.Name, "already has an account")
+	case store.ErrNotFound:
+		passhash, err := askPassword()
+		if err != nil {
+			return err
+		}
+		if u, err = repos.Users.Create(args[0], args[1], passhash); err != nil {
+			return err
+		}
+	default:
+		return err
+	}
+	if err = repos.Users.SetAdmin(u.ID, true); err != nil {
+		return err
+	}
+	fmt.Println(u.Name, "is an admin")
+	return nil
+}
+
+//leagueExport is everything about a league, for league export.
+type leagueExport struct {
+	League   store.League            `json:"league"`
+	Season   int                     `json:"season"`
+	Teams    []store.Team            `json:"teams"`
+	Invites  []store.User            `json:"invites"`
+	Settings store.FullDraftSettings `json:"settings"`
+	Picks    []store.Pick            `json:"picks"`
+	Keepers  []store.Keeper          `json:"keepers"`
+}
+
+func exportLeague(repos store.Repos, ID int64) (leagueExport, error) {
+	var e leagueExport
+	var err error
+	if e.League, err = repos.Leagues.Get(ID); err != nil {
+		return e, err
+	}
+	e.Season = e.League.Season
+	if e.Teams, err = repos.Teams.List(ID); err != nil {
+		return e, err
+	}
+	if e.Invites, err = repos.Invites.List(ID); err != nil {
+		return e, err
+	}
+	if e.Settings, err = repos.Settings.Draft(ID); err != nil {
+		return e, err
+	}
+	if e.Picks, err = repos.Drafts.Picks(ID); err != nil {
+		return e, err
+	}
+	e.Keepers, err = repos.Drafts.Keepers(ID)
+	return e, err
+}
+
+func leagueCommand(args []string) error {
+	if len(args) < 1 {
+		return errUsage
+	}
+	ID, err := leagueID(args[1:])
+	if err != nil {
+		return err
+	}
+	e, err := exportLeague(store.NewSQL(store.GetDB()), ID)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "export":
+		out := json.NewEncoder(os.Stdout)
+		out.SetIndent("", "  ")
+		return out.Encode(e)
+	case "inspect":
+		l := e.League
+		fmt.Printf("%v: %s (%s, %v season)\n", l.ID, l.Name, l.Kind, e.Season)
+		fmt.Printf("Commissioner %s, state %s, %v of %v teams\n", l.Commissioner.Name, l.State, len(e.Teams), l.MaxOwner)
+		fmt.Println("Teams:")
+		for _, t := range e.Teams {
+			fmt.Printf("  %v: %s, managed by %s, picks %v\n", t.ID, t.Name, t.Manager.Name, t.Slot)
+		}
+		fmt.Println("Invites:")
+		for _, u := range e.Invites {
+			if u.ID == 0 {
+				fmt.Printf("  %s (not signed up)\n", u.Email)
+			} else {
+				fmt.Printf("  %s <%s>\n", u.Name, u.Email)
+			}
+		}
+		d := e.Settings.D
+		fmt.Printf("Draft: %s %s, %v rounds, %v on the clock, starts %s\n", d.Pace, d.DraftOrder, d.Rounds, d.DraftClock,
+			d.Time.Format("2006-01-02 15:04"))
+		fmt.Printf("  %v picks made, %v keepers\n", len(e.Picks), len(e.Keepers))
+		return nil
+	}
+	return errUsage
+}
+
+func draftCommand(args []string) error {
+	if len(args) < 1 || args[0] != "reset" {
+		return errUsage
+	}
+	ID, err := leagueID(args[1:])
+	if err != nil {
+		return err
+	}
+	repos := store.NewSQL(store.GetDB())
+	l, err := repos.Leagues.Get(ID)
+	if err != nil {
+		return err
+	}
+	picks, err := repos.Drafts.Picks(ID)
+	if err != nil {
+		return err
+	}
+	//There's no getting the picks back, so make sure.
+	answer, err := ask(fmt.Sprintf("Reset the draft for %s, throwing out %v picks? [y/N] ", l.Name, len(picks)))
+	if err != nil {
+		return err
+	}
+	if answer != "y" && answer != "yes" {
+		fmt.Println("Left it alone")
+		return nil
+	}
+	if err = repos.Drafts.Reset(ID); err != nil {
+		return err
+	}
+	fmt.Println(l.Name, "is back to PREDRAFT.  Restart any server with its draft room open.")
+	return nil
+}
+
+func userCommand(args []string) error {
+	if len(args) != 2 || args[0] != "reset-password" {
+		return errUsage
+	}
+	repos := store.NewSQL(store.GetDB())
+	u, err := repos.Users.ByName(args[1])
+	if err != nil {
+		return err
+	}
+	passhash, err := askPassword()
+	if err != nil {
+		return err
+	}
+	if err = repos.Users.SetPassword(u.ID, passhash); err != nil {
+		return err
+	}
+	fmt.Println("Set a new password for", u.Name)
+	return nil
+}
@@ -0,0 +1,296 @@
+//Package config gathers everything the server can be set up with in one place.  Settings start from defaults that
+//suit a development machine, then a JSON file (see config.example.json) and then the environment get a say, in that
+//order.  The environment variables are the ones we've always used, so an existing setup keeps working without a file.
+//In production mode, Validate turns away the development defaults that aren't safe to run on, like the cookie
+//secret everyone can read in this repository.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+)
+
+//The development secrets.  They're only here so a fresh checkout runs, and production refuses them.
+const devSecret = "secret"
+
+type Config struct {
+	//Production turns on the checks in Validate, release mode in gin and secure cookies.
+	Production bool `json:"production"`
+	//Listen is the address the server listens on.
+	Listen string `json:"listen"`
+	//Root is the FantasyDraftGo directory, and Static the assets served from /static, by default Root's static folder.
+	Root   string `json:"root"`
+	Static string `json:"static"`
+	//SiteURL is where the site can be reached, for links in emails.
+	SiteURL string `json:"siteURL"`
+	//PlayerCSV is the player list the import reads.
+	PlayerCSV string   `json:"playerCSV"`
+	DB        DB       `json:"db"`
+	TLS       TLS      `json:"tls"`
+	Secrets   Secrets  `json:"secrets"`
+	Mail      Mail     `json:"mail"`
+	Broker    Broker   `json:"broker"`
+	Features  Features `json:"features"`
+}
+
+//DB says which database to use.  Driver is mysql, postgres or sqlite, and URL is in whatever form that driver takes.
+//Without a URL, MySQL is found at Addr as User with Password, and the others by the database's name.
+type DB struct {
+	Driver   string `json:"driver"`
+	URL      string `json:"url"`
+	Addr     string `json:"addr"`
+	User     string `json:"user"`
+	Password string `json:"password"`
+}
+
+//TLS serves HTTPS with Cert and Key.  Behind a proxy that takes care of HTTPS, set Proxied instead.
+type TLS struct {
+	Cert    string `json:"cert"`
+	Key     string `json:"key"`
+	Proxied bool   `json:"proxied"`
+}
+
+//Secrets sign session cookies, CSRF tokens and invite links.  Without an invite secret, one gets made up each time
+//the server starts.
+type Secrets struct {
+	Cookie string `json:"cookie"`
+	CSRF   string `json:"csrf"`
+	Invite string `json:"invite"`
+}
+
+//Mail is how email goes out: smtp, file or log.  See mail.New.
+type Mail struct {
+	Sender   string `json:"sender"`
+	SMTPAddr string `json:"smtpAddr"`
+	SMTPUser string `json:"smtpUser"`
+	SMTPPass string `json:"smtpPass"`
+	From     string `json:"from"`
+	File     string `json:"file"`
+}
+
+//Broker passes draft room messages between instances: memory or postgres.  See broker.New.
+type Broker struct {
+	Kind string `json:"kind"`
+	URL  string `json:"url"`
+}
+
+//Features can be turned off, taking their routes with them.
+type Features struct {
+	MockDrafts   bool `json:"mockDrafts"`
+	InviteLinks  bool `json:"inviteLinks"`
+	Supplemental bool `json:"supplemental"`
+}
+
+//flags names the feature flags for FEATURES.
+func (f *Features) flags() map[string]*bool {
+	return map[string]*bool{
+		"mockdrafts":   &f.MockDrafts,
+		"invitelinks":  &f.InviteLinks,
+		"supplemental": &f.Supplemental,
+	}
+}
+
+//Default is what we run on with no file and nothing in the environment.
+func Default() *Config {
+	return &Config{
+		Listen: ":8000",
+		DB:     DB{Driver: "mysql", Addr: "127.0.0.1:3306"},
+		Secrets: Secrets{
+			Cookie: devSecret,
+			CSRF:   devSecret,
+		},
+		Mail:     Mail{Sender: "log"},
+		Broker:   Broker{Kind: "memory"},
+		Features: Features{MockDrafts: true, InviteLinks: true, Supplemental: true},
+	}
+}
+
+//Load reads file over the defaults, when there is one, and then the environment over that, and checks the result.
+func Load(file string) (*Config, error) {
+	c := Default()
+	if file != "" {
+		raw, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal(raw, c); err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+	}
+	if err := c.fromEnv(); err != nil {
+		return nil, err
+	}
+	if c.Static == "" {
+		c.Static = c.Root + "static"
+	}
+	return c, c.Validate()
+}
+
+//fromEnv lays whatever's set in the environment over c.
+func (c *Config) fromEnv() error {
+	//Empty counts as unset, as it always has.
+	env := func(name string, dest *string) {
+		if v := os.Getenv(name); v != "" {
+			*dest = v
+		}
+	}
+	var production string
+	env("FSAF_ENV", &production)
+	if production != "" {
+		c.Production = production == "production"
+	}
+	env("LISTEN", &c.Listen)
+	env("FSGOPATH", &c.Root)
+	env("STATIC_PATH", &c.Static)
+	env("SITE_URL", &c.SiteURL)
+	env("nflcsv", &c.PlayerCSV)
+
+	env("DB", &c.DB.Driver)
+	env("DB_URL", &c.DB.URL)
+	env("DB_ADDR", &c.DB.Addr)
+	env("DBUSER", &c.DB.User)
+	env("DBPASS", &c.DB.Password)
+
+	env("TLS_CERT", &c.TLS.Cert)
+	env("TLS_KEY", &c.TLS.Key)
+	var proxied string
+	env("TLS_PROXIED", &proxied)
+	if proxied != "" {
+		c.TLS.Proxied = proxied == "true" || proxied == "1"
+	}
+
+	env("COOKIESECRET", &c.Secrets.Cookie)
+	env("CSRFSECRET", &c.Secrets.CSRF)
+	env("INVITESECRET", &c.Secrets.Invite)
+
+	env("MAIL", &c.Mail.Sender)
+	env("SMTP_ADDR", &c.Mail.SMTPAddr)
+	env("SMTP_USER", &c.Mail.SMTPUser)
+	env("SMTP_PASS", &c.Mail.SMTPPass)
+	env("MAIL_FROM", &c.Mail.From)
+	env("MAIL_FILE", &c.Mail.File)
+
+	env("BROKER", &c.Broker.Kind)
+	env("BROKER_URL", &c.Broker.URL)
+
+	//FEATURES lists flags to turn on, or off with a - in front, like FEATURES=-mockdrafts,supplemental.
+	var features string
+	env("FEATURES", &features)
+	flags := c.Features.flags()
+	for _, f := range strings.Split(features, ",") {
+		f = strings.ToLower(strings.TrimSpace(f))
+		if f == "" {
+			continue
+		}
+		on := !strings.HasPrefix(f, "-")
+		flag, ok := flags[strings.TrimPrefix(f, "-")]
+		if !ok {
+			return errors.New("unknown feature in FEATURES: " + f)
+		}
+		*flag = on
+	}
+	return nil
+}
+
+//Validate checks the settings make sense together, and in production that none of them are unsafe.  Everything
+//wrong is reported at once, so it doesn't take a restart per mistake.
+func (c *Config) Validate() error {
+	var problems []string
+	problem := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if c.Listen == "" {
+		problem("listen address is empty")
+	}
+	switch c.DB.Driver {
+	case "mysql", "postgres", "sqlite":
+	default:
+		problem("unknown database driver %q", c.DB.Driver)
+	}
+	if (c.TLS.Cert == "") != (c.TLS.Key == "") {
+		problem("TLS needs both a cert and a key")
+	}
+	switch c.Mail.Sender {
+	case "smtp":
+		if c.Mail.SMTPAddr == "" || c.Mail.From == "" {
+			problem("SMTP_ADDR and MAIL_FROM are needed to send mail over smtp")
+		}
+	case "file":
+		if c.Mail.File == "" {
+			problem("MAIL_FILE is needed to send mail to a file")
+		}
+	case "log":
+	default:
+		problem("unknown mail sender %q", c.Mail.Sender)
+	}
+	switch c.Broker.Kind {
+	case "postgres":
+		if c.Broker.URL == "" {
+			problem("BROKER_URL is needed for the postgres broker")
+		}
+	case "memory":
+	default:
+		problem("unknown broker %q", c.Broker.Kind)
+	}
+
+	if c.Production {
+		secret := func(name string, s string) {
+			switch {
+			case s == "" || s == devSecret:
+				problem("%s has to be set in production", name)
+			case len(s) < 32:
+				problem("%s should be at least 32 characters", name)
+			}
+		}
+		secret("COOKIESECRET", c.Secrets.Cookie)
+		secret("CSRFSECRET", c.Secrets.CSRF)
+		secret("INVITESECRET", c.Secrets.Invite)
+		if c.TLS.Cert == "" && !c.TLS.Proxied {
+			problem("production needs TLS_CERT and TLS_KEY, or TLS_PROXIED when a proxy handles HTTPS")
+		}
+		if !strings.HasPrefix(c.SiteURL, "https://") {
+			problem("SITE_URL has to be an https address in production")
+		}
+		if c.DB.Driver == "mysql" && c.DB.URL == "" && c.DB.Password == "" {
+			problem("DBPASS is empty")
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.New("bad configuration: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+var (
+	current   *Config
+	currentMu sync.Mutex
+)
+
+//Current is the configuration everything runs on.  Unless main has Set one, it's loaded from the file at
+//FSAF_CONFIG, if any, and the environment the first time it's asked for, and a bad one stops us right there.
+func Current() *Config {
+	currentMu.Lock()
+	defer currentMu.Unlock()
+	if current == nil {
+		c, err := Load(os.Getenv("FSAF_CONFIG"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		current = c
+	}
+	return current
+}
+
+//Set makes c the configuration Current returns.
+func Set(c *Config) {
+	currentMu.Lock()
+	current = c
+	currentMu.Unlock()
+}
@@ -0,0 +1,43 @@
+//Package mail sends the emails the site needs: invites to a league, invites taken back, drafts starting, managers
+//on the clock and trade proposals.  Handlers shouldn't wait on a mail server, so everything goes through a Queue,
+//which hands messages to a Sender in the background and tries again when sending fails.
+package mail
+
+import (
+	"errors"
+
+	"github.com/PhiloTFarnsworth/FantasySportsAF/config"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+//Sender delivers a message, or says why it couldn't.
+type Sender interface {
+	Send(m Message) error
+}
+
+//New picks a sender from the config.  smtp sends through SMTPAddr (host:port), logging in with SMTPUser and
+//SMTPPass when they're set, from From.  file appends everything to File, and log writes mail to the log, which is
+//all a development server needs.
+func New(c config.Mail) (Sender, error) {
+	switch c.Sender {
+	case "smtp":
+		if c.SMTPAddr == "" || c.From == "" {
+			return nil, errors.New("SMTP_ADDR and MAIL_FROM are needed to send mail over smtp")
+		}
+		return NewSMTP(c.SMTPAddr, c.SMTPUser, c.SMTPPass, c.From), nil
+	case "file":
+		if c.File == "" {
+			return nil, errors.New("MAIL_FILE is needed to send mail to a file")
+		}
+		return NewFile(c.File), nil
+	case "", "log":
+		return Log{}, nil
+	default:
+		return nil, errors.New("unknown mail sender: " + c.Sender)
+	}
+}
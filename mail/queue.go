@@ -0,0 +1,145 @@
+package mail
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+//The queue holds mail for a Sender and works through it one message at a time.  A message that fails goes back on
+//the queue after a wait that doubles each time, so it never holds up the mail behind it.  After maxAttempts we log
+//it and move on.
+
+const (
+	queueSize   = 256
+	maxAttempts = 5
+	firstRetry  = 2 * time.Second
+)
+
+var (
+	ErrQueueFull   = errors.New("mail queue is full")
+	ErrQueueClosed = errors.New("mail queue is closed")
+)
+
+//queued is a message along with how many times it has failed, and how long to wait before its next try.
+type queued struct {
+	Message
+	attempts int
+	wait     time.Duration
+}
+
+type Queue struct {
+	sender Sender
+	queue  chan queued
+	wg     sync.WaitGroup
+	mu     sync.Mutex
+	closed bool
+	//failed messages waiting out their retry
+	waiting map[*time.Timer]queued
+	//time to wait before the first retry
+	retry time.Duration
+}
+
+func NewQueue(s Sender) *Queue {
+	q := &Queue{
+		sender:  s,
+		queue:   make(chan queued, queueSize),
+		waiting: make(map[*time.Timer]queued),
+		retry:   firstRetry,
+	}
+	q.wg.Add(1)
+	go q.run()
+	return q
+}
+
+//Send queues a message and returns straight away.  It never waits on the mail server, so when the queue is backed
+//up the message is turned away with ErrQueueFull instead.
+func (q *Queue) Send(m Message) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return ErrQueueClosed
+	}
+	select {
+	case q.queue <- queued{Message: m, wait: q.retry}:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+//Close stops taking mail and waits for what's queued to go out.  Messages still failing get one last try, without
+//waiting around to retry them.
+func (q *Queue) Close() error {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return nil
+	}
+	q.closed = true
+	var retries []queued
+	for t, m := range q.waiting {
+		t.Stop()
+		retries = append(retries, m)
+	}
+	q.waiting = nil
+	close(q.queue)
+	q.mu.Unlock()
+	q.wg.Wait()
+	for _, m := range retries {
+		q.lastTry(m)
+	}
+	return nil
+}
+
+func (q *Queue) run() {
+	defer q.wg.Done()
+	for m := range q.queue {
+		q.deliver(m)
+	}
+}
+
+//deliver sends a message once.  If that fails, it goes back on the queue to try again later.
+func (q *Queue) deliver(m queued) {
+	err := q.sender.Send(m.Message)
+	if err == nil {
+		return
+	}
+	m.attempts++
+	if m.attempts == maxAttempts {
+		log.Printf("mail: giving up on %q to %v: %v", m.Subject, m.To, err)
+		return
+	}
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		q.lastTry(m)
+		return
+	}
+	//We hold the lock until t is set, and the timer can't requeue without it.
+	wait := m.wait
+	m.wait *= 2
+	var t *time.Timer
+	t = time.AfterFunc(wait, func() {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		if _, ok := q.waiting[t]; !ok {
+			return
+		}
+		delete(q.waiting, t)
+		select {
+		case q.queue <- m:
+		default:
+			log.Printf("mail: giving up on %q to %v: %v", m.Subject, m.To, ErrQueueFull)
+		}
+	})
+	q.waiting[t] = m
+	q.mu.Unlock()
+}
+
+func (q *Queue) lastTry(m queued) {
+	if err := q.sender.Send(m.Message); err != nil {
+		log.Printf("mail: giving up on %q to %v: %v", m.Subject, m.To, err)
+	}
+}
@@ -0,0 +1,44 @@
+package mail
+
+import (
+	"log"
+	"os"
+	"sync"
+)
+
+//Log writes mail to the server log instead of sending it.
+type Log struct{}
+
+func (Log) Send(m Message) error {
+	log.Printf("mail to %v: %v\n%v\n", m.To, m.Subject, m.Body)
+	return nil
+}
+
+//File appends mail to a file, one message after another, for tests and for looking over what the site would have
+//sent.
+type File struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewFile(path string) *File {
+	return &File{path: path}
+}
+
+func (f *File) Send(m Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = out.Write(format("fantasydraft", m)); err != nil {
+		out.Close()
+		return err
+	}
+	if _, err = out.Write([]byte("\r\n")); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
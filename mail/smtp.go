@@ -0,0 +1,49 @@
+package mail
+
+import (
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+//SMTP sends mail through a mail server.
+type SMTP struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+//NewSMTP sends through the server at addr as from.  Without a user we don't log in, which suits a local relay.
+func NewSMTP(addr string, user string, pass string, from string) *SMTP {
+	s := &SMTP{addr: addr, from: from}
+	if user != "" {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			host = addr
+		}
+		s.auth = smtp.PlainAuth("", user, pass, host)
+	}
+	return s
+}
+
+func (s *SMTP) Send(m Message) error {
+	return smtp.SendMail(s.addr, s.auth, s.from, []string{m.To}, format(s.from, m))
+}
+
+//format writes out a message with its headers.  Subjects come from our templates, but we strip line breaks anyway
+//so nothing can sneak in an extra header.
+func format(from string, m Message) []byte {
+	clean := strings.NewReplacer("\r", "", "\n", " ")
+	var b strings.Builder
+	b.WriteString("From: " + clean.Replace(from) + "\r\n")
+	b.WriteString("To: " + clean.Replace(m.To) + "\r\n")
+	b.WriteString("Subject: " + clean.Replace(m.Subject) + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(m.Body, "\r\n", "\n"), "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return []byte(b.String())
+}
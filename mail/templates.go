@@ -0,0 +1,98 @@
+package mail
+
+import (
+	"embed"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+//Each template defines a "subject" and a "body", and takes the data type of the same name.  Templates can link back
+//to the site with {{site}}.
+
+const (
+	Invite        = "invite"
+	Revoke        = "revoke"
+	DraftStarting = "draftstarting"
+	OnTheClock    = "ontheclock"
+	Trade         = "trade"
+)
+
+//Site is where emails send people.  Set it to the site's address before sending anything.
+var Site = "FantasyDraft"
+
+type InviteData struct {
+	League       string
+	Commissioner string
+	//whether the invitee already has an account
+	Registered bool
+}
+
+type RevokeData struct {
+	League string
+}
+
+type DraftStartingData struct {
+	League string
+	//the manager's team and where it picks, if they have one
+	Team string
+	Slot int64
+}
+
+type OnTheClockData struct {
+	League   string
+	Pick     int64
+	Deadline time.Time
+}
+
+type TradeData struct {
+	League    string
+	From      string
+	To        string
+	Offered   []string
+	Requested []string
+}
+
+//go:embed templates/*.tmpl
+var templateFiles embed.FS
+
+var templates = map[string]*template.Template{}
+
+func init() {
+	funcs := template.FuncMap{"ordinal": ordinal, "site": func() string { return Site }}
+	for _, name := range []string{Invite, Revoke, DraftStarting, OnTheClock, Trade} {
+		templates[name] = template.Must(template.New(name).Funcs(funcs).ParseFS(templateFiles, "templates/"+name+".tmpl"))
+	}
+}
+
+//Render fills in a template for someone.
+func Render(name string, to string, data interface{}) (Message, error) {
+	t, ok := templates[name]
+	if !ok {
+		return Message{}, fmt.Errorf("no mail template %q", name)
+	}
+	var subject, body strings.Builder
+	if err := t.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, err
+	}
+	if err := t.ExecuteTemplate(&body, "body", data); err != nil {
+		return Message{}, err
+	}
+	return Message{To: to, Subject: strings.TrimSpace(subject.String()), Body: strings.TrimSpace(body.String()) + "\n"}, nil
+}
+
+//ordinal turns a draft slot into 1st, 2nd and so on.
+func ordinal(n int64) string {
+	suffix := "th"
+	switch {
+	case n%100 >= 11 && n%100 <= 13:
+	case n%10 == 1:
+		suffix = "st"
+	case n%10 == 2:
+		suffix = "nd"
+	case n%10 == 3:
+		suffix = "rd"
+	}
+	return fmt.Sprintf("%d%s", n, suffix)
+}
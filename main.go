@@ -1,17 +1,72 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"log"
+	"os"
+	"strconv"
 
+	"github.com/PhiloTFarnsworth/FantasySportsAF/config"
 	"github.com/PhiloTFarnsworth/FantasySportsAF/server"
 	"github.com/PhiloTFarnsworth/FantasySportsAF/store"
 )
 
 var testDB = flag.Bool("test", false, "Set database to testing, possibly do other testing related stuff.")
+var configFile = flag.String("config", os.Getenv("FSAF_CONFIG"), "JSON config file (see config.example.json).  The environment overrides it.")
+var normalize = flag.Bool("normalize", false, "Fold the old per league and per user tables into the shared ones, then exit.")
+
+const usage = `usage: main [-test] [-config file] [-normalize] [command]
+
+With no command, main serves the site.  The commands are:
+  serve                          serve the site
+  migrate [up [version] | down [steps] | status | force version]
+                                 bring the database up to date, roll back, or see where it stands
+  import-players [csv]           add the players in a CSV laid out like nfl_2020.csv, by default the nflcsv one
+  import-stats csv               add or correct player_stats from a CSV with a header (see playerimport.ImportStats)
+  create-admin name email        make a new admin account, or make an existing account an admin
+  league inspect ID              show a league, its teams, invites and draft
+  league export ID               write out a league as JSON
+  draft reset ID                 take a league back to before its draft started
+  user reset-password name       set a new password for an account
+
+Passwords are read from standard input.`
+
+//errUsage sends a command back to the usage above.
+var errUsage = errors.New("bad arguments")
+
+//commands are what main can do besides serve.  They all run on the database from the configuration.
+var commands = map[string]func(args []string) error{
+	"serve":          serve,
+	"migrate":        migrate,
+	"import-players": importPlayers,
+	"import-stats":   importStats,
+	"create-admin":   createAdmin,
+	"league":         leagueCommand,
+	"draft":          draftCommand,
+	"user":           userCommand,
+}
 
 func main() {
+	flag.Usage = func() { fmt.Fprintln(flag.CommandLine.Output(), usage) }
 	flag.Parse()
+	command := flag.Arg(0)
+	if command == "" {
+		command = "serve"
+	}
+	run, ok := commands[command]
+	if !ok {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	config.Set(cfg)
+
 	if testing := *testDB; testing {
 		//Setup for ServerSide Tests
 		store.ConnectDB("testfsgo")
@@ -20,5 +75,89 @@ func main() {
 		store.ConnectDB("fsgo")
 	}
 
+	if *normalize {
+		if err := store.FoldLeagueTables(store.GetDB()); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if err = run(flag.Args()[1:]); err == errUsage {
+		flag.Usage()
+		os.Exit(2)
+	} else if err != nil {
+		log.Fatal(err)
+	}
+}
+
+//serve runs the site.
+func serve(args []string) error {
+	if len(args) > 0 {
+		return errUsage
+	}
+	//We don't migrate on our own, but we shouldn't let a stale schema go unnoticed either.
+	if pending, err := store.Pending(store.GetDB()); err != nil {
+		log.Println("Couldn't check migrations:", err)
+	} else if pending > 0 {
+		log.Println(pending, "migrations pending, run: main migrate")
+	}
+
 	server.Init()
+	return nil
+}
+
+//migrate runs the migrate subcommand.  With no arguments it brings the database up to date.
+func migrate(args []string) error {
+	db := store.GetDB()
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+	number := func(fallback int) (int, error) {
+		if len(args) < 2 {
+			if fallback < 0 {
+				return 0, errUsage
+			}
+			return fallback, nil
+		}
+		return strconv.Atoi(args[1])
+	}
+
+	switch command {
+	case "up":
+		target, err := number(0)
+		if err != nil {
+			return err
+		}
+		return store.Migrate(db, target)
+	case "down":
+		steps, err := number(1)
+		if err != nil {
+			return err
+		}
+		return store.Rollback(db, steps)
+	case "force":
+		version, err := number(-1)
+		if err != nil {
+			return err
+		}
+		return store.ForceMigration(db, version)
+	case "status":
+		states, err := store.MigrationStatus(db)
+		if err != nil {
+			return err
+		}
+		for _, s := range states {
+			switch {
+			case s.Dirty:
+				fmt.Println(s.Migration, "dirty")
+			case s.Applied.IsZero():
+				fmt.Println(s.Migration, "pending")
+			default:
+				fmt.Println(s.Migration, "applied", s.Applied.Format("2006-01-02 15:04:05"))
+			}
+		}
+		return nil
+	}
+	return errUsage
 }
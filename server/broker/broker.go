@@ -0,0 +1,54 @@
+//Package broker passes draft room messages between server instances.  Each instance's hub only knows about the
+//connections it holds, so whatever it sends to a room also goes to the broker, and every other instance delivers
+//it to the connections they hold in that room.
+package broker
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+
+	"github.com/PhiloTFarnsworth/FantasySportsAF/config"
+)
+
+//Message is something sent to a draft room.  Users limits it to those users' connections, for things only one
+//manager needs to see, and Except leaves out a user, like the one whose arrival the message is announcing.
+type Message struct {
+	Room   string
+	Users  []int64
+	Except int64
+	Data   []byte
+}
+
+//Broker publishes messages to the other instances and hands over the messages they publish.  Instances don't get
+//their own messages back, since the hub has already delivered them locally.
+type Broker interface {
+	Publish(m Message) error
+	Messages() <-chan Message
+	Close() error
+}
+
+//New picks a broker from the config.  postgres uses LISTEN/NOTIFY on the database at URL, and memory gets an
+//in-memory broker, which is all a single instance needs.
+func New(c config.Broker) (Broker, error) {
+	switch c.Kind {
+	case "postgres":
+		if c.URL == "" {
+			return nil, errors.New("BROKER_URL is needed for the postgres broker")
+		}
+		return NewPostgres(c.URL)
+	case "", "memory":
+		return NewBus().Client(), nil
+	default:
+		return nil, errors.New("unknown broker: " + c.Kind)
+	}
+}
+
+//instanceID tells instances apart, so they can skip their own messages.
+func instanceID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,107 @@
+package broker
+
+import "sync"
+
+//Bus is an in-memory broker.  Every client on the bus stands in for a server instance, so one client is a single
+//server, and a few of them sharing a bus is how we test hubs talking to each other without a database.
+type Bus struct {
+	mu      sync.Mutex
+	clients []*memoryClient
+}
+
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+//Client adds an instance to the bus.
+func (b *Bus) Client() Broker {
+	c := &memoryClient{
+		bus:  b,
+		wake: make(chan struct{}, 1),
+		out:  make(chan Message),
+		done: make(chan struct{}),
+	}
+	b.mu.Lock()
+	b.clients = append(b.clients, c)
+	b.mu.Unlock()
+	go c.run()
+	return c
+}
+
+//memoryClient queues up messages from the rest of the bus.  The queue doesn't have a limit, since a hub that's
+//busy publishing can't also be reading, and we'd rather not drop anything.
+type memoryClient struct {
+	bus   *Bus
+	mu    sync.Mutex
+	queue []Message
+	wake  chan struct{}
+	out   chan Message
+	done  chan struct{}
+	once  sync.Once
+}
+
+func (c *memoryClient) Publish(m Message) error {
+	c.bus.mu.Lock()
+	defer c.bus.mu.Unlock()
+	for _, other := range c.bus.clients {
+		if other != c {
+			other.push(m)
+		}
+	}
+	return nil
+}
+
+func (c *memoryClient) Messages() <-chan Message {
+	return c.out
+}
+
+func (c *memoryClient) Close() error {
+	c.once.Do(func() {
+		c.bus.mu.Lock()
+		for i, other := range c.bus.clients {
+			if other == c {
+				c.bus.clients = append(c.bus.clients[:i], c.bus.clients[i+1:]...)
+				break
+			}
+		}
+		c.bus.mu.Unlock()
+		close(c.done)
+	})
+	return nil
+}
+
+func (c *memoryClient) push(m Message) {
+	c.mu.Lock()
+	c.queue = append(c.queue, m)
+	c.mu.Unlock()
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+//run feeds the queue out to the hub, in order.
+func (c *memoryClient) run() {
+	defer close(c.out)
+	for {
+		c.mu.Lock()
+		if len(c.queue) == 0 {
+			c.mu.Unlock()
+			select {
+			case <-c.wake:
+				continue
+			case <-c.done:
+				return
+			}
+		}
+		m := c.queue[0]
+		c.queue = c.queue[1:]
+		c.mu.Unlock()
+
+		select {
+		case c.out <- m:
+		case <-c.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package broker
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+//The postgres broker uses LISTEN/NOTIFY on a single channel.  Postgres caps a notification at 8000 bytes, which is
+//plenty for picks and chat, but we check rather than have the database turn us down.
+
+const (
+	notifyChannel = "fsaf_draft_rooms"
+	maxNotify     = 7999
+)
+
+var errTooBig = errors.New("message too big for the postgres broker")
+
+//envelope is a message on the wire, tagged with the instance that sent it.
+type envelope struct {
+	Instance string
+	Message
+}
+
+type postgres struct {
+	db       *sql.DB
+	listener *pq.Listener
+	instance string
+	out      chan Message
+	done     chan struct{}
+	once     sync.Once
+}
+
+//NewPostgres connects to the database at url, which only needs to be reachable by every instance, not the database
+//the leagues live in.
+func NewPostgres(url string) (Broker, error) {
+	instance, err := instanceID()
+	if err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		return nil, err
+	}
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	listener := pq.NewListener(url, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Println("broker:", err)
+		}
+	})
+	if err = listener.Listen(notifyChannel); err != nil {
+		listener.Close()
+		db.Close()
+		return nil, err
+	}
+
+	p := &postgres{
+		db:       db,
+		listener: listener,
+		instance: instance,
+		out:      make(chan Message),
+		done:     make(chan struct{}),
+	}
+	go p.run()
+	return p, nil
+}
+
+func (p *postgres) Publish(m Message) error {
+	b, err := json.Marshal(envelope{p.instance, m})
+	if err != nil {
+		return err
+	}
+	if len(b) > maxNotify {
+		return errTooBig
+	}
+	_, err = p.db.Exec("SELECT pg_notify($1, $2)", notifyChannel, string(b))
+	return err
+}
+
+func (p *postgres) Messages() <-chan Message {
+	return p.out
+}
+
+func (p *postgres) Close() error {
+	var err error
+	p.once.Do(func() {
+		close(p.done)
+		p.listener.Close()
+		err = p.db.Close()
+	})
+	return err
+}
+
+//run passes along notifications from other instances.  A nil notification means the listener lost its connection
+//and got it back, and anything sent in between is gone.  Clients fill in the gap with a sync.
+func (p *postgres) run() {
+	defer close(p.out)
+	for {
+		select {
+		case n, ok := <-p.listener.Notify:
+			if !ok {
+				return
+			}
+			if n == nil {
+				log.Println("broker: reconnected, messages may have been missed")
+				continue
+			}
+			var e envelope
+			if err := json.Unmarshal([]byte(n.Extra), &e); err != nil {
+				log.Println("broker:", err)
+				continue
+			}
+			if e.Instance == p.instance {
+				continue
+			}
+			select {
+			case p.out <- e.Message:
+			case <-p.done:
+				return
+			}
+		case <-p.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/PhiloTFarnsworth/FantasySportsAF/store"
+	"github.com/gin-gonic/gin"
+)
+
+//Draft chat used to go out to whoever was in the room and then vanish.  Now league rooms keep it in draft_chat,
+//new connections get the recent history, and the commissioner can delete messages and mute managers.  Mock draft
+//chat still vanishes, since the mock does too.
+
+//chatHistory is how many messages a new connection gets to catch up on.
+const chatHistory = 50
+
+var errMuted = errors.New("you've been muted for this draft")
+
+type chatMessage struct {
+	ID      int64
+	User    int64
+	Name    string `json:",omitempty"`
+	Payload string
+	Sent    time.Time
+}
+
+//saveChat stores a chat message for a league room, unless the sender has been muted.
+func saveChat(db *sql.DB, room string, user int64, text string) (chatMessage, error) {
+	c := chatMessage{User: user, Payload: text}
+	league, err := roomLeague(room)
+	if err != nil {
+		return c, err
+	}
+	var muted int
+	row := db.QueryRow("SELECT COUNT(*) FROM draft_mutes WHERE league=? AND room=? AND user=?", league, room, user)
+	if err = row.Scan(&muted); err != nil {
+		return c, err
+	}
+	if muted > 0 {
+		return c, errMuted
+	}
+
+	c.ID, err = store.InsertID(db, "INSERT INTO draft_chat (league, room, user, message) VALUES (?,?,?,?)", league, room, user, text)
+	if err != nil {
+		return c, err
+	}
+	row = db.QueryRow("SELECT sent FROM draft_chat WHERE ID=?", c.ID)
+	err = row.Scan(&c.Sent)
+	return c, err
+}
+
+//chat sends a message to its room, saving it first if the room belongs to a league.
+func (h *hub) chat(m message) {
+	c := chatMessage{User: m.User, Payload: string(m.data), Sent: time.Now()}
+	if !strings.HasPrefix(m.room, mockRoomPrefix) {
+		var err error
+		if c, err = saveChat(h.repos.DB, m.room, m.User, string(m.data)); err != nil {
+			h.sendError(m.room, m.User, err)
+			return
+		}
+	}
+
+	b, err := json.Marshal(chatFrame{"chat", c})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	h.broadcastRoom(m.room, b)
+}
+
+//joinChat sends a new connection the recent chat in its room.
+func (h *hub) joinChat(room string, conn *connection) {
+	if strings.HasPrefix(room, mockRoomPrefix) {
+		return
+	}
+	rows, err := h.repos.DB.Query("SELECT ID, user, message, sent FROM draft_chat WHERE room=? AND NOT deleted ORDER BY ID DESC LIMIT ?", room, chatHistory)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer rows.Close()
+	messages := make([]chatMessage, 0)
+	for rows.Next() {
+		var c chatMessage
+		if err = rows.Scan(&c.ID, &c.User, &c.Payload, &c.Sent); err != nil {
+			fmt.Println(err)
+			return
+		}
+		messages = append(messages, c)
+	}
+	//Newest came back first, but the chat reads oldest first.
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+
+	b, err := json.Marshal(historyFrame{"history", messages})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	conn.send <- b
+}
+
+//deleteChat hides a message from the league's chat, and tells the room it went out to.
+func (h *hub) deleteChat(league int64, ID int64) error {
+	db := h.repos.DB
+	var room string
+	row := db.QueryRow("SELECT room FROM draft_chat WHERE ID=? AND league=?", ID, league)
+	if err := row.Scan(&room); err != nil {
+		return errors.New("no such message")
+	}
+	if _, err := db.Exec("UPDATE draft_chat SET deleted=TRUE WHERE ID=?", ID); err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(chatDeletedFrame{"chatDeleted", ID})
+	if err != nil {
+		return err
+	}
+	h.broadcastRoom(room, b)
+	return nil
+}
+
+//muteUser stops a manager from chatting for the rest of the draft in a room, or lets them back in.  Other drafts in
+//the league aren't affected.
+func (h *hub) muteUser(room string, league int64, user int64, muted bool) error {
+	db := h.repos.DB
+	var err error
+	if muted {
+		_, err = db.Exec(store.InsertIgnore("INTO draft_mutes (league, room, user) VALUES (?,?,?)"), league, room, user)
+	} else {
+		_, err = db.Exec("DELETE FROM draft_mutes WHERE league=? AND room=? AND user=?", league, room, user)
+	}
+	if err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(muteFrame{"mute", user, muted})
+	if err != nil {
+		return err
+	}
+	h.broadcastRoom(room, b)
+	return nil
+}
+
+//sendError lets a user know something they asked the room to do didn't happen.
+func (h *hub) sendError(room string, user int64, err error) {
+	b, err := encodeError(&errorFrame{Code: errorCode(err), Message: err.Error()})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	h.sendToUsers(room, []int64{user}, b)
+}
+
+//chatLog exports a league's draft chat, oldest first, for anyone in the league.  Deleted messages stay deleted.
+//Pass format=text for a plain text log instead of JSON.
+func chatLog(c *gin.Context, repos store.Repos) {
+	db := repos.DB
+	league := access(c).League
+
+	rows, err := db.Query("SELECT c.ID, c.user, u.name, c.message, c.sent FROM draft_chat AS c JOIN user AS u ON c.user=u.ID WHERE c.league=? AND NOT c.deleted ORDER BY c.ID", league)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	defer rows.Close()
+	messages := make([]chatMessage, 0)
+	for rows.Next() {
+		var m chatMessage
+		if err = rows.Scan(&m.ID, &m.User, &m.Name, &m.Payload, &m.Sent); err != nil {
+			c.JSON(http.StatusBadRequest, err.Error())
+			return
+		}
+		messages = append(messages, m)
+	}
+
+	if c.Query("format") == "text" {
+		var log strings.Builder
+		for _, m := range messages {
+			fmt.Fprintf(&log, "[%s] %s: %s\n", m.Sent.Format(time.RFC3339), m.Name, m.Payload)
+		}
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"league_%d_chat.txt\"", league))
+		c.String(http.StatusOK, log.String())
+		return
+	}
+	c.JSON(http.StatusOK, messages)
+}
@@ -0,0 +1,181 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+//The draft clock has been a setting for a while, but it was up to the front end to do anything with it.  Now the
+//hub keeps time: draftClock is how many seconds each team gets (hours in a slow draft, see slow.go), and when it
+//runs out we autopick for them.  A clock of 0 means the draft waits as long as it takes.  Running clocks are saved
+//to draft_clocks, so a restart picks them back up, but pauses only live in memory.
+
+var errDraftPaused = errors.New("draft is paused")
+
+type draftClock struct {
+	league int64
+	//pick the clock is running for, the team making it, and when it runs out
+	pick     int64
+	team     int64
+	manager  int64
+	deadline time.Time
+	timer    *time.Timer
+	//time left on the clock when the commissioner paused the draft
+	remaining time.Duration
+	paused    bool
+	//LIVE or SLOW, from the league's draft settings
+	pace string
+}
+
+//clockTick is a draft clock running out for a pick, whether in a league or a mock draft.
+type clockTick struct {
+	room   string
+	league int64
+	pick   int64
+}
+
+func (c *draftClock) stop() {
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+}
+
+//draftClock returns the clock for a league's draft room, making one if we haven't got one yet.
+func (h *hub) draftClock(room string, league int64) *draftClock {
+	c := h.clocks[room]
+	if c == nil {
+		c = &draftClock{league: league, pick: -1}
+		h.clocks[room] = c
+	}
+	return c
+}
+
+//advanceDraft runs after anything that might change who's on the clock: picks for teams on autodraft, then starts
+//the clock for whoever is left holding the pick.
+func (h *hub) advanceDraft(room string, league int64) {
+	h.runAutodraft(room, league)
+	h.runClock(room, league)
+}
+
+//runClock starts the clock for the next pick in a league's draft and lets the room know the deadline.  Picking up
+//after a pause, the team on the clock gets whatever time they had left rather than a fresh clock.
+func (h *hub) runClock(room string, league int64) {
+	c := h.draftClock(room, league)
+	c.stop()
+	if c.paused {
+		return
+	}
+
+	db := h.repos.DB
+	var state, pace string
+	var seconds int
+	row := db.QueryRow("SELECT l.state, d.draftClock, d.pace FROM league AS l JOIN draft_settings AS d ON l.ID=d.ID WHERE l.ID=?", league)
+	if err := row.Scan(&state, &seconds, &pace); err != nil {
+		fmt.Println(err)
+		return
+	}
+	if state != "DRAFT" || seconds <= 0 {
+		return
+	}
+	board, err := loadBoard(db, league)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	pick, err := board.nextPick()
+	if err != nil {
+		//Draft's over, nothing left to time.
+		delete(h.clocks, room)
+		clearClock(db, league)
+		return
+	}
+
+	//Slow drafts count the clock in hours.
+	wait := time.Duration(seconds) * time.Second
+	if pace == paceSlow {
+		wait = time.Duration(seconds) * time.Hour
+	}
+	if c.remaining > 0 && c.pick == pick {
+		wait = c.remaining
+	}
+	c.remaining = 0
+	c.pace = pace
+	c.pick = pick
+	c.team = board.teamFor(pick).ID
+	c.manager = board.teamFor(pick).Manager
+	c.deadline = time.Now().Add(wait)
+	previous, err := saveClock(db, league, c.pick, c.team, c.deadline)
+	if err != nil {
+		fmt.Println(err)
+	} else if pace == paceSlow && previous != pick {
+		h.notifyOnClock(room, league, pick, c.manager, c.deadline)
+	}
+	b, err := c.message(h.presenceOf(room, c.manager))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	h.broadcastRoom(room, b)
+	c.timer = time.AfterFunc(wait, func() { h.tick <- clockTick{room, league, pick} })
+}
+
+//message tells the room who's on the clock and until when, and whether their manager is around.
+func (c *draftClock) message(state string) ([]byte, error) {
+	return json.Marshal(clockFrame{"clock", c.pick, c.team, c.deadline, c.manager, state})
+}
+
+//joinClock catches a new connection up on the draft clock, or lets them know the draft is paused.
+func (h *hub) joinClock(room string, c *draftClock, conn *connection) {
+	var b []byte
+	var err error
+	if c.paused {
+		b, err = json.Marshal(pauseFrame{"pause", true})
+	} else if c.pick >= 0 && time.Now().Before(c.deadline) {
+		//The clock may be running on another instance, but we still know the deadline.
+		b, err = c.message(h.presenceOf(room, c.manager))
+	} else {
+		return
+	}
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	conn.send <- b
+}
+
+//leagueClock handles a team running out of time: the server picks for them the same way it does for autodraft.
+//Ticks for picks that have already been made are left over from a stopped timer, so we ignore those.
+func (h *hub) leagueClock(t clockTick) {
+	c := h.clocks[t.room]
+	if c == nil || c.paused || c.pick != t.pick {
+		return
+	}
+	c.timer = nil
+
+	db := h.repos.DB
+	board, err := loadBoard(db, t.league)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	pick, err := board.nextPick()
+	if err != nil || pick != t.pick {
+		return
+	}
+	team := board.teamFor(pick)
+	//Nobody expects a slow draft's managers to sit in the room, so only live drafts bench the ones who left.
+	if c.pace != paceSlow {
+		h.benchAbsentTeam(t.room, t.league, team)
+	}
+	player, err := autopick(db, t.league, team.ID)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	if h.makePick(draftPick{player, pick, team.ID, t.league, t.room, 0, 0}) {
+		h.advanceDraft(t.room, t.league)
+	}
+}
@@ -0,0 +1,191 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+//Commissioners get a few controls in the draft room: pausing and resuming the draft, undoing the last few picks,
+//picking for a team and keeping the chat civil.  These come in over the socket like picks do, and the hub checks
+//the sender is the commissioner, or a co-commissioner who can run drafts or moderate chat, before doing anything.
+//The draft controls only apply to a league's main draft, chat moderation works in any of the league's rooms.
+
+type commishCommand struct {
+	kind   string
+	user   int64
+	league int64
+	room   string
+	//picks to undo
+	count int
+	//pick to make on a team's behalf
+	player int64
+	team   int64
+	//chat message to delete, or user to mute
+	message int64
+	target  int64
+}
+
+//maxUndo keeps a fat finger on the undo count from wiping out the whole draft.
+const maxUndo = 50
+
+var errNotCommissioner = errors.New("only the commissioner can do that")
+
+//commissionerCommand checks the sender runs the league, then carries out the command.  Anything that goes wrong
+//is sent back to the commissioner as an "error", since they're the one left wondering why nothing happened.
+func (h *hub) commissionerCommand(cmd commishCommand) {
+	permission := permDraft
+	switch cmd.kind {
+	case "deleteChat", "mute", "unmute":
+		permission = permChat
+	}
+	m, err := h.repos.Roles.Member(cmd.league, cmd.user)
+	if err == nil && !newLeagueAccess(m).allowed(permission) {
+		err = errNotCommissioner
+	}
+
+	if err == nil {
+		switch cmd.kind {
+		case "pause":
+			h.pauseDraft(cmd.room, cmd.league, true)
+		case "resume":
+			h.pauseDraft(cmd.room, cmd.league, false)
+			h.advanceDraft(cmd.room, cmd.league)
+		case "undo":
+			err = h.undoPicks(cmd.room, cmd.league, cmd.count)
+		case "commishPick":
+			err = h.commissionerPick(cmd)
+		case "deleteChat":
+			err = h.deleteChat(cmd.league, cmd.message)
+		case "mute", "unmute":
+			err = h.muteUser(cmd.room, cmd.league, cmd.target, cmd.kind == "mute")
+		}
+	}
+
+	if err != nil {
+		h.sendError(cmd.room, cmd.user, err)
+	}
+}
+
+//pauseDraft stops or restarts the draft.  While paused managers can't pick and nobody gets autopicked, but the
+//commissioner can still undo and pick for teams.  Pausing banks whatever time was left on the clock.
+func (h *hub) pauseDraft(room string, league int64, paused bool) {
+	c := h.draftClock(room, league)
+	if paused && !c.paused && c.timer != nil {
+		c.remaining = time.Until(c.deadline)
+	}
+	c.stop()
+	c.paused = paused
+
+	b, err := json.Marshal(pauseFrame{"pause", paused})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	h.broadcastRoom(room, b)
+}
+
+//undoPicks takes back the last count picks and tells the room with a "rollback".  Keepers aren't picks anybody
+//made in the room, so we leave those be.  Undoing pauses the draft, otherwise a team on autodraft would make the
+//same pick again before the commissioner could do anything about it.
+func (h *hub) undoPicks(room string, league int64, count int) error {
+	if count < 1 || count > maxUndo {
+		return fmt.Errorf("can undo between 1 and %d picks", maxUndo)
+	}
+	db := h.repos.DB
+	undone, err := deletePicks(db, league, count)
+	if err != nil {
+		return err
+	}
+	if len(undone) == 0 {
+		return errors.New("no picks to undo")
+	}
+
+	h.pauseDraft(room, league, true)
+	//The clock starts fresh for whoever is back on it.
+	h.clocks[room].remaining = 0
+
+	b, err := json.Marshal(rollbackFrame{"rollback", undone})
+	if err != nil {
+		return err
+	}
+	h.broadcastRoom(room, b)
+	return nil
+}
+
+//deletePicks removes the latest picks from a league's draft by slot, latest first, and returns them.  That takes in
+//picks a commissioner filled in ahead of the clock, wherever they sit on the board, but never keepers.
+func deletePicks(db *sql.DB, league int64, count int) ([]draftSlot, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	undone := make([]draftSlot, 0)
+	rows, err := tx.Query(`SELECT ID, player, team FROM draft_picks WHERE league=?
+		AND player NOT IN (SELECT player FROM keepers WHERE league=?) ORDER BY ID DESC LIMIT ?`, league, league, count)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var d draftSlot
+		if err = rows.Scan(&d.Slot, &d.Player, &d.Team); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		undone = append(undone, d)
+	}
+	rows.Close()
+
+	for _, d := range undone {
+		if _, err = tx.Exec("DELETE FROM draft_picks WHERE league=? AND ID=?", league, d.Slot); err != nil {
+			return nil, err
+		}
+	}
+	return undone, tx.Commit()
+}
+
+//commissionerPick makes a pick for any team, whether or not they're on the clock.  A team on the clock gets the
+//current pick and the draft moves along, anyone else has their next open pick filled in ahead of time, the same
+//way keepers fill theirs.  It doesn't go through the paused check, so it works while the draft is paused.
+func (h *hub) commissionerPick(cmd commishCommand) error {
+	db := h.repos.DB
+	var state string
+	row := db.QueryRow("SELECT state FROM league WHERE ID=?", cmd.league)
+	if err := row.Scan(&state); err != nil {
+		return err
+	}
+	if state != "DRAFT" {
+		return errLeagueNotDrafting
+	}
+	board, err := loadBoard(db, cmd.league)
+	if err != nil {
+		return err
+	}
+	next, err := board.nextPick()
+	if err != nil {
+		return err
+	}
+	pick, err := board.openPick(cmd.team)
+	if err != nil {
+		return err
+	}
+	if err = board.available(cmd.player); err != nil {
+		return err
+	}
+
+	p := draftPick{cmd.player, pick, cmd.team, cmd.league, cmd.room, 0, 0}
+	_, err = db.Exec("INSERT INTO draft_picks (league, ID, player, team) VALUES (?,?,?,?)", p.league, p.pick, p.player, p.team)
+	if err != nil {
+		return err
+	}
+	h.announcePick(p)
+	h.dequeue(p)
+	if pick == next {
+		h.advanceDraft(cmd.room, cmd.league)
+	}
+	return nil
+}
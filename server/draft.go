@@ -0,0 +1,297 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"sort"
+
+	"github.com/PhiloTFarnsworth/FantasySportsAF/store/scanners"
+	"github.com/gin-gonic/gin"
+)
+
+//The front end has always worked out whose turn it is from the draft settings and team slots.  Once the
+//server started picking for teams it needed to do the same, so draftBoard is that logic on our side.
+
+type boardTeam struct {
+	ID        int64
+	Manager   int64
+	Slot      int64
+	Autodraft bool
+}
+
+type draftBoard struct {
+	league int64
+	order  string
+	rounds int
+	//teams sorted by slot
+	teams []boardTeam
+	//picks already made, by pick number
+	taken map[int64]int64
+}
+
+var (
+	errDraftComplete = errors.New("draft complete")
+	errWrongPick     = errors.New("not the current pick")
+	errNotOnClock    = errors.New("team isn't on the clock")
+	errPlayerTaken   = errors.New("player already drafted")
+	errNoPicksLeft   = errors.New("team has no picks left")
+	//the league's main draft hasn't started, or is over
+	errLeagueNotDrafting = errors.New("league isn't drafting")
+)
+
+//loadBoard reads a league's draft order and the picks made so far.
+func loadBoard(db *sql.DB, league int64) (*draftBoard, error) {
+	b := &draftBoard{league: league, taken: make(map[int64]int64)}
+
+	row := db.QueryRow("SELECT draftOrder, rounds FROM draft_settings WHERE ID=?", league)
+	if err := row.Scan(&b.order, &b.rounds); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query("SELECT ID, manager, slot, autodraft FROM teams WHERE league=? ORDER BY slot", league)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var t boardTeam
+		if err = rows.Scan(&t.ID, &t.Manager, &t.Slot, &t.Autodraft); err != nil {
+			return nil, err
+		}
+		b.teams = append(b.teams, t)
+	}
+
+	rows, err = db.Query("SELECT ID, player FROM draft_picks WHERE league=?", league)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var pick, player int64
+		if err = rows.Scan(&pick, &player); err != nil {
+			return nil, err
+		}
+		b.taken[pick] = player
+	}
+	return b, nil
+}
+
+//teamFor returns the team picking at a given pick number.  Snake drafts flip the order every round, straight
+//drafts don't.  We don't have custom or cursed orders worked out yet, so those get treated as a snake.
+func (b *draftBoard) teamFor(pick int64) boardTeam {
+	n := int64(len(b.teams))
+	round, roundPick := pick/n, pick%n
+	if b.order != "STRAIGHT" && round%2 == 1 {
+		roundPick = n - 1 - roundPick
+	}
+	return b.teams[roundPick]
+}
+
+//nextPick returns the first pick that hasn't been made yet.  This is usually the number of picks made, but keepers
+//and the like can fill in later picks ahead of time.
+func (b *draftBoard) nextPick() (int64, error) {
+	total := int64(b.rounds * len(b.teams))
+	for i := int64(0); i < total; i++ {
+		if _, ok := b.taken[i]; !ok {
+			return i, nil
+		}
+	}
+	return 0, errDraftComplete
+}
+
+//pickFor finds the pick a team makes in a round, counting rounds from 1.
+func (b *draftBoard) pickFor(team int64, round int) (int64, bool) {
+	n := int64(len(b.teams))
+	if round < 1 || round > b.rounds {
+		return 0, false
+	}
+	for pick := int64(round-1) * n; pick < int64(round)*n; pick++ {
+		if b.teamFor(pick).ID == team {
+			return pick, true
+		}
+	}
+	return 0, false
+}
+
+func (b *draftBoard) team(ID int64) (boardTeam, bool) {
+	for _, t := range b.teams {
+		if t.ID == ID {
+			return t, true
+		}
+	}
+	return boardTeam{}, false
+}
+
+//validate checks a pick against the board before anybody records it: it has to be the next pick, made by
+//the team on the clock, for a player nobody has taken.  League, supplemental and mock drafts all go through here.
+func (b *draftBoard) validate(p draftPick) error {
+	next, err := b.nextPick()
+	if err != nil {
+		return err
+	}
+	if p.pick != next {
+		return errWrongPick
+	}
+	if b.teamFor(p.pick).ID != p.team {
+		return errNotOnClock
+	}
+	return b.available(p.player)
+}
+
+//available checks nobody has taken a player yet.
+func (b *draftBoard) available(player int64) error {
+	for _, taken := range b.taken {
+		if taken == player {
+			return errPlayerTaken
+		}
+	}
+	return nil
+}
+
+//openPick finds a team's first pick that hasn't been made yet, which is the current pick when they're on the clock.
+func (b *draftBoard) openPick(team int64) (int64, error) {
+	next, err := b.nextPick()
+	if err != nil {
+		return 0, err
+	}
+	total := int64(b.rounds * len(b.teams))
+	for pick := next; pick < total; pick++ {
+		if _, ok := b.taken[pick]; !ok && b.teamFor(pick).ID == team {
+			return pick, nil
+		}
+	}
+	return 0, errNoPicksLeft
+}
+
+//rankedPlayer is an available player along with what they're worth under a league's scoring.
+type rankedPlayer struct {
+	ID       int64
+	Position string
+	Points   float64
+}
+
+//leagueRankings ranks every player still available in a league by the points they would have scored under
+//that league's settings.
+func leagueRankings(db *sql.DB, league int64) ([]rankedPlayer, error) {
+	var scoring scanners.ScoringSettingsOff
+	row := db.QueryRow("SELECT * FROM scoring_settings_offense WHERE ID=?", league)
+	if err := scoring.ScanRow(row); err != nil {
+		return nil, err
+	}
+
+	var players scanners.PlayerList
+	rows, err := db.Query(`SELECT * FROM player WHERE ID NOT IN (SELECT player FROM draft_picks WHERE league=?)
+		AND ID NOT IN (SELECT player FROM rosters WHERE league=?)`, league, league)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err = players.ScanRow(rows); err != nil {
+			return nil, err
+		}
+	}
+
+	return rankPlayers(players.Players, &scoring), nil
+}
+
+//rankPlayers scores players under a set of scoring settings, best first.
+func rankPlayers(players []scanners.Player, scoring *scanners.ScoringSettingsOff) []rankedPlayer {
+	ranked := make([]rankedPlayer, len(players))
+	for i, p := range players {
+		ranked[i] = rankedPlayer{p.ID, p.Position, p.Score(scoring)}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].Points > ranked[j].Points })
+	return ranked
+}
+
+//bestFit returns the best ranked player that fits a roster.  If nobody fits we take the best player available,
+//since a pick has to be made either way.
+func bestFit(ranked []rankedPlayer, roster []string, settings *scanners.PositionalSettings) int64 {
+	for _, p := range ranked {
+		if settings.CanRoster(append(roster[:len(roster):len(roster)], p.Position)) {
+			return p.ID
+		}
+	}
+	return ranked[0].ID
+}
+
+//autopick chooses a player for a team: the first player in their queue that fits their roster, otherwise the best
+//player available under the league's scoring that fits.
+func autopick(db *sql.DB, league int64, team int64) (int64, error) {
+	var settings scanners.PositionalSettings
+	row := db.QueryRow("SELECT * FROM positional_settings WHERE ID=?", league)
+	if err := settings.ScanRow(row); err != nil {
+		return 0, err
+	}
+
+	var roster []string
+	rows, err := db.Query("SELECT player.position FROM draft_picks AS d JOIN player ON d.player=player.ID WHERE d.league=? AND d.team=?", league, team)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var position string
+		if err = rows.Scan(&position); err != nil {
+			return 0, err
+		}
+		roster = append(roster, position)
+	}
+	ranked, err := leagueRankings(db, league)
+	if err != nil {
+		return 0, err
+	}
+	if len(ranked) == 0 {
+		return 0, errors.New("no players available")
+	}
+	available := make(map[int64]rankedPlayer, len(ranked))
+	for _, p := range ranked {
+		available[p.ID] = p
+	}
+
+	queue, err := teamQueue(db, league, team)
+	if err != nil {
+		return 0, err
+	}
+	for _, ID := range queue {
+		if p, ok := available[ID]; ok && settings.CanRoster(append(roster[:len(roster):len(roster)], p.Position)) {
+			return p.ID, nil
+		}
+	}
+	return bestFit(ranked, roster, &settings), nil
+}
+
+//autodraftToggle is passed to the hub when a manager flips autodraft, or when a draft starts and the first team
+//up might be on autodraft.  Team is zero when we're just asking the hub to check the clock.
+type autodraftToggle struct {
+	league int64
+	team   int64
+	active bool
+}
+
+//setAutodraft lets a manager flag their team to be drafted by the server.  If they happen to be on the clock,
+//the hub picks for them right away.
+func setAutodraft(c *gin.Context, h *hub) {
+	db := h.repos.DB
+	type AutodraftBody struct {
+		League    int64 `json:"league"`
+		Autodraft bool  `json:"autodraft"`
+	}
+	var b AutodraftBody
+	if err := c.BindJSON(&b); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	team := access(c).Team
+	_, err := db.Exec("UPDATE teams SET autodraft=? WHERE league=? AND ID=?", b.Autodraft, b.League, team)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.autodraft <- autodraftToggle{b.League, team, b.Autodraft}
+	c.JSON(http.StatusOK, gin.H{"team": team, "autodraft": b.Autodraft})
+}
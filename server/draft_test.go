@@ -0,0 +1,35 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/PhiloTFarnsworth/FantasySportsAF/store/scanners"
+)
+
+//bestFit isn't exported, so unlike the rest of the suite it's tested from inside the package.
+func TestBestFit(t *testing.T) {
+	settings := scanners.PositionalSettings{QB: 1, RB: 1, WR: 1, TE: 1, Flex: 1, Bench: 1, Def: 1, K: 1}
+	ranked := []rankedPlayer{{1, "QB", 300}, {2, "RB", 250}, {3, "WR", 200}}
+	tests := []struct {
+		name   string
+		ranked []rankedPlayer
+		roster []string
+		want   int64
+	}{
+		{"best player fits", ranked, nil, 1},
+		{"quarterbacks are full", ranked, []string{"QB", "QB"}, 2},
+		{"only the receiver fits", ranked, []string{"QB", "QB", "RB", "RB"}, 3},
+		{"nobody fits", ranked, []string{"QB", "QB", "RB", "RB", "WR", "WR"}, 1},
+		{"lowercase positions", []rankedPlayer{{4, "te", 100}}, []string{"te"}, 4},
+	}
+	for _, test := range tests {
+		roster := append([]string{}, test.roster...)
+		if got := bestFit(test.ranked, roster, &settings); got != test.want {
+			t.Errorf("%s: got %v want %v", test.name, got, test.want)
+		}
+		//bestFit tries players on a copy of the roster, not the roster itself.
+		if len(roster) != len(test.roster) {
+			t.Errorf("%s: roster changed to %v", test.name, roster)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/PhiloTFarnsworth/FantasySportsAF/store"
+)
+
+//Sockets drop, especially on phones, and a client that reconnects used to refetch the draft history and hope it
+//didn't miss anything in between.  Now every message the hub sends to a whole room, other than status, gets a Seq,
+//counting up from 1 per room, and is kept in draft_events.  A client that remembers the last Seq it saw can send a
+//"sync" and the hub replays everything after it, in order, before anything new goes out.  Mock drafts keep their
+//events in memory, like everything else about them.
+
+const maxSeqTries = 5
+
+type syncRequest struct {
+	room string
+	conn *connection
+	seq  int64
+}
+
+//roomLeague pulls the league ID out of a league or supplemental draft room.
+func roomLeague(room string) (int64, error) {
+	return strconv.ParseInt(strings.SplitN(room, "/", 2)[0], 10, 64)
+}
+
+//sequence stamps the room's next Seq on a message and keeps it for replay.  Messages are JSON objects, so we just
+//add a key rather than give every message struct a Seq of its own.
+func (h *hub) sequence(room string, b []byte) ([]byte, error) {
+	var msg map[string]json.RawMessage
+	if err := json.Unmarshal(b, &msg); err != nil {
+		return nil, err
+	}
+
+	if m := h.mocks[room]; m != nil {
+		seq := int64(len(m.events)) + 1
+		msg["Seq"] = json.RawMessage(strconv.FormatInt(seq, 10))
+		b, err := json.Marshal(msg)
+		if err != nil {
+			return nil, err
+		}
+		m.events = append(m.events, b)
+		return b, nil
+	}
+
+	league, err := roomLeague(room)
+	if err != nil {
+		return nil, err
+	}
+	//Other instances are numbering the same rooms, so we let the primary key settle who gets a Seq and try the
+	//next one if somebody beat us to it.
+	for tries := 0; ; tries++ {
+		seq, err := h.lastSeq(room)
+		if err != nil {
+			return nil, err
+		}
+		msg["Seq"] = json.RawMessage(strconv.FormatInt(seq+1, 10))
+		b, err := json.Marshal(msg)
+		if err != nil {
+			return nil, err
+		}
+		_, err = h.repos.DB.Exec("INSERT INTO draft_events (league, room, seq, data) VALUES (?,?,?,?)", league, room, seq+1, string(b))
+		if store.IsDuplicate(err) && tries < maxSeqTries {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return b, nil
+	}
+}
+
+//lastSeq is the newest Seq in a room, 0 if nothing has happened there yet.
+func (h *hub) lastSeq(room string) (int64, error) {
+	if m := h.mocks[room]; m != nil {
+		return int64(len(m.events)), nil
+	}
+	var seq int64
+	row := h.repos.DB.QueryRow("SELECT COALESCE(MAX(seq), 0) FROM draft_events WHERE room=?", room)
+	err := row.Scan(&seq)
+	return seq, err
+}
+
+//maxReplay keeps a replay within a connection's send buffer.  Clients that are further behind than this just sync
+//again from where the last batch left off.
+const maxReplay = 200
+
+//replay sends a connection the events in its room after the Seq it asked for, then a "synced" with the last Seq it
+//got.  More is set when there's still more to catch up on.
+func (h *hub) replay(r syncRequest) {
+	//The connection may have been dropped for falling behind while this was on its way.
+	if !h.rooms[r.room][r.conn] {
+		return
+	}
+	latest, err := h.lastSeq(r.room)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	//A client ahead of us is holding events from before a reset, so it gets everything over again.
+	if r.seq < 0 || r.seq > latest {
+		r.seq = 0
+	}
+
+	var events [][]byte
+	if m := h.mocks[r.room]; m != nil {
+		for i := r.seq; i < int64(len(m.events)) && len(events) < maxReplay; i++ {
+			events = append(events, m.events[i])
+		}
+	} else {
+		rows, err := h.repos.DB.Query("SELECT data FROM draft_events WHERE room=? AND seq>? ORDER BY seq LIMIT ?", r.room, r.seq, maxReplay)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		for rows.Next() {
+			var data string
+			if err = rows.Scan(&data); err != nil {
+				rows.Close()
+				fmt.Println(err)
+				return
+			}
+			events = append(events, []byte(data))
+		}
+		rows.Close()
+	}
+
+	synced := syncedFrame{Kind: "synced", Seq: r.seq}
+	//Leave room in the buffer for the synced message itself.
+	for _, b := range events {
+		if len(r.conn.send) >= cap(r.conn.send)-1 {
+			break
+		}
+		r.conn.send <- b
+		synced.Seq++
+	}
+	synced.More = synced.Seq < latest
+	b, err := json.Marshal(synced)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	select {
+	case r.conn.send <- b:
+	default:
+	}
+}
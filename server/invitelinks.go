@@ -0,0 +1,326 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PhiloTFarnsworth/FantasySportsAF/config"
+	"github.com/PhiloTFarnsworth/FantasySportsAF/store"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	csrf "github.com/utrack/gin-csrf"
+)
+
+//Invite links are for commissioners who don't have everyone's email.  A link carries its row in invite_links, the
+//league, when it expires and the row's nonce, signed with INVITESECRET, so a made up or altered link never gets as
+//far as the database.  The row is what lets a commissioner revoke a link or limit it to one use.  Visiting a link
+//shows a page asking the user to accept, after logging in if they haven't.  Accepting works like getting an invite
+//by email: the league turns up in the user's invites, and they join from there.
+
+const (
+	defaultLinkHours = 7 * 24
+	maxLinkHours     = 30 * 24
+)
+
+var (
+	errBadLink     = errors.New("invalid invite link")
+	errLinkExpired = errors.New("invite link has expired")
+	errLinkUsed    = errors.New("invite link is no longer valid")
+)
+
+var (
+	linkSecret     []byte
+	linkSecretOnce sync.Once
+)
+
+//inviteSecret is the key links are signed with.  Without INVITESECRET we make one up, which works fine until the
+//server restarts and every link stops working.
+func inviteSecret() []byte {
+	linkSecretOnce.Do(func() {
+		if s := config.Current().Secrets.Invite; s != "" {
+			linkSecret = []byte(s)
+			return
+		}
+		linkSecret = make([]byte, 32)
+		if _, err := rand.Read(linkSecret); err != nil {
+			log.Fatal(err)
+		}
+		log.Println("INVITESECRET not set, invite links won't survive a restart")
+	})
+	return linkSecret
+}
+
+type inviteLink struct {
+	ID        int64
+	League    int64
+	Link      string
+	Created   time.Time
+	Expires   time.Time
+	SingleUse bool
+	Uses      int64
+	Revoked   bool
+	nonce     string
+}
+
+func signLink(ID int64, league int64, expires time.Time, nonce string) string {
+	payload := fmt.Sprintf("%d.%d.%d.%s", ID, league, expires.Unix(), nonce)
+	mac := hmac.New(sha256.New, inviteSecret())
+	mac.Write([]byte(payload))
+	return payload + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+//readLink checks a link's signature and expiry, and returns the link's row and league.
+func readLink(token string) (int64, int64, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 5 {
+		return 0, 0, errBadLink
+	}
+	ID, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, 0, errBadLink
+	}
+	league, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, 0, errBadLink
+	}
+	expires, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return 0, 0, errBadLink
+	}
+	if !hmac.Equal([]byte(signLink(ID, league, time.Unix(expires, 0), parts[3])), []byte(token)) {
+		return 0, 0, errBadLink
+	}
+	if time.Now().After(time.Unix(expires, 0)) {
+		return 0, 0, errLinkExpired
+	}
+	return ID, league, nil
+}
+
+//createInviteLink makes a new link for a league.  Links last a week unless the commissioner says otherwise, and
+//can be limited to a single use.
+func createInviteLink(c *gin.Context, repos store.Repos) {
+	db := repos.DB
+	type LinkBody struct {
+		League    int64 `json:"league"`
+		Hours     int   `json:"hours"`
+		SingleUse bool  `json:"singleUse"`
+	}
+	var b LinkBody
+	if err := c.BindJSON(&b); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	if b.Hours == 0 {
+		b.Hours = defaultLinkHours
+	}
+	if b.Hours < 1 || b.Hours > maxLinkHours {
+		c.JSON(http.StatusBadRequest, "Links can last between 1 and "+strconv.Itoa(maxLinkHours)+" hours")
+		return
+	}
+
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	//Expiry goes into the signature in whole seconds, so that's all we keep.
+	l := inviteLink{
+		League:    b.League,
+		Created:   time.Now().Truncate(time.Second),
+		Expires:   time.Now().Add(time.Duration(b.Hours) * time.Hour).Truncate(time.Second),
+		SingleUse: b.SingleUse,
+		nonce:     hex.EncodeToString(nonce),
+	}
+	ID, err := store.InsertID(db, "INSERT INTO invite_links (league, nonce, created, expires, singleUse) VALUES (?,?,?,?,?)",
+		l.League, l.nonce, l.Created, l.Expires, l.SingleUse)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	l.ID = ID
+	l.Link = "/invite/" + signLink(l.ID, l.League, l.Expires, l.nonce)
+	c.JSON(http.StatusOK, l)
+}
+
+//listInviteLinks shows a commissioner every link for their league, newest first.
+func listInviteLinks(c *gin.Context, repos store.Repos) {
+	db := repos.DB
+	league := access(c).League
+
+	rows, err := db.Query("SELECT ID, nonce, created, expires, singleUse, uses, revoked FROM invite_links WHERE league=? ORDER BY ID DESC", league)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	defer rows.Close()
+	links := make([]inviteLink, 0)
+	for rows.Next() {
+		l := inviteLink{League: league}
+		if err = rows.Scan(&l.ID, &l.nonce, &l.Created, &l.Expires, &l.SingleUse, &l.Uses, &l.Revoked); err != nil {
+			c.JSON(http.StatusBadRequest, err.Error())
+			return
+		}
+		l.Link = "/invite/" + signLink(l.ID, l.League, l.Expires, l.nonce)
+		links = append(links, l)
+	}
+	c.JSON(http.StatusOK, links)
+}
+
+//revokeInviteLink stops a link from working.  Invites already handed out by it stay put, the commissioner can
+//revoke those the usual way.
+func revokeInviteLink(c *gin.Context, repos store.Repos) {
+	db := repos.DB
+	type RevokeBody struct {
+		League int64 `json:"league"`
+		Link   int64 `json:"link"`
+	}
+	var b RevokeBody
+	if err := c.BindJSON(&b); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	res, err := db.Exec("UPDATE invite_links SET revoked=TRUE WHERE ID=? AND league=?", b.Link, b.League)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		//MySQL doesn't count rows that were already revoked, so check before calling it missing.
+		var exists int
+		row := db.QueryRow("SELECT COUNT(*) FROM invite_links WHERE ID=? AND league=?", b.Link, b.League)
+		if err = row.Scan(&exists); err != nil || exists == 0 {
+			c.JSON(http.StatusNotFound, "No such link")
+			return
+		}
+	}
+	c.JSON(http.StatusOK, "success")
+}
+
+//checkLink makes sure a link is signed, hasn't expired and still has a use left, and returns its row and league.
+//The status is what to answer with when it isn't any good.
+func checkLink(db *sql.DB, token string) (int64, int64, int, error) {
+	ID, league, err := readLink(token)
+	if err == errLinkExpired {
+		return 0, 0, http.StatusGone, err
+	} else if err != nil {
+		return 0, 0, http.StatusBadRequest, err
+	}
+
+	//A dead link should say so, whoever follows it.
+	var revoked, singleUse bool
+	var uses int64
+	row := db.QueryRow("SELECT revoked, singleUse, uses FROM invite_links WHERE ID=? AND league=?", ID, league)
+	if err = row.Scan(&revoked, &singleUse, &uses); err == sql.ErrNoRows || revoked || (singleUse && uses > 0) {
+		return 0, 0, http.StatusGone, errLinkUsed
+	} else if err != nil {
+		return 0, 0, http.StatusBadRequest, err
+	}
+	return ID, league, http.StatusOK, nil
+}
+
+//loginFirst sends someone who isn't logged in to log in, and back to the link once they have.
+func loginFirst(c *gin.Context) {
+	c.Redirect(http.StatusSeeOther, "/?next="+url.QueryEscape(c.Request.URL.Path))
+}
+
+//showInviteLink is where a link takes a user.  Following a link shouldn't sign anyone up for anything, so all we do
+//here is say which league it's for and ask them to accept.
+func showInviteLink(c *gin.Context, repos store.Repos) {
+	session := sessions.Default(c)
+	db := repos.DB
+	user, ok := session.Get("user").(int64)
+	if !ok {
+		loginFirst(c)
+		return
+	}
+	_, league, status, err := checkLink(db, c.Param("token"))
+	if err != nil {
+		c.JSON(status, err.Error())
+		return
+	}
+	if _, err = managedTeam(db, league, user); err == nil {
+		c.JSON(http.StatusBadRequest, "Already in league")
+		return
+	}
+	var name string
+	if err = db.QueryRow("SELECT name FROM league WHERE ID=?", league).Scan(&name); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	c.HTML(http.StatusOK, "invite.html", gin.H{"league": name, "link": c.Request.URL.Path, "CSRFToken": csrf.GetToken(c)})
+}
+
+//acceptInviteLink is the confirmation page's POST.  The league goes into the user's invites, same as if the
+//commissioner had invited them by email, and they're sent home to join it.
+func acceptInviteLink(c *gin.Context, repos store.Repos) {
+	session := sessions.Default(c)
+	db := repos.DB
+	user, ok := session.Get("user").(int64)
+	if !ok {
+		loginFirst(c)
+		return
+	}
+	ID, league, status, err := checkLink(db, c.Param("token"))
+	if err != nil {
+		c.JSON(status, err.Error())
+		return
+	}
+
+	if _, err = managedTeam(db, league, user); err == nil {
+		c.JSON(http.StatusBadRequest, "Already in league")
+		return
+	}
+	var invited int
+	row := db.QueryRow("SELECT COUNT(*) FROM invites WHERE league=? AND user=?", league, user)
+	if err = row.Scan(&invited); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	if invited > 0 {
+		//Nothing to do, and no reason to use up the link.
+		c.Redirect(http.StatusSeeOther, "/")
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	defer tx.Rollback()
+
+	//Taking a use and checking the link is still good happen together, so two people can't share a single use.
+	res, err := tx.Exec("UPDATE invite_links SET uses=uses+1 WHERE ID=? AND league=? AND NOT revoked AND (NOT singleUse OR uses=0)", ID, league)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		c.JSON(http.StatusGone, errLinkUsed.Error())
+		return
+	}
+
+	_, err = tx.Exec("INSERT INTO invites (league, user) VALUES (?,?)", league, user)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	if err = tx.Commit(); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	c.Redirect(http.StatusSeeOther, "/")
+}
@@ -0,0 +1,398 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/PhiloTFarnsworth/FantasySportsAF/store"
+	"github.com/PhiloTFarnsworth/FantasySportsAF/store/scanners"
+	"github.com/gin-gonic/gin"
+)
+
+//Keeper and dynasty leagues work by renewing a league into a new season.  The renewed league gets a copy of
+//the old league's settings, teams and managers, and each team can then choose keepers from their old roster.
+//Keepers cost a pick in the new draft, which we fill in when the commissioner starts the draft.
+
+//settingsColumns lists the columns we carry over for each settings table when a league is renewed.  Draft
+//time is left behind, since last year's draft day isn't much use.
+var settingsColumns = []struct {
+	table   string
+	columns string
+}{
+	{"draft_settings", "kind, draftOrder, draftClock, rounds, pace"},
+	{"positional_settings", "kind, qb, rb, wr, te, flex, bench, superflex, def, k"},
+	{"scoring_settings_offense", `pass_att, pass_comp, pass_yard, pass_td, pass_int, pass_sack, rush_att, rush_yard, rush_td,
+		rec_tar, rec, rec_yard, rec_td, fum, fum_lost, misc_td, two_point, two_point_pass`},
+	{"scoring_settings_defense", `touchdown, sack, interception, safety, shutout,
+		points_6, points_13, points_20, points_27, points_34, points_35, yardBonus, yards`},
+	{"scoring_settings_special", "fg_29, fg_39, fg_49, fg_50, extra_point"},
+	{"keeper_settings", "keepers, roundCost, undraftedRound"},
+}
+
+type keeper struct {
+	Team   int64
+	Player int64
+	Round  int
+}
+
+//keeperSettings returns a league's keeper settings.  Leagues created before keepers existed don't have a row,
+//so they get the defaults, which keep nobody.
+func keeperSettings(db *sql.DB, league int64) (scanners.KeeperSettings, error) {
+	k := scanners.KeeperSettings{ID: int(league), RoundCost: 1}
+	row := db.QueryRow("SELECT * FROM keeper_settings WHERE ID=?", league)
+	if err := k.ScanRow(row); err != nil && err != sql.ErrNoRows {
+		return k, err
+	}
+	return k, nil
+}
+
+func getKeeperSettings(c *gin.Context, repos store.Repos) {
+	k, err := keeperSettings(repos.DB, access(c).League)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, k)
+}
+
+//setKeeperSettings lets the commissioner change how many keepers teams get and what they cost.
+func setKeeperSettings(c *gin.Context, repos store.Repos) {
+	db := repos.DB
+	var k scanners.KeeperSettings
+	if err := c.BindJSON(&k); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	k.ID = int(access(c).League)
+
+	if k.Keepers < 0 || k.RoundCost < 0 || k.UndraftedRound < 0 {
+		c.JSON(http.StatusBadRequest, "Keeper settings can't be negative")
+		return
+	}
+
+	_, err := db.Exec(store.Upsert("INTO keeper_settings (ID, keepers, roundCost, undraftedRound) VALUES (?,?,?,?)",
+		[]string{"ID"}, []string{"keepers", "roundCost", "undraftedRound"}),
+		k.ID, k.Keepers, k.RoundCost, k.UndraftedRound)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, k)
+}
+
+//renewLeague starts the next season of a league once its draft is done.  Settings, teams, managers and roles are
+//copied into a new league, which starts back in the INIT state so the commissioner can fill any empty seats.
+func renewLeague(c *gin.Context, repos store.Repos) {
+	db := repos.DB
+	type RenewBody struct {
+		ID int64 `json:"league"`
+	}
+	var b RenewBody
+	if err := c.BindJSON(&b); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var state string
+	row := db.QueryRow("SELECT state FROM league WHERE ID=?", b.ID)
+	if err := row.Scan(&state); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	//We can only renew once the draft is behind us.  Since nothing moves a league past DRAFT yet, a finished
+	//draft board counts too.
+	switch state {
+	case "INPROGRESS", "COMPLETE":
+	case "DRAFT":
+		board, err := loadBoard(db, b.ID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, err.Error())
+			return
+		}
+		if _, err = board.nextPick(); err != errDraftComplete {
+			c.JSON(http.StatusBadRequest, "Draft still in progress")
+			return
+		}
+	default:
+		c.JSON(http.StatusBadRequest, "League hasn't drafted yet")
+		return
+	}
+
+	var renewed int64
+	row = db.QueryRow("SELECT COUNT(*) FROM league WHERE previous=?", b.ID)
+	if err := row.Scan(&renewed); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	if renewed > 0 {
+		c.JSON(http.StatusBadRequest, "League already renewed")
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	defer tx.Rollback()
+
+	leagueID, err := store.InsertID(tx, `INSERT INTO league (name, commissioner, maxOwner, kind, season, previous)
+		SELECT name, commissioner, maxOwner, kind, season+1, ID FROM league WHERE ID=?`, b.ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	//Postgres can't tell what type a placeholder in the SELECT is meant to be, so the new ID goes in as it is.
+	newID := strconv.FormatInt(leagueID, 10)
+	for _, s := range settingsColumns {
+		_, err = tx.Exec("INSERT INTO "+s.table+" (ID, "+s.columns+") SELECT "+newID+", "+s.columns+" FROM "+s.table+" WHERE ID=?", b.ID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	//Older leagues won't have had keeper settings to copy.
+	_, err = tx.Exec(store.InsertIgnore("INTO keeper_settings (ID) VALUES (?)"), leagueID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	//Teams keep their numbers, so last season's team 3 is still team 3.
+	_, err = tx.Exec("INSERT INTO teams (league, ID, name, manager) SELECT "+newID+", ID, name, manager FROM teams WHERE league=?", b.ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	_, err = tx.Exec("INSERT INTO league_roles (league, user, role, permissions) SELECT "+newID+", user, role, permissions FROM league_roles WHERE league=?", b.ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"leagueID": leagueID})
+}
+
+//getKeepers returns every keeper designated in a renewed league.
+func getKeepers(c *gin.Context, repos store.Repos) {
+	keepers, err := leagueKeepers(repos.DB, access(c).League)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, keepers)
+}
+
+func leagueKeepers(db *sql.DB, league int64) ([]keeper, error) {
+	keepers := make([]keeper, 0)
+	rows, err := db.Query("SELECT team, player, round FROM keepers WHERE league=? ORDER BY team, round", league)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var k keeper
+		if err = rows.Scan(&k.Team, &k.Player, &k.Round); err != nil {
+			return nil, err
+		}
+		keepers = append(keepers, k)
+	}
+	return keepers, nil
+}
+
+//setKeepers replaces the requesting manager's keepers in a renewed league.  Each player has to have been on the
+//manager's team last season, and we work out the round they cost here so managers know the price up front.
+func setKeepers(c *gin.Context, repos store.Repos) {
+	db := repos.DB
+	type KeeperBody struct {
+		Players []int64 `json:"players"`
+	}
+	var b KeeperBody
+	if err := c.BindJSON(&b); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	a := access(c)
+	league, team := a.League, a.Team
+
+	var state string
+	var previous sql.NullInt64
+	row := db.QueryRow("SELECT state, previous FROM league WHERE ID=?", league)
+	if err := row.Scan(&state, &previous); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	if !previous.Valid {
+		c.JSON(http.StatusBadRequest, "League wasn't renewed from a previous season")
+		return
+	}
+	if state != "INIT" && state != "PREDRAFT" {
+		c.JSON(http.StatusBadRequest, "Keepers are locked once the draft starts")
+		return
+	}
+
+	oldTeam, err := managedTeam(db, previous.Int64, a.User)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, "No team in the previous season")
+		return
+	}
+
+	settings, err := keeperSettings(db, league)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(b.Players) > settings.Keepers {
+		c.JSON(http.StatusBadRequest, "Too many keepers")
+		return
+	}
+	kept := make(map[int64]bool)
+	for _, player := range b.Players {
+		if kept[player] {
+			c.JSON(http.StatusBadRequest, "Player "+strconv.FormatInt(player, 10)+" is in there twice")
+			return
+		}
+		kept[player] = true
+	}
+
+	var rounds, oldTeams int
+	row = db.QueryRow("SELECT rounds FROM draft_settings WHERE ID=?", league)
+	if err = row.Scan(&rounds); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	row = db.QueryRow("SELECT COUNT(*) FROM teams WHERE league=?", previous.Int64)
+	if err = row.Scan(&oldTeams); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	//Price each keeper.  Drafted players cost the round they went in minus the round cost, while players that
+	//came off waivers cost the undrafted round.
+	var keepers []keeper
+	for _, player := range b.Players {
+		k := keeper{Team: team, Player: player}
+		var pick int
+		row = db.QueryRow("SELECT ID FROM draft_picks WHERE league=? AND player=? AND team=?", previous.Int64, player, oldTeam)
+		err = row.Scan(&pick)
+		switch err {
+		case nil:
+			k.Round = pick/oldTeams + 1 - settings.RoundCost
+		case sql.ErrNoRows:
+			var onRoster int
+			row = db.QueryRow("SELECT COUNT(*) FROM rosters WHERE league=? AND player=? AND team=?", previous.Int64, player, oldTeam)
+			if err = row.Scan(&onRoster); err != nil {
+				c.JSON(http.StatusBadRequest, err.Error())
+				return
+			}
+			if onRoster == 0 {
+				c.JSON(http.StatusBadRequest, "Player "+strconv.FormatInt(player, 10)+" wasn't on your team")
+				return
+			}
+			k.Round = settings.UndraftedRound
+			if k.Round == 0 {
+				k.Round = rounds
+			}
+		default:
+			c.JSON(http.StatusBadRequest, err.Error())
+			return
+		}
+		if k.Round < 1 {
+			k.Round = 1
+		}
+		if k.Round > rounds {
+			k.Round = rounds
+		}
+		keepers = append(keepers, k)
+	}
+	if keepers, err = assignKeeperRounds(keepers, rounds); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("DELETE FROM keepers WHERE league=? AND team=?", league, team)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	for _, k := range keepers {
+		_, err = tx.Exec("INSERT INTO keepers (league, team, player, round) VALUES (?,?,?,?)", league, k.Team, k.Player, k.Round)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if keepers == nil {
+		keepers = make([]keeper, 0)
+	}
+	c.JSON(http.StatusOK, keepers)
+}
+
+var errKeeperRounds = errors.New("not enough rounds for keepers")
+
+//assignKeeperRounds sorts out teams keeping two players that cost the same round.  The cheaper keeper keeps their
+//round and the other moves up to the closest earlier round that's free, or back if there's none.
+func assignKeeperRounds(keepers []keeper, rounds int) ([]keeper, error) {
+	sort.SliceStable(keepers, func(i, j int) bool { return keepers[i].Round > keepers[j].Round })
+	used := make(map[int]bool)
+	for i := range keepers {
+		round := keepers[i].Round
+		for round >= 1 && used[round] {
+			round--
+		}
+		if round < 1 {
+			for round = keepers[i].Round; round <= rounds && used[round]; round++ {
+			}
+		}
+		if round > rounds {
+			return nil, errKeeperRounds
+		}
+		used[round] = true
+		keepers[i].Round = round
+	}
+	return keepers, nil
+}
+
+//keeperPicks works out the draft picks that keepers cost, once startDraft has settled the draft order.  A keeper
+//whose round isn't in the draft any more, say because the commissioner cut the rounds after keepers were set, is an
+//error rather than a player who quietly goes missing.
+func keeperPicks(settings store.DraftSettings, d []store.TeamSlot, keepers []store.Keeper) ([]store.Pick, error) {
+	b := &draftBoard{order: settings.DraftOrder, rounds: settings.Rounds}
+	for _, o := range d {
+		b.teams = append(b.teams, boardTeam{ID: o.Team, Slot: o.Slot})
+	}
+	sort.Slice(b.teams, func(i, j int) bool { return b.teams[i].Slot < b.teams[j].Slot })
+
+	var picks []store.Pick
+	for _, k := range keepers {
+		pick, ok := b.pickFor(k.Team, k.Round)
+		if !ok {
+			return nil, fmt.Errorf("Team %d keeps player %d in round %d, but the draft has %d rounds.  Change the rounds or have them set their keepers again",
+				k.Team, k.Player, k.Round, settings.Rounds)
+		}
+		picks = append(picks, store.Pick{Slot: pick, Player: k.Player, Team: k.Team})
+	}
+	return picks, nil
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/PhiloTFarnsworth/FantasySportsAF/store"
+)
+
+func TestAssignKeeperRounds(t *testing.T) {
+	tests := []struct {
+		name   string
+		rounds []int
+		draft  int
+		want   []int
+	}{
+		{"no clash", []int{3, 7}, 15, []int{7, 3}},
+		{"clash moves up", []int{5, 5}, 15, []int{5, 4}},
+		{"clash in the first round moves back", []int{1, 1}, 15, []int{1, 2}},
+		{"up past a taken round", []int{4, 4, 3}, 15, []int{4, 3, 2}},
+		{"not enough rounds", []int{1, 1}, 1, nil},
+	}
+	for _, test := range tests {
+		var keepers []keeper
+		for i, r := range test.rounds {
+			keepers = append(keepers, keeper{Team: 1, Player: int64(i + 1), Round: r})
+		}
+		got, err := assignKeeperRounds(keepers, test.draft)
+		if test.want == nil {
+			if err != errKeeperRounds {
+				t.Errorf("%s: got %v want errKeeperRounds", test.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		for i, k := range got {
+			if k.Round != test.want[i] {
+				t.Errorf("%s: got %+v want rounds %v", test.name, got, test.want)
+				break
+			}
+		}
+	}
+}
+
+func TestKeeperPicks(t *testing.T) {
+	settings := store.DraftSettings{DraftOrder: "SNAKE", Rounds: 3}
+	order := []store.TeamSlot{{Team: 20, Slot: 2}, {Team: 10, Slot: 1}}
+	picks, err := keeperPicks(settings, order, []store.Keeper{{Team: 20, Player: 5, Round: 1}, {Team: 20, Player: 6, Round: 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	//Team 20 picks second, then first in the snake back.
+	if len(picks) != 2 || picks[0].Slot != 1 || picks[1].Slot != 2 || picks[1].Player != 6 {
+		t.Errorf("got %+v", picks)
+	}
+	if _, err = keeperPicks(settings, order, []store.Keeper{{Team: 10, Player: 7, Round: 4}}); err == nil {
+		t.Error("a keeper past the last round should be an error")
+	}
+}
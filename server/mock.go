@@ -0,0 +1,481 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	mathrand "math/rand"
+	"net/http"
+	"time"
+
+	"github.com/PhiloTFarnsworth/FantasySportsAF/store/scanners"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+//Mock drafts let a user practice against bots, with or without a league.  Nothing gets saved: the hub keeps the
+//board in memory and forgets it once the user leaves the room.  Picks still go through hub.makePick and the same
+//board validation as a real draft, a mock just records them on its own board instead of a draft table.
+
+const (
+	mockRoomPrefix = "mock/"
+	mockMaxTeams   = 16
+	mockMaxRounds  = 30
+	//How long the user gets on the clock by default, and the bounds they can set it to.
+	mockClock    = 10 * time.Second
+	mockMinClock = 3 * time.Second
+	mockMaxClock = 60 * time.Second
+	//Bots don't need to think, but picks landing all at once make for a confusing draft room.
+	mockBotDelay = time.Second
+	//How long a mock waits for the user to show up before we throw it away.
+	mockIdle = 5 * time.Minute
+	//Random bots pick from this many of the best players that fit their roster.
+	mockRandomPool = 5
+)
+
+//Bot strategies.  "best" takes the best player that fits, "need" fills empty starting spots first and "random"
+//picks from the top few players that fit, so no two mocks go quite the same way.
+var mockStrategies = map[string]bool{"best": true, "need": true, "random": true}
+
+type mockDraft struct {
+	ID       string
+	owner    int64
+	board    *draftBoard
+	settings scanners.PositionalSettings
+	//every player, best first, and each player's position
+	ranked    []rankedPlayer
+	positions map[int64]string
+	//strategy by team, teams without one are the user's
+	strategies map[int64]string
+	clock      time.Duration
+	timer      *time.Timer
+	rng        *mathrand.Rand
+	started    bool
+	//everything sent to the room, for replay
+	events [][]byte
+}
+
+type mockTeam struct {
+	ID       int64
+	Slot     int64
+	Bot      bool
+	Strategy string
+}
+
+type mockTeamSummary struct {
+	mockTeam
+	Players   []int64
+	Positions map[string]int
+	Points    float64
+}
+
+//defaultPositions and defaultScoring match the column defaults in store/migrations, for mocks run without a league.
+func defaultPositions() scanners.PositionalSettings {
+	return scanners.PositionalSettings{Kind: "TRAD", QB: 1, RB: 2, WR: 2, TE: 1, Flex: 1, Bench: 6, Def: 1, K: 1}
+}
+
+func defaultScoring() scanners.ScoringSettingsOff {
+	return scanners.ScoringSettingsOff{
+		PassYard:           0.04,
+		PassTouchdown:      6,
+		PassInterception:   -3,
+		RushYard:           0.1,
+		RushTouchdown:      6,
+		ReceivingYard:      0.1,
+		ReceivingTouchdown: 6,
+		Fumble:             -1,
+		FumbleLost:         -2,
+		MiscTouchdown:      6,
+		TwoPointConversion: 2,
+		TwoPointPass:       2,
+	}
+}
+
+func (m *mockDraft) room() string {
+	return mockRoomPrefix + m.ID
+}
+
+//teams lists the mock's teams in slot order.
+func (m *mockDraft) teams() []mockTeam {
+	teams := make([]mockTeam, len(m.board.teams))
+	for i, t := range m.board.teams {
+		teams[i] = mockTeam{t.ID, t.Slot, t.Autodraft, m.strategies[t.ID]}
+	}
+	return teams
+}
+
+//userTeam is the team the user drafts for.
+func (m *mockDraft) userTeam() int64 {
+	for _, t := range m.board.teams {
+		if !t.Autodraft {
+			return t.ID
+		}
+	}
+	return 0
+}
+
+//record is the mock half of hub.makePick.  Bots only pick through the hub, so a user can't pick for them.
+func (m *mockDraft) record(p draftPick) error {
+	if _, ok := m.positions[p.player]; !ok {
+		return errors.New("no such player")
+	}
+	if t, ok := m.board.team(p.team); !ok || (t.Manager != p.user && p.user != 0) {
+		return errNotOnClock
+	}
+	if err := m.board.validate(p); err != nil {
+		return err
+	}
+	m.board.taken[p.pick] = p.player
+	return nil
+}
+
+//roster returns a team's players so far, in the order they were picked.
+func (m *mockDraft) roster(team int64) []int64 {
+	var players []int64
+	total := int64(m.board.rounds * len(m.board.teams))
+	for pick := int64(0); pick < total; pick++ {
+		if player, ok := m.board.taken[pick]; ok && m.board.teamFor(pick).ID == team {
+			players = append(players, player)
+		}
+	}
+	return players
+}
+
+//choose picks a player for a team using a strategy.  The user's team gets "best" when their clock runs out.
+func (m *mockDraft) choose(team int64, strategy string) int64 {
+	taken := make(map[int64]bool, len(m.board.taken))
+	for _, player := range m.board.taken {
+		taken[player] = true
+	}
+	available := make([]rankedPlayer, 0, len(m.ranked))
+	for _, p := range m.ranked {
+		if !taken[p.ID] {
+			available = append(available, p)
+		}
+	}
+	var roster []string
+	for _, player := range m.roster(team) {
+		roster = append(roster, m.positions[player])
+	}
+	fits := func(p rankedPlayer) bool {
+		return m.settings.CanRoster(append(roster[:len(roster):len(roster)], p.Position))
+	}
+
+	switch strategy {
+	case "need":
+		starters := map[string]int{"QB": m.settings.QB, "RB": m.settings.RB, "WR": m.settings.WR, "TE": m.settings.TE,
+			"DEF": m.settings.Def, "K": m.settings.K}
+		counts := make(map[string]int)
+		for _, position := range roster {
+			counts[position]++
+		}
+		for _, p := range available {
+			if counts[p.Position] < starters[p.Position] && fits(p) {
+				return p.ID
+			}
+		}
+	case "random":
+		var pool []int64
+		for _, p := range available {
+			if fits(p) {
+				pool = append(pool, p.ID)
+				if len(pool) == mockRandomPool {
+					break
+				}
+			}
+		}
+		if len(pool) > 0 {
+			return pool[m.rng.Intn(len(pool))]
+		}
+	}
+	return bestFit(available, roster, &m.settings)
+}
+
+//summary totals up each team's draft.  Points are what the players scored under the mock's settings.
+func (m *mockDraft) summary() []mockTeamSummary {
+	points := make(map[int64]float64, len(m.ranked))
+	for _, p := range m.ranked {
+		points[p.ID] = p.Points
+	}
+	teams := m.teams()
+	summary := make([]mockTeamSummary, len(teams))
+	for i, t := range teams {
+		s := mockTeamSummary{mockTeam: t, Players: m.roster(t.ID), Positions: make(map[string]int)}
+		for _, player := range s.Players {
+			s.Positions[m.positions[player]]++
+			s.Points += points[player]
+		}
+		summary[i] = s
+	}
+	return summary
+}
+
+//createMock sets up a mock draft and hands it to the hub.  Settings come from one of the user's leagues if they
+//pass one along, otherwise we use the defaults a new league would get.  The user then joins through /ws/mock/:ID.
+func createMock(c *gin.Context, h *hub) {
+	session := sessions.Default(c)
+	db := h.repos.DB
+	type MockBody struct {
+		League     int64    `json:"league"`
+		Teams      int      `json:"teams"`
+		Slot       int      `json:"slot"`
+		Rounds     int      `json:"rounds"`
+		Clock      int      `json:"clock"`
+		Strategies []string `json:"strategies"`
+	}
+	var b MockBody
+	if err := c.BindJSON(&b); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	user := session.Get("user").(int64)
+
+	board := &draftBoard{order: "SNAKE", rounds: 15, taken: make(map[int64]int64)}
+	settings := defaultPositions()
+	scoring := defaultScoring()
+	teams := 10
+	if b.League != 0 {
+		if _, err := managedTeam(db, b.League, user); err != nil {
+			c.JSON(http.StatusForbidden, "User not in league")
+			return
+		}
+		row := db.QueryRow("SELECT l.maxOwner, d.draftOrder, d.rounds FROM league AS l JOIN draft_settings AS d ON l.ID=d.ID WHERE l.ID=?", b.League)
+		if err := row.Scan(&teams, &board.order, &board.rounds); err != nil {
+			c.JSON(http.StatusBadRequest, err.Error())
+			return
+		}
+		row = db.QueryRow("SELECT * FROM positional_settings WHERE ID=?", b.League)
+		if err := settings.ScanRow(row); err != nil {
+			c.JSON(http.StatusBadRequest, err.Error())
+			return
+		}
+		row = db.QueryRow("SELECT * FROM scoring_settings_offense WHERE ID=?", b.League)
+		if err := scoring.ScanRow(row); err != nil {
+			c.JSON(http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if b.Teams != 0 {
+		teams = b.Teams
+	}
+	if b.Rounds != 0 {
+		board.rounds = b.Rounds
+	}
+	if teams < 2 || teams > mockMaxTeams {
+		c.JSON(http.StatusBadRequest, fmt.Sprintf("Mock drafts need between 2 and %d teams", mockMaxTeams))
+		return
+	}
+	if board.rounds < 1 || board.rounds > mockMaxRounds {
+		c.JSON(http.StatusBadRequest, fmt.Sprintf("Mock drafts need between 1 and %d rounds", mockMaxRounds))
+		return
+	}
+	clock := mockClock
+	if b.Clock != 0 {
+		clock = time.Duration(b.Clock) * time.Second
+	}
+	if clock < mockMinClock || clock > mockMaxClock {
+		c.JSON(http.StatusBadRequest, "Clock out of range")
+		return
+	}
+	for _, s := range b.Strategies {
+		if !mockStrategies[s] {
+			c.JSON(http.StatusBadRequest, "Unknown strategy: "+s)
+			return
+		}
+	}
+
+	rng := mathrand.New(mathrand.NewSource(time.Now().UnixNano()))
+	//No slot means the user drafts from wherever the bots leave them.
+	if b.Slot == 0 {
+		b.Slot = rng.Intn(teams) + 1
+	}
+	if b.Slot < 1 || b.Slot > teams {
+		c.JSON(http.StatusBadRequest, "Slot out of range")
+		return
+	}
+
+	var players scanners.PlayerList
+	rows, err := db.Query("SELECT * FROM player")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err = players.ScanRow(rows); err != nil {
+			c.JSON(http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if len(players.Players) < teams*board.rounds {
+		c.JSON(http.StatusBadRequest, "Not enough players for a draft that size")
+		return
+	}
+
+	ID := make([]byte, 8)
+	if _, err = rand.Read(ID); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	m := &mockDraft{
+		ID:         hex.EncodeToString(ID),
+		owner:      user,
+		board:      board,
+		settings:   settings,
+		ranked:     rankPlayers(players.Players, &scoring),
+		positions:  make(map[int64]string, len(players.Players)),
+		strategies: make(map[int64]string),
+		clock:      clock,
+		rng:        rng,
+	}
+	for _, p := range players.Players {
+		m.positions[p.ID] = p.Position
+	}
+	//Team IDs are just their slots.  Bots take the strategies in slot order, starting over if they run out.
+	bots := 0
+	for slot := int64(1); slot <= int64(teams); slot++ {
+		t := boardTeam{ID: slot, Slot: slot}
+		if slot == int64(b.Slot) {
+			t.Manager = user
+		} else {
+			t.Autodraft = true
+			m.strategies[slot] = "best"
+			if len(b.Strategies) > 0 {
+				m.strategies[slot] = b.Strategies[bots%len(b.Strategies)]
+			}
+			bots++
+		}
+		board.teams = append(board.teams, t)
+	}
+	room := m.room()
+	m.timer = time.AfterFunc(mockIdle, func() { h.tick <- clockTick{room: room, pick: -1} })
+
+	h.mock <- m
+	c.JSON(http.StatusOK, gin.H{"mock": m.ID, "team": b.Slot, "rounds": board.rounds, "order": board.order,
+		"clock": int(clock / time.Second), "teams": m.teams()})
+}
+
+//serveMockWs puts the user in a mock draft's room.  The hub turns them away if the mock isn't theirs.
+func serveMockWs(c *gin.Context, h hub) {
+	session := sessions.Default(c)
+	user, ok := session.Get("user").(int64)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, "Log in to join the draft")
+		return
+	}
+	version, ok := negotiateVersion(c)
+	if !ok {
+		rejectVersion(c)
+		return
+	}
+	ws, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	conn := &connection{send: make(chan []byte, 256), ws: ws, user: user, version: version}
+	s := subscription{conn, mockRoomPrefix + c.Param("ID"), 0, 0}
+	h.register <- s
+	go s.writePump()
+	s.readPump(h)
+}
+
+//joinMock catches a connection up on the mock, and starts the draft the first time the user shows up.
+func (h *hub) joinMock(m *mockDraft, conn *connection) {
+	state := mockFrame{
+		Kind:   "mock",
+		Mock:   m.ID,
+		Rounds: m.board.rounds,
+		Order:  m.board.order,
+		Clock:  int(m.clock / time.Second),
+		Teams:  m.teams(),
+		Picks:  make([]draftSlot, 0),
+	}
+	total := int64(m.board.rounds * len(m.board.teams))
+	for pick := int64(0); pick < total; pick++ {
+		if player, ok := m.board.taken[pick]; ok {
+			state.Picks = append(state.Picks, draftSlot{pick, player, m.board.teamFor(pick).ID})
+		}
+	}
+	b, err := json.Marshal(state)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	conn.send <- b
+
+	if !m.started {
+		m.started = true
+		h.advanceMock(m)
+	}
+}
+
+//advanceMock puts the next team on the clock, or wraps the mock up with a summary once the last pick is in.
+func (h *hub) advanceMock(m *mockDraft) {
+	if m.timer != nil {
+		m.timer.Stop()
+	}
+	room := m.room()
+	pick, err := m.board.nextPick()
+	if err != nil {
+		b, err := json.Marshal(summaryFrame{"summary", m.ID, m.summary()})
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		h.broadcastRoom(room, b)
+		return
+	}
+
+	team := m.board.teamFor(pick)
+	wait := m.clock
+	if team.Autodraft {
+		wait = mockBotDelay
+	}
+	b, err := json.Marshal(clockFrame{Kind: "clock", Pick: pick, Team: team.ID, Deadline: time.Now().Add(wait)})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	h.broadcastRoom(room, b)
+	m.timer = time.AfterFunc(wait, func() { h.tick <- clockTick{room: room, pick: pick} })
+}
+
+//mockClock handles a mock's clock running out.  Bots make their pick, and the user gets the best player that fits.
+//Ticks for picks that have already been made are left over from a stopped timer, so we ignore those.  Pick is -1
+//for the idle check we run when the mock is created.
+func (h *hub) mockClock(t clockTick) {
+	m := h.mocks[t.room]
+	if m == nil {
+		return
+	}
+	if len(h.rooms[t.room]) == 0 {
+		h.closeMock(t.room)
+		return
+	}
+	pick, err := m.board.nextPick()
+	if err != nil || pick != t.pick {
+		return
+	}
+	team := m.board.teamFor(pick)
+	strategy := m.strategies[team.ID]
+	if strategy == "" {
+		strategy = "best"
+	}
+	h.makePick(draftPick{m.choose(team.ID, strategy), pick, team.ID, 0, t.room, 0, 0})
+}
+
+//closeMock throws a mock away once nobody is left in its room.
+func (h *hub) closeMock(room string) {
+	m := h.mocks[room]
+	if m == nil {
+		return
+	}
+	if m.timer != nil {
+		m.timer.Stop()
+	}
+	delete(h.mocks, room)
+}
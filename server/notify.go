@@ -0,0 +1,92 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/PhiloTFarnsworth/FantasySportsAF/config"
+	"github.com/PhiloTFarnsworth/FantasySportsAF/mail"
+	"github.com/PhiloTFarnsworth/FantasySportsAF/store"
+)
+
+//Mail goes out through a queue, so a slow mail server never holds up a request.  The sender comes from the
+//config (see mail.New) unless SetSender picks one first, and SiteURL is where emails send people.  There is one
+//queue per process, however many routers get built.
+
+var mailSender mail.Sender
+var mailer *mail.Queue
+var mailOnce sync.Once
+
+//SetSender picks where mail goes.  Call it before NewRouter.
+func SetSender(s mail.Sender) {
+	mailSender = s
+}
+
+//startMail starts the queue the first time a router is built.  Every router after that shares it, so there's only
+//ever the one worker sending mail.
+func startMail() {
+	mailOnce.Do(func() {
+		if mailSender == nil {
+			s, err := mail.New(config.Current().Mail)
+			if err != nil {
+				log.Fatal(err)
+			}
+			mailSender = s
+		}
+		if site := config.Current().SiteURL; site != "" {
+			mail.Site = site
+		}
+		mailer = mail.NewQueue(mailSender)
+	})
+}
+
+//sendMail fills in a template and queues it up.  Nobody's request should fail over an email, so problems only get
+//logged.
+func sendMail(name string, to string, data interface{}) {
+	m, err := mail.Render(name, to, data)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	if err = mailer.Send(m); err != nil {
+		fmt.Println(err)
+	}
+}
+
+//mailInvite lets someone know they've been invited to a league.
+func mailInvite(repos store.Repos, league int64, email string, registered bool) {
+	l, err := repos.Leagues.Get(league)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	sendMail(mail.Invite, email, mail.InviteData{League: l.Name, Commissioner: l.Commissioner.Name, Registered: registered})
+}
+
+//mailRevoke lets someone know their invite was taken back.
+func mailRevoke(repos store.Repos, league int64, email string) {
+	l, err := repos.Leagues.Get(league)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	sendMail(mail.Revoke, email, mail.RevokeData{League: l.Name})
+}
+
+//mailDraftStarting tells every manager in a league that the draft is on, and where they pick.
+func mailDraftStarting(repos store.Repos, league int64) {
+	l, err := repos.Leagues.Get(league)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	teams, err := repos.Teams.List(league)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for _, t := range teams {
+		sendMail(mail.DraftStarting, t.Manager.Email, mail.DraftStartingData{League: l.Name, Team: t.Name, Slot: t.Slot})
+	}
+}
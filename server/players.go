@@ -0,0 +1,111 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/PhiloTFarnsworth/FantasySportsAF/store"
+	"github.com/PhiloTFarnsworth/FantasySportsAF/store/scanners"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+//statHistory is a week or season of stats, with the fantasy points it's worth when a league is provided.
+type statHistory struct {
+	scanners.PlayerStats
+	Points *float64 `json:",omitempty"`
+}
+
+//playerDetail returns everything we know about a single player: the summary from the player table,
+//their weekly and season stat history, and who owns them in each of the requesting user's leagues.
+//Pass ?league=ID to have the history scored with that league's settings, which only the league's members get to see.
+func playerDetail(c *gin.Context, repos store.Repos) {
+	session := sessions.Default(c)
+
+	playerID, err := strconv.ParseInt(c.Param("ID"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	p, err := repos.Players.Get(playerID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			c.JSON(http.StatusNotFound, "Player not found")
+			return
+		}
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var scoring *scanners.ScoringSettingsOff
+	if league := c.Query("league"); league != "" {
+		user, ok := session.Get("user").(int64)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, "Log in first")
+			return
+		}
+		ID, err := strconv.ParseInt(league, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, err.Error())
+			return
+		}
+		m, err := repos.Roles.Member(ID, user)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, err.Error())
+			return
+		}
+		if !newLeagueAccess(m).can(Spectator) {
+			c.JSON(http.StatusForbidden, "User not in league")
+			return
+		}
+		s, err := repos.Settings.Scoring(ID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, err.Error())
+			return
+		}
+		scoring = &s.O
+	}
+
+	weekStats, seasonStats, err := repos.Players.Stats(playerID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	weeks := make([]statHistory, 0, len(weekStats))
+	for _, s := range weekStats {
+		weeks = append(weeks, statHistory{PlayerStats: s}.scored(scoring))
+	}
+	seasons := make([]statHistory, 0, len(seasonStats))
+	for _, s := range seasonStats {
+		seasons = append(seasons, statHistory{PlayerStats: s}.scored(scoring))
+	}
+
+	response := gin.H{"player": p, "weeks": weeks, "seasons": seasons}
+	if scoring != nil {
+		response["points"] = p.Score(scoring)
+	}
+
+	//Anonymous users get the stats, but there's no leagues to check.
+	if session.Get("user") == nil {
+		response["ownership"] = make([]store.Ownership, 0)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+	owners, err := repos.Players.Owners(session.Get("user").(int64), playerID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	response["ownership"] = owners
+	c.JSON(http.StatusOK, response)
+}
+
+func (s statHistory) scored(scoring *scanners.ScoringSettingsOff) statHistory {
+	if scoring != nil {
+		points := s.Score(scoring)
+		s.Points = &points
+	}
+	return s
+}
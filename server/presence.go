@@ -0,0 +1,217 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+//Presence is a bit more than whether someone has the draft room open.  A user is active while they're doing things
+//in the room, idle after idleAfter without any input, and away after awayAfter, or as soon as their client says
+//so (the draft room tells us when its tab is hidden).  Disconnected means no connections to the room at all.  Any
+//frame from a client counts as input, and clients with a quiet user can send "presence" frames to keep them active.
+//
+//Each instance tracks the connections it holds, and picks up everyone else's from the status messages other
+//instances send.
+
+const (
+	presenceActive       = "active"
+	presenceIdle         = "idle"
+	presenceAway         = "away"
+	presenceDisconnected = "disconnected"
+
+	idleAfter     = time.Minute
+	awayAfter     = 5 * time.Minute
+	presenceCheck = 10 * time.Second
+)
+
+type presence struct {
+	state     string
+	lastInput time.Time
+	//the client told us the user stepped away
+	away      bool
+	spectator bool
+	//the user is connected to another instance, which keeps their state up to date
+	remote bool
+}
+
+//activity is input from a user in a room.  State is what the input says about them, away when their client tells
+//us they've left, otherwise active.
+type activity struct {
+	room  string
+	user  int64
+	state string
+}
+
+type presencePayload struct {
+	State string
+}
+
+func (p *presencePayload) validate() error {
+	if p.State != presenceActive && p.State != presenceAway {
+		return errors.New("State is active or away")
+	}
+	return nil
+}
+
+type userPresence struct {
+	User  int64
+	State string
+}
+
+//presenceOf is where a user stands in a room.
+func (h *hub) presenceOf(room string, user int64) string {
+	if p := h.presence[room][user]; p != nil {
+		return p.state
+	}
+	return presenceDisconnected
+}
+
+//roomPresence lists everyone in a room, for connections that just joined.
+func (h *hub) roomPresence(room string) []userPresence {
+	users := make([]userPresence, 0, len(h.presence[room]))
+	for user, p := range h.presence[room] {
+		users = append(users, userPresence{user, p.state})
+	}
+	return users
+}
+
+//setPresence moves a user to a new state and lets the room know, unless that's where they already were.
+func (h *hub) setPresence(room string, user int64, p *presence, state string) {
+	if p.state == state {
+		return
+	}
+	p.state = state
+	h.broadcastStatus(room, h.statusMessage(user, p), 0)
+}
+
+func (h *hub) statusMessage(user int64, p *presence) []byte {
+	b, err := json.Marshal(status{"status", user, p.state != presenceDisconnected, p.spectator, p.state})
+	if err != nil {
+		fmt.Println(err)
+	}
+	return b
+}
+
+//joinPresence marks a user active when a connection of theirs joins a room.  Everyone else hears about it, even
+//when the user already had a tab open, same as always.
+func (h *hub) joinPresence(s subscription) {
+	users := h.presence[s.room]
+	if users == nil {
+		users = map[int64]*presence{}
+		h.presence[s.room] = users
+	}
+	p := users[s.conn.user]
+	if p == nil || p.remote {
+		p = &presence{spectator: s.conn.spectator}
+		users[s.conn.user] = p
+	}
+	p.state = presenceActive
+	p.lastInput = time.Now()
+	p.away = false
+	h.broadcastStatus(s.room, h.statusMessage(s.conn.user, p), s.conn.user)
+}
+
+//leavePresence disconnects a user from a room once their last connection to it closes.
+func (h *hub) leavePresence(s subscription) {
+	for c := range h.rooms[s.room] {
+		if c.user == s.conn.user {
+			return
+		}
+	}
+	p := h.presence[s.room][s.conn.user]
+	if p == nil {
+		return
+	}
+	delete(h.presence[s.room], s.conn.user)
+	if len(h.presence[s.room]) == 0 {
+		delete(h.presence, s.room)
+	}
+	h.setPresence(s.room, s.conn.user, p, presenceDisconnected)
+}
+
+//recordActivity brings a user back to active, or away if that's what their client said.
+func (h *hub) recordActivity(a activity) {
+	p := h.presence[a.room][a.user]
+	if p == nil || p.remote {
+		return
+	}
+	p.lastInput = time.Now()
+	p.away = a.state == presenceAway
+	h.setPresence(a.room, a.user, p, p.current())
+}
+
+//current works out a user's state from their input.
+func (p *presence) current() string {
+	since := time.Since(p.lastInput)
+	switch {
+	case p.away || since >= awayAfter:
+		return presenceAway
+	case since >= idleAfter:
+		return presenceIdle
+	}
+	return presenceActive
+}
+
+//checkPresence moves users who've gone quiet to idle, then away.
+func (h *hub) checkPresence() {
+	for room, users := range h.presence {
+		for user, p := range users {
+			if !p.remote {
+				h.setPresence(room, user, p, p.current())
+			}
+		}
+	}
+}
+
+//remotePresence keeps track of users on other instances from the status messages those instances send.
+func (h *hub) remotePresence(room string, user int64, state string, spectator bool) {
+	if state == "" {
+		return
+	}
+	users := h.presence[room]
+	p := users[user]
+	if p != nil && !p.remote {
+		//We hold a connection of theirs, so we know better.
+		return
+	}
+	if state == presenceDisconnected {
+		if p != nil {
+			delete(users, user)
+		}
+		return
+	}
+	if users == nil {
+		users = map[int64]*presence{}
+		h.presence[room] = users
+	}
+	users[user] = &presence{state: state, spectator: spectator, remote: true}
+}
+
+//absent is whether a manager has left their draft, rather than just being slow.
+func (h *hub) absent(room string, user int64) bool {
+	state := h.presenceOf(room, user)
+	return state == presenceAway || state == presenceDisconnected
+}
+
+//benchAbsentTeam puts a team on autodraft when their clock runs out while the manager is away.  An idle manager is
+//probably still around and gets their next pick back, but there's no sense making the whole league wait out an
+//absent manager's clock every round.  They can turn autodraft back off when they return.  Slow drafts don't do this,
+//see leagueClock.
+func (h *hub) benchAbsentTeam(room string, league int64, team boardTeam) {
+	if team.Autodraft || !h.absent(room, team.Manager) {
+		return
+	}
+	_, err := h.repos.DB.Exec("UPDATE teams SET autodraft=TRUE WHERE league=? AND ID=?", league, team.ID)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	b, err := json.Marshal(autodraftFrame{"autodraft", team.ID, true})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	h.broadcastRoom(room, b)
+}
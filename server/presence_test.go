@@ -0,0 +1,94 @@
+package server
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/PhiloTFarnsworth/FantasySportsAF/server/broker"
+	"github.com/PhiloTFarnsworth/FantasySportsAF/store"
+)
+
+func TestPresenceCurrent(t *testing.T) {
+	tests := []struct {
+		name  string
+		quiet time.Duration
+		away  bool
+		want  string
+	}{
+		{"just did something", 0, false, presenceActive},
+		{"gone quiet", idleAfter, false, presenceIdle},
+		{"gone a while", awayAfter, false, presenceAway},
+		{"tab hidden", 0, true, presenceAway},
+	}
+	for _, tt := range tests {
+		p := presence{lastInput: time.Now().Add(-tt.quiet), away: tt.away}
+		if got := p.current(); got != tt.want {
+			t.Errorf("%v: want %v got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+//remoteFrame hands the next message one hub published to another, the way run does.
+func remoteFrame(t *testing.T, to *hub) {
+	t.Helper()
+	select {
+	case m := <-to.remote:
+		to.remoteMessage(m)
+	case <-time.After(5 * time.Second):
+		t.Fatal("message never arrived")
+	}
+}
+
+//Two instances share a room.  Larry (user 2) is connected to the first, and the second keeps up with him, and with
+//the draft clock, from what the first publishes.  Neither needs a database, since presence is never kept for replay.
+func TestRemotePresence(t *testing.T) {
+	bus := broker.NewBus()
+	a, b := bus.Client(), bus.Client()
+	defer a.Close()
+	defer b.Close()
+	first, second := newHub(a, store.NewMemory().Repos()), newHub(b, store.NewMemory().Repos())
+
+	conn := &connection{send: make(chan []byte, 8), user: 2}
+	s := subscription{conn, "1", 1, 0}
+	first.rooms["1"] = map[*connection]bool{conn: true}
+	first.joinPresence(s)
+	remoteFrame(t, second)
+	if got := second.presenceOf("1", 2); got != presenceActive {
+		t.Errorf("joined: want %v got %v", presenceActive, got)
+	}
+
+	first.recordActivity(activity{"1", 2, presenceAway})
+	remoteFrame(t, second)
+	if !second.absent("1", 2) {
+		t.Errorf("stepped away: got %v", second.presenceOf("1", 2))
+	}
+	//Activity for a user on another instance is up to that instance.
+	second.recordActivity(activity{"1", 2, presenceActive})
+	if got := second.presenceOf("1", 2); got != presenceAway {
+		t.Errorf("second instance changed a remote user to %v", got)
+	}
+
+	clock, err := json.Marshal(clockFrame{"clock", 3, 4, time.Now().Add(time.Minute), 2, presenceAway})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first.publish(broker.Message{Room: "1", Data: clock})
+	remoteFrame(t, second)
+	if c := second.clocks["1"]; c == nil || c.pick != 3 || c.team != 4 || c.manager != 2 {
+		t.Errorf("got clock %+v", c)
+	}
+
+	delete(first.rooms["1"], conn)
+	first.leavePresence(s)
+	remoteFrame(t, second)
+	if got := second.presenceOf("1", 2); got != presenceDisconnected {
+		t.Errorf("left: want %v got %v", presenceDisconnected, got)
+	}
+	for len(conn.send) > 0 {
+		var f struct{ Seq int64 }
+		if err = json.Unmarshal(<-conn.send, &f); err != nil || f.Seq != 0 {
+			t.Errorf("status went out with Seq %v %v", f.Seq, err)
+		}
+	}
+}
@@ -0,0 +1,550 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+//go:generate go run ./protocolgen -o ../src/scripts/protocol.json
+
+//The draft room protocol.  Every frame is a JSON object with a Kind.  Frames from the client carry whatever else
+//they need in Payload, and frames from the server carry their fields alongside Kind.  Anything sent to a whole room
+//apart from status also gets a Seq (see events.go).  Clients pick a protocol version when they connect, either with
+//?v= on the socket URL or by offering an "fsaf.v1" style subprotocol, and the server answers with a "hello".  Clients
+//that don't ask get version 1, which is the protocol the draft room has always spoken.
+//
+//Frames the server can't use get an "error" frame back rather than being dropped, with a Code the client can act
+//on and the Kind of the frame that caused it in Ref.  ProtocolSchema describes all of this for the JS client.
+
+const protocolVersion = 1
+
+var protocolVersions = []int{1}
+
+//Error codes sent in error frames.
+const (
+	codeBadFrame        = "bad_frame"
+	codeUnknownKind     = "unknown_kind"
+	codeForbidden       = "forbidden"
+	codeInvalidPick     = "invalid_pick"
+	codePaused          = "draft_paused"
+	codeMuted           = "muted"
+	codeNotCommissioner = "not_commissioner"
+	codeFailed          = "failed"
+	codeVersion         = "unsupported_version"
+)
+
+var errorCodes = []string{codeBadFrame, codeUnknownKind, codeForbidden, codeInvalidPick, codePaused, codeMuted,
+	codeNotCommissioner, codeFailed, codeVersion}
+
+//Frames from the server.
+
+type helloFrame struct {
+	Kind     string
+	Version  int
+	Versions []int
+}
+
+type errorFrame struct {
+	Kind    string
+	Code    string
+	Message string
+	Ref     string `json:",omitempty"`
+}
+
+//Status will inform the room whether a user has entered or left a draft instance, and how present they are while
+//they're there (see presence.go).  Active is whether they're connected at all, for clients from before State.
+type status struct {
+	Kind      string
+	User      int64
+	Active    bool
+	Spectator bool
+	State     string
+}
+
+type usersFrame struct {
+	Kind     string
+	Users    []int64
+	Presence []userPresence
+	Seq      int64
+}
+
+type chatFrame struct {
+	Kind string
+	chatMessage
+}
+
+type historyFrame struct {
+	Kind     string
+	Messages []chatMessage
+}
+
+type chatDeletedFrame struct {
+	Kind string
+	ID   int64
+}
+
+type muteFrame struct {
+	Kind  string
+	User  int64
+	Muted bool
+}
+
+type draftFrame struct {
+	Kind   string
+	Player int64
+	Team   int64
+	Pick   int64
+}
+
+type autodraftFrame struct {
+	Kind   string
+	Team   int64
+	Active bool
+}
+
+type queueFrame struct {
+	Kind    string
+	Team    int64
+	Players []int64
+}
+
+type dequeueFrame struct {
+	Kind   string
+	Player int64
+	Team   int64
+}
+
+//League clocks also say who manages the team on the clock and whether they're around.  Status messages keep that
+//up to date until the next clock.
+type clockFrame struct {
+	Kind     string
+	Pick     int64
+	Team     int64
+	Deadline time.Time
+	Manager  int64  `json:",omitempty"`
+	State    string `json:",omitempty"`
+}
+
+type pauseFrame struct {
+	Kind   string
+	Paused bool
+}
+
+type rollbackFrame struct {
+	Kind  string
+	Picks []draftSlot
+}
+
+type mockFrame struct {
+	Kind   string
+	Mock   string
+	Rounds int
+	Order  string
+	Clock  int
+	Teams  []mockTeam
+	Picks  []draftSlot
+}
+
+type summaryFrame struct {
+	Kind  string
+	Mock  string
+	Teams []mockTeamSummary
+}
+
+type syncedFrame struct {
+	Kind string
+	Seq  int64
+	More bool
+}
+
+//outboundKinds lists the server's frames for the schema.  Room frames go to the whole room and carry a Seq.  Status
+//goes to the whole room too, but isn't kept for replay, so it has no Seq.
+var outboundKinds = []struct {
+	kind  string
+	frame interface{}
+	room  bool
+}{
+	{"hello", helloFrame{}, false},
+	{"error", errorFrame{}, false},
+	{"users", usersFrame{}, false},
+	{"status", status{}, false},
+	{"chat", chatFrame{}, true},
+	{"history", historyFrame{}, false},
+	{"chatDeleted", chatDeletedFrame{}, true},
+	{"mute", muteFrame{}, true},
+	{"draft", draftFrame{}, true},
+	{"autodraft", autodraftFrame{}, true},
+	{"queue", queueFrame{}, false},
+	{"dequeue", dequeueFrame{}, false},
+	{"clock", clockFrame{}, true},
+	{"pause", pauseFrame{}, true},
+	{"rollback", rollbackFrame{}, true},
+	{"mock", mockFrame{}, false},
+	{"summary", summaryFrame{}, true},
+	{"synced", syncedFrame{}, false},
+}
+
+//Payloads of frames from the client.
+
+type pickPayload struct {
+	Player int64
+	Pick   int64
+	//Old clients send these along.  The server works them out from the connection, so they're ignored.
+	Team   int64 `json:",omitempty"`
+	League int64 `json:",omitempty"`
+}
+
+type syncPayload struct {
+	Seq int64
+}
+
+type undoPayload struct {
+	Count int
+}
+
+type commishPickPayload struct {
+	Player int64
+	Team   int64
+}
+
+type deleteChatPayload struct {
+	Message int64
+}
+
+type mutePayload struct {
+	User int64
+}
+
+//validator is for payloads that can tell when they don't make sense.
+type validator interface {
+	validate() error
+}
+
+func (p *pickPayload) validate() error {
+	if p.Player <= 0 || p.Pick < 0 {
+		return errors.New("pick needs a Player and a Pick")
+	}
+	return nil
+}
+
+func (p *syncPayload) validate() error {
+	if p.Seq < 0 {
+		return errors.New("Seq can't be negative")
+	}
+	return nil
+}
+
+func (p *undoPayload) validate() error {
+	if p.Count < 1 || p.Count > maxUndo {
+		return fmt.Errorf("can undo between 1 and %d picks", maxUndo)
+	}
+	return nil
+}
+
+func (p *commishPickPayload) validate() error {
+	if p.Player <= 0 || p.Team <= 0 {
+		return errors.New("commishPick needs a Player and a Team")
+	}
+	return nil
+}
+
+func (p *deleteChatPayload) validate() error {
+	if p.Message <= 0 {
+		return errors.New("deleteChat needs a Message")
+	}
+	return nil
+}
+
+func (p *mutePayload) validate() error {
+	if p.User <= 0 {
+		return errors.New("mute needs a User")
+	}
+	return nil
+}
+
+//chatText is the payload of a chat message, which is just the text.
+type chatText string
+
+func (t *chatText) validate() error {
+	if len(strings.TrimSpace(string(*t))) == 0 {
+		return errors.New("empty message")
+	}
+	return nil
+}
+
+//inboundKind describes a frame the client can send.  Payload makes a new payload to decode into, nil when the kind
+//doesn't take one.  Spectators can only send kinds marked for them, league kinds need a league's draft room rather
+//than a mock, and main draft kinds need the league's main draft room.
+type inboundKind struct {
+	payload    func() interface{}
+	spectators bool
+	league     bool
+	mainDraft  bool
+}
+
+var inboundKinds = map[string]inboundKind{
+	"message":     {payload: func() interface{} { return new(chatText) }},
+	"pick":        {payload: func() interface{} { return new(pickPayload) }},
+	"sync":        {payload: func() interface{} { return new(syncPayload) }, spectators: true},
+	"presence":    {payload: func() interface{} { return new(presencePayload) }, spectators: true},
+	"queue":       {league: true},
+	"pause":       {league: true, mainDraft: true},
+	"resume":      {league: true, mainDraft: true},
+	"undo":        {payload: func() interface{} { return new(undoPayload) }, league: true, mainDraft: true},
+	"commishPick": {payload: func() interface{} { return new(commishPickPayload) }, league: true, mainDraft: true},
+	"deleteChat":  {payload: func() interface{} { return new(deleteChatPayload) }, league: true},
+	"mute":        {payload: func() interface{} { return new(mutePayload) }, league: true},
+	"unmute":      {payload: func() interface{} { return new(mutePayload) }, league: true},
+}
+
+//decodeFrame reads a frame from the client into its typed payload.  Frames we can't use come back as an error frame
+//to send to the client.
+func decodeFrame(msg []byte) (string, interface{}, *errorFrame) {
+	var f struct {
+		Kind    string
+		Payload json.RawMessage
+	}
+	if err := json.Unmarshal(msg, &f); err != nil {
+		return "", nil, &errorFrame{Code: codeBadFrame, Message: err.Error()}
+	}
+	k, ok := inboundKinds[f.Kind]
+	if !ok {
+		return f.Kind, nil, &errorFrame{Code: codeUnknownKind, Message: "unknown kind: " + f.Kind, Ref: f.Kind}
+	}
+	if k.payload == nil {
+		return f.Kind, nil, nil
+	}
+	if len(f.Payload) == 0 || string(f.Payload) == "null" {
+		return f.Kind, nil, &errorFrame{Code: codeBadFrame, Message: "missing payload", Ref: f.Kind}
+	}
+	p := k.payload()
+	if err := json.Unmarshal(f.Payload, p); err != nil {
+		return f.Kind, nil, &errorFrame{Code: codeBadFrame, Message: err.Error(), Ref: f.Kind}
+	}
+	if v, ok := p.(validator); ok {
+		if err := v.validate(); err != nil {
+			return f.Kind, nil, &errorFrame{Code: codeBadFrame, Message: err.Error(), Ref: f.Kind}
+		}
+	}
+	return f.Kind, p, nil
+}
+
+//allowed checks that a connection can send a kind of frame from its room.
+func (s subscription) allowed(kind string) *errorFrame {
+	k := inboundKinds[kind]
+	switch {
+	case s.conn.spectator && !k.spectators:
+		return &errorFrame{Code: codeForbidden, Message: "spectators can't do that", Ref: kind}
+	case k.league && s.league == 0:
+		return &errorFrame{Code: codeForbidden, Message: "not in a mock draft", Ref: kind}
+	case k.mainDraft && s.draft != 0:
+		return &errorFrame{Code: codeForbidden, Message: "only in the main draft", Ref: kind}
+	}
+	return nil
+}
+
+//errorCode sorts the errors that make it back to a client into codes.
+func errorCode(err error) string {
+	switch err {
+	case errWrongPick, errNotOnClock, errPlayerTaken, errNoPicksLeft, errDraftComplete, errNotDrafting, errLeagueNotDrafting:
+		return codeInvalidPick
+	case errDraftPaused:
+		return codePaused
+	case errMuted:
+		return codeMuted
+	case errNotCommissioner:
+		return codeNotCommissioner
+	}
+	return codeFailed
+}
+
+func encodeError(e *errorFrame) ([]byte, error) {
+	e.Kind = "error"
+	return json.Marshal(e)
+}
+
+//negotiateVersion works out which protocol version a client wants.  ok is false when it's one we don't speak.
+func negotiateVersion(c *gin.Context) (int, bool) {
+	requested := c.Query("v")
+	for _, p := range websocketProtocols(c.Request) {
+		if strings.HasPrefix(p, "fsaf.v") {
+			requested = strings.TrimPrefix(p, "fsaf.v")
+			break
+		}
+	}
+	if requested == "" {
+		return protocolVersion, true
+	}
+	v, err := strconv.Atoi(requested)
+	if err != nil {
+		return 0, false
+	}
+	for _, supported := range protocolVersions {
+		if v == supported {
+			return v, true
+		}
+	}
+	return 0, false
+}
+
+func websocketProtocols(r *http.Request) []string {
+	var protocols []string
+	for _, h := range r.Header.Values("Sec-Websocket-Protocol") {
+		for _, p := range strings.Split(h, ",") {
+			protocols = append(protocols, strings.TrimSpace(p))
+		}
+	}
+	return protocols
+}
+
+//rejectVersion turns away a client asking for a protocol version we don't have, with the ones we do.
+func rejectVersion(c *gin.Context) {
+	c.JSON(http.StatusBadRequest, gin.H{"Kind": "error", "Code": codeVersion, "Message": "unsupported protocol version",
+		"Versions": protocolVersions})
+}
+
+func helloMessage(version int) ([]byte, error) {
+	return json.Marshal(helloFrame{"hello", version, protocolVersions})
+}
+
+//Schema types.  Types are "number", "string", "boolean", "time" (an RFC 3339 string), "object" with Fields, or
+//"array" with Items.
+
+type fieldSchema struct {
+	Name     string `json:",omitempty"`
+	Type     string
+	Optional bool          `json:",omitempty"`
+	Fields   []fieldSchema `json:",omitempty"`
+	Items    *fieldSchema  `json:",omitempty"`
+}
+
+type frameSchema struct {
+	Kind string
+	//Inbound frames: the payload, if the kind takes one, and who can send it
+	Payload    *fieldSchema `json:",omitempty"`
+	Spectators bool         `json:",omitempty"`
+	League     bool         `json:",omitempty"`
+	MainDraft  bool         `json:",omitempty"`
+	//Outbound frames: the frame's fields, and whether it goes to the whole room with a Seq
+	Fields []fieldSchema `json:",omitempty"`
+	Room   bool          `json:",omitempty"`
+}
+
+type protocolSchema struct {
+	Version    int
+	Versions   []int
+	ErrorCodes []string
+	Inbound    []frameSchema
+	Outbound   []frameSchema
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+//describe builds a schema for a Go type from its JSON encoding.
+func describe(t reflect.Type) fieldSchema {
+	if t == timeType {
+		return fieldSchema{Type: "time"}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return describe(t.Elem())
+	case reflect.Bool:
+		return fieldSchema{Type: "boolean"}
+	case reflect.String:
+		return fieldSchema{Type: "string"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return fieldSchema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		items := describe(t.Elem())
+		return fieldSchema{Type: "array", Items: &items}
+	case reflect.Map:
+		items := describe(t.Elem())
+		return fieldSchema{Type: "object", Items: &items}
+	case reflect.Struct:
+		return fieldSchema{Type: "object", Fields: describeFields(t)}
+	}
+	return fieldSchema{Type: "unknown"}
+}
+
+//describeFields lists a struct's JSON fields, flattening embedded structs the way encoding/json does.
+func describeFields(t reflect.Type) []fieldSchema {
+	var fields []fieldSchema
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			fields = append(fields, describeFields(f.Type)...)
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		name, opts := f.Name, ""
+		if tag, ok := f.Tag.Lookup("json"); ok {
+			if tag == "-" {
+				continue
+			}
+			parts := strings.SplitN(tag, ",", 2)
+			if parts[0] != "" {
+				name = parts[0]
+			}
+			if len(parts) > 1 {
+				opts = parts[1]
+			}
+		}
+		d := describe(f.Type)
+		d.Name = name
+		d.Optional = strings.Contains(opts, "omitempty")
+		fields = append(fields, d)
+	}
+	return fields
+}
+
+//ProtocolSchema describes the draft room protocol as JSON, for the JS client to build against.
+func ProtocolSchema() ([]byte, error) {
+	s := protocolSchema{Version: protocolVersion, Versions: protocolVersions, ErrorCodes: errorCodes}
+
+	kinds := make([]string, 0, len(inboundKinds))
+	for kind := range inboundKinds {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		k := inboundKinds[kind]
+		f := frameSchema{Kind: kind, Spectators: k.spectators, League: k.league, MainDraft: k.mainDraft}
+		if k.payload != nil {
+			p := describe(reflect.TypeOf(k.payload()))
+			f.Payload = &p
+		}
+		s.Inbound = append(s.Inbound, f)
+	}
+
+	for _, o := range outboundKinds {
+		fields := describeFields(reflect.TypeOf(o.frame))
+		if o.room {
+			fields = append(fields, fieldSchema{Name: "Seq", Type: "number"})
+		}
+		s.Outbound = append(s.Outbound, frameSchema{Kind: o.kind, Fields: fields, Room: o.room})
+	}
+	return json.MarshalIndent(s, "", "  ")
+}
+
+//protocol serves ProtocolSchema.
+func protocol(c *gin.Context) {
+	b, err := ProtocolSchema()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	c.Data(http.StatusOK, "application/json", b)
+}
@@ -0,0 +1,24 @@
+//protocolgen writes out the draft room protocol schema for the JS client.  Run it with go generate from the server
+//package whenever the protocol changes, and commit what it writes.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/PhiloTFarnsworth/FantasySportsAF/server"
+)
+
+var out = flag.String("o", "protocol.json", "Where to write the schema")
+
+func main() {
+	flag.Parse()
+	b, err := server.ProtocolSchema()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err = os.WriteFile(*out, append(b, '\n'), 0644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,136 @@
+package server
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/PhiloTFarnsworth/FantasySportsAF/store"
+	"github.com/gin-gonic/gin"
+)
+
+//Teams can line up the players they're after ahead of (and during) the draft.  The queue lives in draft_queue
+//so it survives refreshes, and the draft room uses it both to clean up after picks and to pick for teams that
+//need the help.
+
+//managedTeam finds the team a user manages in a league.  Returns sql.ErrNoRows when the user isn't in the league.
+func managedTeam(db *sql.DB, league int64, user int64) (int64, error) {
+	var team int64
+	row := db.QueryRow("SELECT ID FROM teams WHERE league=? AND manager=?", league, user)
+	err := row.Scan(&team)
+	return team, err
+}
+
+//teamQueue returns a team's queued players in order, skipping anyone who has already been drafted.
+func teamQueue(db *sql.DB, league int64, team int64) ([]int64, error) {
+	queue := make([]int64, 0)
+	rows, err := db.Query(`SELECT player FROM draft_queue WHERE league=? AND team=?
+		AND player NOT IN (SELECT player FROM draft_picks WHERE league=?) ORDER BY priority`, league, team, league)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var player int64
+		if err = rows.Scan(&player); err != nil {
+			return nil, err
+		}
+		queue = append(queue, player)
+	}
+	return queue, nil
+}
+
+//dequeuePlayer pulls a drafted player out of every queue in the league.  We hand back the teams that had
+//the player queued so the draft room can let them know.
+func dequeuePlayer(db *sql.DB, league int64, player int64) ([]int64, error) {
+	var teams []int64
+	rows, err := db.Query("SELECT team FROM draft_queue WHERE league=? AND player=?", league, player)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var team int64
+		if err = rows.Scan(&team); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		teams = append(teams, team)
+	}
+	rows.Close()
+
+	if _, err = db.Exec("DELETE FROM draft_queue WHERE league=? AND player=?", league, player); err != nil {
+		return nil, err
+	}
+	return teams, nil
+}
+
+//getQueue returns the requesting user's queue for the league in the path.
+func getQueue(c *gin.Context, repos store.Repos) {
+	db := repos.DB
+	a := access(c)
+	league, team := a.League, a.Team
+
+	queue, err := teamQueue(db, league, team)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"team": team, "players": queue})
+}
+
+//setQueue replaces the requesting user's queue with the ordered list of players posted.  Reordering,
+//adding and removing all go through here, since the client always has the whole list handy anyway.
+func setQueue(c *gin.Context, repos store.Repos) {
+	db := repos.DB
+	type QueueBody struct {
+		Players []int64 `json:"players"`
+	}
+	var b QueueBody
+	if err := c.BindJSON(&b); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	a := access(c)
+	league, team := a.League, a.Team
+
+	seen := make(map[int64]bool)
+	for _, p := range b.Players {
+		if seen[p] {
+			c.JSON(http.StatusBadRequest, "Player queued twice")
+			return
+		}
+		seen[p] = true
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("DELETE FROM draft_queue WHERE league=? AND team=?", league, team)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	for i, p := range b.Players {
+		_, err = tx.Exec("INSERT INTO draft_queue (league, team, player, priority) VALUES (?,?,?,?)", league, team, p, i)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	queue, err := teamQueue(db, league, team)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"team": team, "players": queue})
+}
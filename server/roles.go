@@ -0,0 +1,364 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/PhiloTFarnsworth/FantasySportsAF/store"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+//Every /league route is about one league, and who gets to use it comes down to what they are to that league.  Rather
+//than each handler checking for itself (and some forgetting to), the router says what each route needs, and
+//requireLeague looks the user up once, turns away anyone short of it and leaves what it found on the context.
+
+//Role is what a user is to a league, each one able to do everything the ones before it can.
+type Role int
+
+const (
+	//NoRole is someone with nothing to do with the league.  Routes that need it only want the league looked up.
+	NoRole Role = iota
+	//Spectators follow a league without a team.  Anyone invited is one until they join.
+	Spectator
+	//Managers have a team.
+	Manager
+	//Co-commissioners help run the league, with whichever permissions the commissioner gave them, but only the
+	//commissioner hands out roles or the league itself.
+	CoCommissioner
+	//Commissioner runs the league.  Site admins count as one in every league.
+	Commissioner
+)
+
+func (r Role) String() string {
+	switch r {
+	case Spectator:
+		return "spectator"
+	case Manager:
+		return "manager"
+	case CoCommissioner:
+		return "co-commissioner"
+	case Commissioner:
+		return "commissioner"
+	}
+	return "none"
+}
+
+//The roles that live in league_roles.  Commissioners and managers come from the league and its teams.
+const (
+	roleCoCommissioner = "COCOMMISSIONER"
+	roleSpectator      = "SPECTATOR"
+)
+
+//What a co-commissioner can be allowed to do, and how we tell them they can't.
+const (
+	permInvites  = "INVITES"
+	permSettings = "SETTINGS"
+	permDraft    = "DRAFT"
+	permChat     = "CHAT"
+)
+
+var permissionText = map[string]string{
+	permInvites:  "handle invites",
+	permSettings: "change settings",
+	permDraft:    "run drafts",
+	permChat:     "moderate chat",
+}
+
+//allPermissions is what a co-commissioner gets when the commissioner doesn't say.
+var allPermissions = []string{permInvites, permSettings, permDraft, permChat}
+
+//leagueAccess is what requireLeague found out about the user and the league a request is about.
+type leagueAccess struct {
+	League int64
+	User   int64
+	//Team is the user's team, or 0.
+	Team    int64
+	Invited bool
+	Role    Role
+	//Permissions are a co-commissioner's.
+	Permissions []string
+}
+
+func newLeagueAccess(m store.Member) leagueAccess {
+	a := leagueAccess{League: m.League, User: m.User, Team: m.Team, Invited: m.Invited, Permissions: m.Permissions}
+	switch {
+	case m.Commissioner || m.Admin:
+		a.Role = Commissioner
+	case m.Role == roleCoCommissioner:
+		a.Role = CoCommissioner
+	case m.Team != 0:
+		a.Role = Manager
+	case m.Role == roleSpectator || m.Invited:
+		a.Role = Spectator
+	}
+	return a
+}
+
+//can tells whether the user can use a route that needs a role.  Routes for managers act on the user's own team, so
+//those need a team, which a co-commissioner might not have.
+func (a leagueAccess) can(need Role) bool {
+	if need == Manager {
+		return a.Team != 0
+	}
+	return a.Role >= need
+}
+
+//allowed tells whether a commissioner, or a co-commissioner given the permission, can do something.
+func (a leagueAccess) allowed(permission string) bool {
+	if a.Role == Commissioner {
+		return true
+	}
+	if a.Role != CoCommissioner {
+		return false
+	}
+	for _, p := range a.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+const accessKey = "leagueAccess"
+
+//access is the leagueAccess requireLeague left for the handler.
+func access(c *gin.Context) leagueAccess {
+	return c.MustGet(accessKey).(leagueAccess)
+}
+
+//leagueFrom finds the league a request is about.
+type leagueFrom func(c *gin.Context) (int64, error)
+
+//fromParam takes the league from the :ID in the path.
+func fromParam(c *gin.Context) (int64, error) {
+	return strconv.ParseInt(c.Param("ID"), 10, 64)
+}
+
+//fromBody takes the league from the league field of a JSON body, and puts the body back for the handler to bind.
+func fromBody(c *gin.Context) (int64, error) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return 0, err
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	var b struct {
+		League int64 `json:"league"`
+	}
+	if err = json.Unmarshal(body, &b); err != nil {
+		return 0, err
+	}
+	return b.League, nil
+}
+
+//requireLogin turns away anyone who isn't logged in.
+func requireLogin(c *gin.Context) {
+	if _, ok := sessions.Default(c).Get("user").(int64); !ok {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, "Log in first")
+		return
+	}
+	c.Next()
+}
+
+//requireLeague looks up what the logged in user is to the league from, and lets them through if they can do what
+//need asks.
+func requireLeague(repos store.Repos, from leagueFrom, need Role) gin.HandlerFunc {
+	return requireAccess(repos, from, need, "")
+}
+
+//requirePermission lets through the commissioner, and co-commissioners they've given the permission.
+func requirePermission(repos store.Repos, from leagueFrom, permission string) gin.HandlerFunc {
+	return requireAccess(repos, from, CoCommissioner, permission)
+}
+
+func requireAccess(repos store.Repos, from leagueFrom, need Role, permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := sessions.Default(c).Get("user").(int64)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, "Log in first")
+			return
+		}
+		league, err := from(c)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+			return
+		}
+		m, err := repos.Roles.Member(league, user)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+			return
+		}
+		a := newLeagueAccess(m)
+		if !a.can(need) {
+			if a.Role == NoRole {
+				c.AbortWithStatusJSON(http.StatusForbidden, "User not in league")
+			} else {
+				c.AbortWithStatusJSON(http.StatusForbidden, "Only a "+need.String()+" can do that")
+			}
+			return
+		}
+		if permission != "" && !a.allowed(permission) {
+			c.AbortWithStatusJSON(http.StatusForbidden, "This league's commissioner hasn't let you "+permissionText[permission])
+			return
+		}
+		c.Set(accessKey, a)
+		c.Next()
+	}
+}
+
+//listRoles returns who's been given a role in a league.
+func listRoles(c *gin.Context, repos store.Repos) {
+	roles, err := repos.Roles.List(access(c).League)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, roles)
+}
+
+//setRole lets the commissioner make someone a co-commissioner or spectator, or with no role, take theirs away.
+//Co-commissioners get every permission unless the commissioner lists the ones they want them to have.
+func setRole(c *gin.Context, repos store.Repos) {
+	type RoleBody struct {
+		League      int64    `json:"league"`
+		User        int64    `json:"user"`
+		Role        string   `json:"role"`
+		Permissions []string `json:"permissions"`
+	}
+	var b RoleBody
+	if err := c.BindJSON(&b); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	a := access(c)
+	if b.User == a.User {
+		c.JSON(http.StatusBadRequest, "Commissioners can't give themselves a role")
+		return
+	}
+	if _, err := repos.Users.ByID(b.User); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var err error
+	switch b.Role {
+	case roleCoCommissioner:
+		if b.Permissions == nil {
+			b.Permissions = allPermissions
+		}
+		for _, p := range b.Permissions {
+			if _, ok := permissionText[p]; !ok {
+				c.JSON(http.StatusBadRequest, "Bad permission "+p)
+				return
+			}
+		}
+		err = repos.Roles.Grant(a.League, a.User, b.User, b.Role, b.Permissions)
+	case roleSpectator:
+		b.Permissions = []string{}
+		err = repos.Roles.Grant(a.League, a.User, b.User, b.Role, nil)
+	case "":
+		b.Permissions = []string{}
+		err = repos.Roles.Revoke(a.League, a.User, b.User)
+	default:
+		c.JSON(http.StatusBadRequest, "Bad role")
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, b)
+}
+
+//roleHistory is every role handed out or taken away in a league, and every attempt to hand the league over.
+func roleHistory(c *gin.Context, repos store.Repos) {
+	changes, err := repos.Roles.History(access(c).League)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, changes)
+}
+
+//A commissioner can't just leave their league to whoever.  They offer it to a manager or someone with a role, and
+//it's only the other person's once they accept.  Until then the commissioner can take the offer back, and making a
+//new offer replaces the old one.  Site admins count as commissioners, so they can hand off a league whose
+//commissioner has disappeared.
+
+type transferBody struct {
+	League int64 `json:"league"`
+	User   int64 `json:"user"`
+}
+
+//offerCommissioner offers the league to someone in it.
+func offerCommissioner(c *gin.Context, repos store.Repos) {
+	var b transferBody
+	if err := c.BindJSON(&b); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	a := access(c)
+	m, err := repos.Roles.Member(a.League, b.User)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	if m.Commissioner {
+		c.JSON(http.StatusBadRequest, "They're already the commissioner")
+		return
+	}
+	if m.Team == 0 && m.Role == "" {
+		c.JSON(http.StatusBadRequest, "The league can only go to a manager or someone with a role in it")
+		return
+	}
+	if err = repos.Roles.Offer(a.League, a.User, b.User); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	t, err := repos.Roles.Transfer(a.League)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, t)
+}
+
+//getTransfer shows the offer out for a league, if there is one.
+func getTransfer(c *gin.Context, repos store.Repos) {
+	t, err := repos.Roles.Transfer(access(c).League)
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, "No transfer offered")
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, t)
+}
+
+//answerTransfer handles the commissioner taking their offer back, and the user it went to taking or turning it down.
+func answerTransfer(c *gin.Context, repos store.Repos, action string) {
+	a := access(c)
+	var err error
+	switch action {
+	case store.ActionCancel:
+		err = repos.Roles.Cancel(a.League, a.User)
+	case store.ActionDecline:
+		err = repos.Roles.Decline(a.League, a.User)
+	case store.ActionAccept:
+		err = repos.Roles.Accept(a.League, a.User)
+	}
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, "No transfer offered")
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"league": a.League, "action": action})
+}
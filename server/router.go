@@ -2,26 +2,49 @@ package server
 
 import (
 	"fmt"
-	"os"
+	"log"
 
+	"github.com/PhiloTFarnsworth/FantasySportsAF/config"
+	"github.com/PhiloTFarnsworth/FantasySportsAF/server/broker"
+	"github.com/PhiloTFarnsworth/FantasySportsAF/store"
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-contrib/sessions/cookie"
 	"github.com/gin-gonic/gin"
 	csrf "github.com/utrack/gin-csrf"
 )
 
+//NewRouter builds the router on the database from store.ConnectDB.
 func NewRouter() *gin.Engine {
+	return NewRouterWithRepos(store.NewSQL(store.GetDB()))
+}
+
+//NewRouterWithRepos builds the router on whichever repositories it's given.  Handlers that haven't moved over to
+//repositories yet use the database behind them, repos.DB, and are only routed when there is one.
+func NewRouterWithRepos(repos store.Repos) *gin.Engine {
+	cfg := config.Current()
+	if cfg.Production {
+		gin.SetMode(gin.ReleaseMode)
+	}
 	r := gin.Default()
 
-	store := cookie.NewStore([]byte("secret"))
+	cookies := cookie.NewStore([]byte(cfg.Secrets.Cookie))
+	if cfg.Production {
+		//Same as the defaults, except the cookie only goes over HTTPS and scripts can't read it.
+		cookies.Options(sessions.Options{Path: "/", MaxAge: 86400 * 30, HttpOnly: true, Secure: true})
+	}
 
-	h := newHub()
+	b, err := broker.New(cfg.Broker)
+	if err != nil {
+		log.Fatal(err)
+	}
+	h := newHub(b, repos)
 	go h.run()
+	startMail()
 
-	r.Use(sessions.Sessions("mysession", store))
+	r.Use(sessions.Sessions("mysession", cookies))
 
 	r.Use(csrf.Middleware(csrf.Options{
-		Secret: os.Getenv("CSRFSECRET"),
+		Secret: cfg.Secrets.CSRF,
 		ErrorFunc: func(c *gin.Context) {
 			token := csrf.GetToken(c)
 			c.String(400, fmt.Sprintf("CSRF token mismatch: %s", token))
@@ -29,34 +52,183 @@ func NewRouter() *gin.Engine {
 		},
 	}))
 
-	r.LoadHTMLFiles(os.Getenv("FSGOPATH") + "static/index.html")
-	r.Static("/static", os.Getenv("FSGOPATH")+"static")
+	r.LoadHTMLFiles(cfg.Static+"/index.html", cfg.Static+"/invite.html")
+	r.Static("/static", cfg.Static)
 
 	//basic User paths
-	r.GET("/", index)
-	r.POST("register", register)
-	r.POST("login", login)
+	r.GET("/", func(c *gin.Context) {
+		index(c, repos)
+	})
+	r.POST("register", func(c *gin.Context) {
+		register(c, repos)
+	})
+	r.POST("login", func(c *gin.Context) {
+		login(c, repos)
+	})
 	r.GET("logout", logout)
-	r.GET("leagues", getLeagues)
+	r.GET("leagues", func(c *gin.Context) {
+		getLeagues(c, repos)
+	})
 	//league paths With more time, I'd probably move league into a session, so
 	//that you hop back into your last league.  That would likely require some
 	//redesign to allow users to view their leagues and change between them easily.
-	r.POST("/league/create", createLeague)
-	r.POST("/league/invite", InviteUser)
-	r.POST("/league/revokeInvite", revokeInvite)
-	r.GET("/league/home/:ID", LeagueHome)
-	r.POST("/league/editTeam", editTeamInfo)
-	r.POST("/league/join", joinLeague)
-	r.POST("/league/settings", leagueSettings)
-	r.POST("/league/lock", lockLeague)
-	r.GET("/league/settings/getdraft/:ID", getDraftSettings)
-	r.POST("/league/settings/setdraft/:ID", setDraftSettings)
-	r.GET("/league/settings/getscor/:ID", getScoringSettings)
-	r.POST("/league/startdraft", startDraft)
-	r.GET("/league/draft/:ID", draftHistory)
-	r.GET("draftpool", DraftPool)
+	//Every route past create is about one league, found in the path or the body, and says what the user has to be
+	//in it to get through, or for the commissioner's jobs, which permission a co-commissioner needs.  See roles.go.
+	leagues := r.Group("/league", requireLogin)
+	path := func(need Role) gin.HandlerFunc { return requireLeague(repos, fromParam, need) }
+	body := func(need Role) gin.HandlerFunc { return requireLeague(repos, fromBody, need) }
+	pathCan := func(permission string) gin.HandlerFunc { return requirePermission(repos, fromParam, permission) }
+	bodyCan := func(permission string) gin.HandlerFunc { return requirePermission(repos, fromBody, permission) }
+	leagues.POST("/create", func(c *gin.Context) {
+		createLeague(c, repos)
+	})
+	leagues.POST("/invite", bodyCan(permInvites), func(c *gin.Context) {
+		InviteUser(c, repos)
+	})
+	leagues.POST("/revokeInvite", bodyCan(permInvites), func(c *gin.Context) {
+		revokeInvite(c, repos)
+	})
+	leagues.GET("/home/:ID", path(Spectator), func(c *gin.Context) {
+		LeagueHome(c, repos)
+	})
+	leagues.POST("/editTeam", body(Manager), func(c *gin.Context) {
+		editTeamInfo(c, repos)
+	})
+	//Joining only needs an invite, which joinLeague checks.
+	leagues.POST("/join", body(NoRole), func(c *gin.Context) {
+		joinLeague(c, repos)
+	})
+	leagues.POST("/settings", bodyCan(permSettings), func(c *gin.Context) {
+		leagueSettings(c, repos)
+	})
+	leagues.POST("/lock", bodyCan(permSettings), func(c *gin.Context) {
+		lockLeague(c, repos)
+	})
+	leagues.GET("/settings/getdraft/:ID", path(Spectator), func(c *gin.Context) {
+		getDraftSettings(c, repos)
+	})
+	leagues.POST("/settings/setdraft/:ID", pathCan(permSettings), func(c *gin.Context) {
+		setDraftSettings(c, repos)
+	})
+	leagues.GET("/settings/getscor/:ID", path(Spectator), func(c *gin.Context) {
+		getScoringSettings(c, repos)
+	})
+	leagues.GET("/roles/:ID", path(Spectator), func(c *gin.Context) {
+		listRoles(c, repos)
+	})
+	leagues.GET("/roles/:ID/history", path(CoCommissioner), func(c *gin.Context) {
+		roleHistory(c, repos)
+	})
+	leagues.POST("/roles", body(Commissioner), func(c *gin.Context) {
+		setRole(c, repos)
+	})
+	leagues.GET("/transfer/:ID", path(Spectator), func(c *gin.Context) {
+		getTransfer(c, repos)
+	})
+	leagues.POST("/transfer", body(Commissioner), func(c *gin.Context) {
+		offerCommissioner(c, repos)
+	})
+	leagues.POST("/transfer/cancel", body(Commissioner), func(c *gin.Context) {
+		answerTransfer(c, repos, store.ActionCancel)
+	})
+	//Only the user the league was offered to gets anywhere with these, which the repository checks.
+	leagues.POST("/transfer/accept", body(NoRole), func(c *gin.Context) {
+		answerTransfer(c, repos, store.ActionAccept)
+	})
+	leagues.POST("/transfer/decline", body(NoRole), func(c *gin.Context) {
+		answerTransfer(c, repos, store.ActionDecline)
+	})
+	leagues.GET("/draft/:ID", path(Spectator), func(c *gin.Context) {
+		draftHistory(c, repos)
+	})
+	r.GET("draftpool", func(c *gin.Context) {
+		DraftPool(c, repos)
+	})
+	r.GET("/player/:ID", func(c *gin.Context) {
+		playerDetail(c, repos)
+	})
+	r.GET("/ws/protocol", protocol)
 
-	//Websocket
+	//Invite links, keepers and the draft room haven't moved over to repositories yet, and query repos.DB directly,
+	//so they're left out when there's no database behind the repositories.
+	if repos.DB == nil {
+		return r
+	}
+	if cfg.Features.InviteLinks {
+		leagues.POST("/invitelink/create", bodyCan(permInvites), func(c *gin.Context) {
+			createInviteLink(c, repos)
+		})
+		leagues.GET("/invitelink/list/:ID", pathCan(permInvites), func(c *gin.Context) {
+			listInviteLinks(c, repos)
+		})
+		leagues.POST("/invitelink/revoke", bodyCan(permInvites), func(c *gin.Context) {
+			revokeInviteLink(c, repos)
+		})
+		r.GET("/invite/:token", func(c *gin.Context) {
+			showInviteLink(c, repos)
+		})
+		r.POST("/invite/:token", func(c *gin.Context) {
+			acceptInviteLink(c, repos)
+		})
+	}
+	leagues.GET("/settings/keepers/:ID", path(Spectator), func(c *gin.Context) {
+		getKeeperSettings(c, repos)
+	})
+	leagues.POST("/settings/keepers/:ID", pathCan(permSettings), func(c *gin.Context) {
+		setKeeperSettings(c, repos)
+	})
+	leagues.POST("/renew", bodyCan(permSettings), func(c *gin.Context) {
+		renewLeague(c, repos)
+	})
+	leagues.GET("/keepers/:ID", path(Spectator), func(c *gin.Context) {
+		getKeepers(c, repos)
+	})
+	leagues.POST("/keepers/:ID", path(Manager), func(c *gin.Context) {
+		setKeepers(c, repos)
+	})
+	leagues.POST("/startdraft", bodyCan(permDraft), func(c *gin.Context) {
+		startDraft(c, h, repos)
+	})
+	leagues.POST("/autodraft", body(Manager), func(c *gin.Context) {
+		setAutodraft(c, h)
+	})
+	leagues.POST("/draft/pick/:ID", path(Manager), func(c *gin.Context) {
+		draftPickREST(c, h)
+	})
+	leagues.GET("/draft/clock/:ID", path(Spectator), func(c *gin.Context) {
+		draftClockStatus(c, repos)
+	})
+	leagues.GET("/chat/:ID", path(Spectator), func(c *gin.Context) {
+		chatLog(c, repos)
+	})
+	leagues.GET("/queue/:ID", path(Manager), func(c *gin.Context) {
+		getQueue(c, repos)
+	})
+	leagues.POST("/queue/:ID", path(Manager), func(c *gin.Context) {
+		setQueue(c, repos)
+	})
+	if cfg.Features.Supplemental {
+		leagues.GET("/supplemental/:ID", path(Spectator), func(c *gin.Context) {
+			getSupplemental(c, repos)
+		})
+		leagues.POST("/supplemental/create", bodyCan(permDraft), func(c *gin.Context) {
+			createSupplemental(c, repos)
+		})
+		leagues.POST("/supplemental/start", bodyCan(permDraft), func(c *gin.Context) {
+			startSupplemental(c, repos)
+		})
+		r.GET("/ws/draft/:ID/supplemental/:draft", func(c *gin.Context) {
+			serveWs(c, *h)
+		})
+	}
+	if cfg.Features.MockDrafts {
+		r.POST("/mock/create", func(c *gin.Context) {
+			createMock(c, h)
+		})
+		r.GET("/ws/mock/:ID", func(c *gin.Context) {
+			serveMockWs(c, *h)
+		})
+	}
 	r.GET("/ws/draft/:ID", func(c *gin.Context) {
 		serveWs(c, *h)
 	})
@@ -1,6 +1,20 @@
 package server
 
+import (
+	"log"
+
+	"github.com/PhiloTFarnsworth/FantasySportsAF/config"
+)
+
 func Init() {
 	r := NewRouter()
-	r.Run(":8000") // listen and serve on local:8000
+	cfg := config.Current()
+	//listen and serve on cfg.Listen, :8000 unless told otherwise
+	var err error
+	if cfg.TLS.Cert != "" {
+		err = r.RunTLS(cfg.Listen, cfg.TLS.Cert, cfg.TLS.Key)
+	} else {
+		err = r.Run(cfg.Listen)
+	}
+	log.Fatal(err)
 }
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/PhiloTFarnsworth/FantasySportsAF/mail"
+	"github.com/PhiloTFarnsworth/FantasySportsAF/store"
+	"github.com/gin-gonic/gin"
+)
+
+//Slow drafts run over days instead of in one sitting.  The clock is in hours, managers can pick over REST as well
+//as in the draft room, and whoever comes on the clock gets an email, since they probably aren't watching.  Clocks
+//are saved to draft_clocks as they start, which lets any instance say who's on the clock and lets a restart carry
+//on with the time that was left.
+
+const (
+	paceLive = "LIVE"
+	paceSlow = "SLOW"
+)
+
+//pickRequest is a pick made over REST.  The hub answers on done.
+type pickRequest struct {
+	pick draftPick
+	done chan error
+}
+
+//saveClock records the clock for a league's current pick, and returns the pick the clock was on before, -1 if it
+//wasn't running.
+func saveClock(db *sql.DB, league int64, pick int64, team int64, deadline time.Time) (int64, error) {
+	previous := int64(-1)
+	row := db.QueryRow("SELECT pick FROM draft_clocks WHERE league=?", league)
+	if err := row.Scan(&previous); err != nil && err != sql.ErrNoRows {
+		return 0, err
+	}
+	_, err := db.Exec(store.Upsert("INTO draft_clocks (league, pick, team, deadline) VALUES (?,?,?,?)",
+		[]string{"league"}, []string{"pick", "team", "deadline"}), league, pick, team, deadline)
+	return previous, err
+}
+
+func clearClock(db *sql.DB, league int64) {
+	if _, err := db.Exec("DELETE FROM draft_clocks WHERE league=?", league); err != nil {
+		fmt.Println(err)
+	}
+}
+
+//resumeClocks restarts the clocks of drafts that were running when the server went down.  Whoever was on the clock
+//gets what was left of their time, and if it ran out while we were gone, a second more.
+func (h *hub) resumeClocks() {
+	db := h.repos.DB
+	if db == nil {
+		//Running on the in-memory repositories, so there's nothing to resume.
+		return
+	}
+	rows, err := db.Query(`SELECT c.league, c.pick, c.deadline FROM draft_clocks AS c
+		JOIN league AS l ON c.league=l.ID WHERE l.state='DRAFT'`)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	type saved struct {
+		league   int64
+		pick     int64
+		deadline time.Time
+	}
+	var clocks []saved
+	for rows.Next() {
+		var s saved
+		if err = rows.Scan(&s.league, &s.pick, &s.deadline); err != nil {
+			fmt.Println(err)
+			break
+		}
+		clocks = append(clocks, s)
+	}
+	rows.Close()
+
+	for _, s := range clocks {
+		room := strconv.FormatInt(s.league, 10)
+		c := h.draftClock(room, s.league)
+		c.pick = s.pick
+		c.remaining = time.Until(s.deadline)
+		if c.remaining < time.Second {
+			c.remaining = time.Second
+		}
+		h.runClock(room, s.league)
+	}
+}
+
+//notifyOnClock emails a slow draft's manager that it's their pick, unless they're already in the draft room.
+func (h *hub) notifyOnClock(room string, league int64, pick int64, manager int64, deadline time.Time) {
+	state := h.presenceOf(room, manager)
+	if state == presenceActive || state == presenceIdle {
+		return
+	}
+	go func() {
+		var email, name string
+		row := h.repos.DB.QueryRow("SELECT u.email, l.name FROM user AS u JOIN league AS l ON l.ID=? WHERE u.ID=?", league, manager)
+		if err := row.Scan(&email, &name); err != nil {
+			fmt.Println(err)
+			return
+		}
+		sendMail(mail.OnTheClock, email, mail.OnTheClockData{League: name, Pick: pick + 1, Deadline: deadline})
+	}()
+}
+
+//draftPickREST makes a pick for the user's team in a league's draft, without the draft room.
+func draftPickREST(c *gin.Context, h *hub) {
+	type PickBody struct {
+		Player int64 `json:"player"`
+		Pick   int64 `json:"pick"`
+	}
+	var b PickBody
+	if err := c.BindJSON(&b); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	a := access(c)
+	done := make(chan error, 1)
+	h.restPick <- pickRequest{draftPick{b.Player, b.Pick, a.Team, a.League, c.Param("ID"), 0, a.User}, done}
+	if err := <-done; err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"team": a.Team, "player": b.Player, "pick": b.Pick})
+}
+
+//draftClockStatus says who's on the clock in a league's draft and until when.  Without a clock running, the pick is
+//-1.
+func draftClockStatus(c *gin.Context, repos store.Repos) {
+	db := repos.DB
+	league := access(c).League
+
+	var clock struct {
+		Pick     int64
+		Team     int64
+		Deadline time.Time
+		Pace     string
+	}
+	row := db.QueryRow("SELECT pace FROM draft_settings WHERE ID=?", league)
+	if err := row.Scan(&clock.Pace); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	row = db.QueryRow("SELECT pick, team, deadline FROM draft_clocks WHERE league=?", league)
+	err := row.Scan(&clock.Pick, &clock.Team, &clock.Deadline)
+	if err == sql.ErrNoRows {
+		clock.Pick = -1
+	} else if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, clock)
+}
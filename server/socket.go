@@ -1,12 +1,17 @@
 package server
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/PhiloTFarnsworth/FantasySportsAF/server/broker"
 	"github.com/PhiloTFarnsworth/FantasySportsAF/store"
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
@@ -42,14 +47,24 @@ type connection struct {
 	//The user that opened the connection
 	user int64
 
+	//The team the user manages in the room's league.  Spectators don't have one, and can only watch.
+	team      int64
+	spectator bool
+
+	//The protocol version the client asked for when it connected.
+	version int
+
 	// Buffered channel of outbound messages.
 	send chan []byte
 }
 
 //represents a single client in a single room
 type subscription struct {
-	conn *connection
-	room string
+	conn   *connection
+	room   string
+	league int64
+	//supplemental draft the room is for, 0 for the league's main draft
+	draft int64
 }
 
 //We'll keep message from the example for our chat.
@@ -59,12 +74,6 @@ type message struct {
 	room string
 }
 
-type chat struct {
-	Kind    string
-	User    int64
-	Payload string
-}
-
 //We could conceivably pass all this information along the message struct (see the python implementation),
 //but I think we get a benefit out of creating a different channel for different functions.
 type draftPick struct {
@@ -73,22 +82,23 @@ type draftPick struct {
 	team   int64
 	league int64
 	room   string
-}
-
-//Status will inform the room whether a user has entered or left a draft instance.  This could be expanded further to include
-//functionality like varying states of being in a room (like an "away" status), but for now we'll keep it simple
-type status struct {
-	Kind   string
-	User   int64
-	Active bool
+	draft  int64
+	//user who made the pick, 0 when the server picks for a team
+	user int64
 }
 
 // hub maintains the set of active connections and broadcasts messages to the
 // connections.
 type hub struct {
-	// Registered connections.
+	repos store.Repos
+
+	// Registered connections.  These are only the connections to this server, other instances have their own.
 	rooms map[string]map[*connection]bool
 
+	// Passes room messages to the hubs on other instances, and theirs to us.
+	broker broker.Broker
+	remote <-chan broker.Message
+
 	// Inbound messages from the connections.
 	broadcast chan message
 
@@ -99,21 +109,78 @@ type hub struct {
 	unregister chan subscription
 
 	pick chan draftPick
+
+	// Messages meant for particular users in a room, rather than the whole room.
+	direct chan directMessage
+
+	// Teams switching autodraft on or off, or a draft starting.
+	autodraft chan autodraftToggle
+
+	// Mock drafts, by room.  These only live in memory, so the hub is the only one who knows about them.
+	mocks map[string]*mockDraft
+
+	// Newly created mock drafts.
+	mock chan *mockDraft
+
+	// Draft clocks running out, in leagues and mock drafts.
+	tick chan clockTick
+
+	// League draft clocks, by room.  These also remember whether the commissioner has paused the draft.
+	clocks map[string]*draftClock
+
+	// Commissioner commands from the draft room.
+	commish chan commishCommand
+
+	// Clients asking to replay the events they missed.
+	sync chan syncRequest
+
+	// Picks made over REST, for slow drafts.
+	restPick chan pickRequest
+
+	// Presence of the users in each room, see presence.go.
+	presence map[string]map[int64]*presence
+	activity chan activity
+}
+
+//directMessage is for things only one manager needs to hear about, like their queue.  We address by user
+//rather than connection so every tab the manager has open stays in sync.  Replies to a bad frame only go back to
+//the connection that sent it, in conn.
+type directMessage struct {
+	room  string
+	users []int64
+	conn  *connection
+	data  []byte
 }
 
-func newHub() *hub {
+func newHub(b broker.Broker, repos store.Repos) *hub {
 	return &hub{
+		repos:      repos,
+		broker:     b,
+		remote:     b.Messages(),
 		rooms:      map[string]map[*connection]bool{},
 		broadcast:  make(chan message),
 		register:   make(chan subscription),
 		unregister: make(chan subscription),
 		pick:       make(chan draftPick),
+		direct:     make(chan directMessage),
+		autodraft:  make(chan autodraftToggle),
+		mocks:      map[string]*mockDraft{},
+		mock:       make(chan *mockDraft),
+		tick:       make(chan clockTick),
+		clocks:     map[string]*draftClock{},
+		commish:    make(chan commishCommand),
+		sync:       make(chan syncRequest),
+		restPick:   make(chan pickRequest),
+		presence:   map[string]map[int64]*presence{},
+		activity:   make(chan activity),
 	}
 }
 
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
+	//Clients can ask for a protocol version as a subprotocol, see protocol.go.
+	Subprotocols: []string{"fsaf.v1"},
 }
 
 // readPump pumps messages from the websocket connection to the hub.
@@ -138,37 +205,85 @@ func (s subscription) readPump(h hub) {
 			break
 		}
 
-		var decoded struct {
-			Kind    string
-			Payload json.RawMessage
+		//Frames we can't use, or that this connection isn't allowed to send, get an error back.
+		kind, payload, ferr := decodeFrame(msg)
+		if ferr == nil {
+			//Anything the client sends counts as the user being around.
+			a := activity{s.room, c.user, presenceActive}
+			if p, ok := payload.(*presencePayload); ok {
+				a.state = p.State
+			}
+			h.activity <- a
+
+			ferr = s.allowed(kind)
 		}
-		err = json.Unmarshal(msg, &decoded)
-		if err != nil {
-			fmt.Println(err)
+		if ferr != nil {
+			b, err := encodeError(ferr)
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+			h.direct <- directMessage{room: s.room, conn: c, data: b}
+			continue
 		}
 
-		switch decoded.Kind {
+		switch kind {
 		case "message":
-			//Cut off the quotes from Payload and pass as a message.
-			m := message{[]byte(decoded.Payload[1 : len(decoded.Payload)-1]), s.conn.user, s.room}
+			m := message{[]byte(*payload.(*chatText)), s.conn.user, s.room}
 			h.broadcast <- m
 		case "pick":
-			var n struct {
-				Player int64
-				Pick   int64
-				Team   int64
-				League int64
+			//The league and team come from the connection, not the client.  Clients still say which pick they
+			//think they're making, so a pick meant for a spot that's already gone doesn't land on the next one.
+			n := payload.(*pickPayload)
+			p := draftPick{n.Player, n.Pick, c.team, s.league, s.room, s.draft, c.user}
+			h.pick <- p
+		case "pause", "resume", "undo", "commishPick", "deleteChat", "mute", "unmute":
+			//The hub checks who's asking.
+			cmd := commishCommand{kind: kind, user: c.user, league: s.league, room: s.room}
+			switch n := payload.(type) {
+			case *undoPayload:
+				cmd.count = n.Count
+			case *commishPickPayload:
+				cmd.player, cmd.team = n.Player, n.Team
+			case *deleteChatPayload:
+				cmd.message = n.Message
+			case *mutePayload:
+				cmd.target = n.User
 			}
-			err = json.Unmarshal(decoded.Payload, &n)
+			h.commish <- cmd
+		case "presence":
+			//Already counted as activity above.
+		case "sync":
+			//Replay everything after the last Seq the client saw.
+			h.sync <- syncRequest{s.room, c, payload.(*syncPayload).Seq}
+		case "queue":
+			//Hand the manager their queue, so the draft room can show it without a separate fetch.
+			b, err := s.queueMessage(h.repos.DB)
 			if err != nil {
-				fmt.Println(err)
+				b, err = encodeError(&errorFrame{Code: codeFailed, Message: err.Error(), Ref: kind})
+				if err != nil {
+					fmt.Println(err)
+					break
+				}
 			}
-			p := draftPick{n.Player, n.Pick, n.Team, n.League, s.room}
-			h.pick <- p
+			h.direct <- directMessage{room: s.room, users: []int64{c.user}, data: b}
 		}
 	}
 }
 
+//queueMessage looks up the queue for the connection's team in the room's league.
+func (s subscription) queueMessage(db *sql.DB) ([]byte, error) {
+	team := s.conn.team
+	if team == 0 {
+		return nil, errors.New("no team to queue for")
+	}
+	queue, err := teamQueue(db, s.league, team)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(queueFrame{"queue", team, queue})
+}
+
 // write writes a message with the given message type and payload.
 func (c *connection) write(mt int, payload []byte) error {
 	c.ws.SetWriteDeadline(time.Now().Add(writeWait))
@@ -202,32 +317,91 @@ func (s *subscription) writePump() {
 	}
 }
 
-// serveWs handles websocket requests from the peer.  Adapted to take a *gin.Context
+// serveWs handles websocket requests from the peer.  Adapted to take a *gin.Context.  We sort out who the user is
+// and what they're allowed to do before upgrading, so we can turn people away with a proper status code.
 func serveWs(c *gin.Context, h hub) {
-	ws, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	session := sessions.Default(c)
+	user, ok := session.Get("user").(int64)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, "Log in to join the draft")
+		return
+	}
+	version, ok := negotiateVersion(c)
+	if !ok {
+		rejectVersion(c)
+		return
+	}
+	leagueID, err := strconv.ParseInt(c.Param("ID"), 10, 64)
 	if err != nil {
-		log.Println(err)
+		c.JSON(http.StatusBadRequest, err.Error())
 		return
 	}
-	session := sessions.Default(c)
-	leagueID := c.Param("ID")
-	user := session.Get("user").(int64)
+	db := h.repos.DB
+	team, spectator, err := roomAccess(db, leagueID, user)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusForbidden, "User not in league")
+		return
+	} else if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	//Supplemental drafts get a room of their own, so their picks don't land on the main draft board.
+	room := c.Param("ID")
+	var draft int64
+	if c.Param("draft") != "" {
+		if draft, err = supplementalForLeague(db, leagueID, c.Param("draft")); err != nil {
+			c.JSON(http.StatusNotFound, "No such draft")
+			return
+		}
+		room += "/" + c.Param("draft")
+	}
+
+	ws, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
 		log.Println(err)
 		return
 	}
-	conn := &connection{send: make(chan []byte, 256), ws: ws, user: user}
-	s := subscription{conn, leagueID}
+	conn := &connection{send: make(chan []byte, 256), ws: ws, user: user, team: team, spectator: spectator,
+		version: version}
+	s := subscription{conn, room, leagueID, draft}
 	h.register <- s
 	go s.writePump()
 	s.readPump(h)
 }
 
+//roomAccess works out how a user gets into a league's draft room.  Managers come in with their team, and users
+//with an open invite to the league or a role in it can watch.  Anyone else gets sql.ErrNoRows.
+func roomAccess(db *sql.DB, league int64, user int64) (int64, bool, error) {
+	team, err := managedTeam(db, league, user)
+	if err != sql.ErrNoRows {
+		return team, false, err
+	}
+	var invited int
+	row := db.QueryRow(`SELECT (SELECT COUNT(*) FROM invites WHERE league=? AND user=?)
+		+ (SELECT COUNT(*) FROM league_roles WHERE league=? AND user=?)`, league, user, league, user)
+	if err = row.Scan(&invited); err != nil {
+		return 0, false, err
+	}
+	if invited == 0 {
+		return 0, false, sql.ErrNoRows
+	}
+	return 0, true, nil
+}
+
 func (h *hub) run() {
+	presenceTicker := time.NewTicker(presenceCheck)
+	defer presenceTicker.Stop()
+	h.resumeClocks()
 	for {
 		select {
 		//indicate user has joined draft
 		case s := <-h.register:
+			//Mock rooms only exist as long as the mock draft does, and only the user who made it gets in.
+			m, mock := h.mocks[s.room]
+			if strings.HasPrefix(s.room, mockRoomPrefix) && (!mock || m.owner != s.conn.user) {
+				close(s.conn.send)
+				break
+			}
 			//get room, create if does not exist
 			connections := h.rooms[s.room]
 			if connections == nil {
@@ -235,129 +409,416 @@ func (h *hub) run() {
 				h.rooms[s.room] = connections
 			}
 			h.rooms[s.room][s.conn] = true
-			//user has joined room.  We want to send a message to all connections informing that the user joined the room as well as poll for all connections
-			//in the room.  We then pass all the active connections to the back to the originating user.  We can just pass a list of user ids since we can access
-			//their user info from the team info passed into the league prop.
-			u := status{Kind: "status", User: s.conn.user, Active: true}
-			b, err := json.Marshal(u)
+			//Let the client know which protocol version it got before anything else.
+			hello, err := helloMessage(s.conn.version)
 			if err != nil {
 				fmt.Println(err)
-				return
+				break
 			}
+			s.conn.send <- hello
+			//user has joined room.  We want to send a message to all connections informing that the user joined the room as well as poll for all connections
+			//in the room.  We then pass all the active connections to the back to the originating user.  We can just pass a list of user ids since we can access
+			//their user info from the team info passed into the league prop.
+			//Pass to all non originating connections
+			h.joinPresence(s)
 
-			var userList struct {
-				Kind  string
-				Users []int64
+			//The user list isn't an event everyone sees, but it does carry the room's Seq, so the client knows
+			//where it's starting from.
+			userList := usersFrame{Kind: "users"}
+			if userList.Seq, err = h.lastSeq(s.room); err != nil {
+				fmt.Println(err)
 			}
-			userList.Kind = "users"
 
-			//Pass to all non originating connections, build user list
+			//Build user list.  This only covers users connected to this instance, Presence has everyone we've
+			//heard about.
 			for c := range connections {
-				if c.user != s.conn.user {
-					c.send <- b
-				}
 				userList.Users = append(userList.Users, c.user)
 			}
-			b, err = json.Marshal(userList)
+			userList.Presence = h.roomPresence(s.room)
+			b, err := json.Marshal(userList)
 			if err != nil {
 				fmt.Println(err)
 				return
 			}
 			//send userlist to originating user
 			s.conn.send <- b
+			h.joinChat(s.room, s.conn)
+			if mock {
+				h.joinMock(m, s.conn)
+			} else if c := h.clocks[s.room]; c != nil {
+				h.joinClock(s.room, c, s.conn)
+			}
 
 		case s := <-h.unregister:
 			//indicate user has left draft then close
 			connections := h.rooms[s.room]
+			emptied := false
 			if connections != nil {
 				if _, ok := connections[s.conn]; ok {
 					delete(connections, s.conn)
 					close(s.conn.send)
 					if len(connections) == 0 {
 						delete(h.rooms, s.room)
-					} else {
-						//If there are still open connections, pass a notification that this connection is closing
-						u := status{Kind: "status", User: s.conn.user, Active: false}
-						b, err := json.Marshal(u)
-						if err != nil {
-							fmt.Println(err)
-							return
-						}
-
-						for c := range connections {
-							c.send <- b
-						}
+						emptied = true
 					}
 				}
 			}
+			//Connections that couldn't keep up were already dropped from the room, but they still need to be
+			//marked gone, so this happens either way.
+			h.leavePresence(s)
+			if emptied {
+				h.closeMock(s.room)
+			}
+		case a := <-h.activity:
+			h.recordActivity(a)
+		case <-presenceTicker.C:
+			h.checkPresence()
 		case m := <-h.broadcast:
-			p := chat{Kind: "chat", User: m.User, Payload: string(m.data[:])}
-			b, err := json.Marshal(p)
-			if err != nil {
-				fmt.Println(err)
-				return
+			h.chat(m)
+		case p := <-h.pick:
+			if h.makePick(p) && p.draft == 0 && !strings.HasPrefix(p.room, mockRoomPrefix) {
+				h.advanceDraft(p.room, p.league)
 			}
-			connections := h.rooms[m.room]
-			for c := range connections {
-				select {
-				case c.send <- b:
-				default:
-					close(c.send)
-					delete(connections, c)
-					if len(connections) == 0 {
-						delete(h.rooms, m.room)
-					}
+		case r := <-h.restPick:
+			err := h.applyPick(r.pick)
+			r.done <- err
+			if err == nil {
+				h.advanceDraft(r.pick.room, r.pick.league)
+			}
+		case a := <-h.autodraft:
+			room := strconv.FormatInt(a.league, 10)
+			if a.team != 0 {
+				b, err := json.Marshal(autodraftFrame{"autodraft", a.team, a.active})
+				if err != nil {
+					fmt.Println(err)
+					break
 				}
+				h.broadcastRoom(room, b)
 			}
-		case p := <-h.pick:
-			//First deal with the database
-			db := store.GetDB()
-
-			tx, err := db.Begin()
-			if err != nil {
-				fmt.Println(err)
+			h.advanceDraft(room, a.league)
+		case d := <-h.direct:
+			if d.conn != nil {
+				if h.rooms[d.room][d.conn] {
+					h.sendTo(d.room, d.conn, d.data)
+				}
+				break
 			}
-
-			draftTable := "draft_" + strconv.FormatInt(p.league, 10)
-			_, err = tx.Exec("INSERT INTO "+draftTable+" (ID, player, team) VALUES (?,?,?)", p.pick, p.player, p.team)
-			if err != nil {
-				fmt.Println(err)
-				tx.Rollback()
+			h.sendToUsers(d.room, d.users, d.data)
+		case m := <-h.mock:
+			h.mocks[m.room()] = m
+		case t := <-h.tick:
+			if strings.HasPrefix(t.room, mockRoomPrefix) {
+				h.mockClock(t)
+			} else {
+				h.leagueClock(t)
 			}
-
-			if err = tx.Commit(); err != nil {
-				fmt.Println(err)
+		case cmd := <-h.commish:
+			h.commissionerCommand(cmd)
+		case r := <-h.sync:
+			h.replay(r)
+		case m, ok := <-h.remote:
+			if !ok {
+				//The broker is gone, so this instance is on its own from here on.
+				h.remote = nil
+				break
 			}
+			h.remoteMessage(m)
+		}
+	}
+}
 
-			//What do we want to broadcast?  That the player has been taken by a team at a certain pick.
-			var thisPick struct {
-				Kind   string
-				Player int64
-				Team   int64
-				Pick   int64
-			}
-			thisPick.Kind = "draft"
-			thisPick.Pick = p.pick
-			thisPick.Player = p.player
-			thisPick.Team = p.team
-			b, err := json.Marshal(thisPick)
+//makePick checks a pick against the draft board, records it, tells the room about it and cleans up queues.  Picks
+//from managers, picks the server makes on a team's behalf and picks in mock drafts all come through here.  Returns
+//whether the pick stuck.
+func (h *hub) makePick(p draftPick) bool {
+	if err := h.applyPick(p); err != nil {
+		//Managers hear about picks that didn't go through.  The server's own picks only show up in the logs.
+		if p.user != 0 {
+			h.sendError(p.room, p.user, err)
+		} else {
+			fmt.Println(err)
+		}
+		return false
+	}
+	return true
+}
+
+//applyPick does the work for makePick, and tells the caller what went wrong, if anything.
+func (h *hub) applyPick(p draftPick) error {
+	mock := h.mocks[p.room]
+	var err error
+	if c := h.clocks[p.room]; c != nil && c.paused && p.user != 0 {
+		err = errDraftPaused
+	} else if mock != nil {
+		//Mock connections don't come with a team, the user's team is whichever one isn't a bot.
+		if p.user != 0 {
+			p.team = mock.userTeam()
+		}
+		err = mock.record(p)
+	} else {
+		err = recordPick(h.repos.DB, p)
+	}
+	if err != nil {
+		return err
+	}
+
+	h.announcePick(p)
+
+	//Mock drafts don't have queues, but they do need the next team put on the clock.
+	if mock != nil {
+		h.advanceMock(mock)
+		return nil
+	}
+	//Finally, take the player out of any queues, and let the other managers who were eyeing them know.
+	h.dequeue(p)
+	return nil
+}
+
+//announcePick tells the room a player has been taken by a team at a certain pick.
+func (h *hub) announcePick(p draftPick) {
+	b, err := json.Marshal(draftFrame{"draft", p.player, p.team, p.pick})
+	if err != nil {
+		//The pick is in, the room will just have to sync to see it.
+		fmt.Println(err)
+		return
+	}
+	h.broadcastRoom(p.room, b)
+}
+
+//recordPick validates a pick against the league's board, or the supplemental draft's, and saves it.
+func recordPick(db *sql.DB, p draftPick) error {
+	var board *draftBoard
+	var err error
+	if p.draft != 0 {
+		board, err = loadSupplementalBoard(db, p.league, p.draft)
+	} else {
+		var state string
+		row := db.QueryRow("SELECT state FROM league WHERE ID=?", p.league)
+		if err = row.Scan(&state); err != nil {
+			return err
+		}
+		if state != "DRAFT" {
+			return errLeagueNotDrafting
+		}
+		board, err = loadBoard(db, p.league)
+	}
+	if err != nil {
+		return err
+	}
+	if err = board.validate(p); err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if p.draft != 0 {
+		err = recordSupplementalPick(tx, p)
+	} else {
+		_, err = tx.Exec("INSERT INTO draft_picks (league, ID, player, team) VALUES (?,?,?,?)", p.league, p.pick, p.player, p.team)
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+//runAutodraft picks for teams on autodraft for as long as one of them is on the clock.  We only run this for
+//leagues that are actually drafting, otherwise flipping autodraft on in the offseason would start the draft.
+func (h *hub) runAutodraft(room string, league int64) {
+	db := h.repos.DB
+	for {
+		if c := h.clocks[room]; c != nil && c.paused {
+			return
+		}
+		var state string
+		row := db.QueryRow("SELECT state FROM league WHERE ID=?", league)
+		if err := row.Scan(&state); err != nil {
+			fmt.Println(err)
+			return
+		}
+		if state != "DRAFT" {
+			return
+		}
+
+		board, err := loadBoard(db, league)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		pick, err := board.nextPick()
+		if err != nil {
+			return
+		}
+		team := board.teamFor(pick)
+		if !team.Autodraft {
+			return
+		}
+
+		player, err := autopick(db, league, team.ID)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		if !h.makePick(draftPick{player, pick, team.ID, league, room, 0, 0}) {
+			return
+		}
+	}
+}
+
+//broadcastRoom passes a message to every connection in a room, on this instance and the others.  Every message to
+//the room apart from status is an event, so it gets sequenced and kept for replay first.
+func (h *hub) broadcastRoom(room string, b []byte) {
+	h.broadcastExcept(room, b, 0)
+}
+
+//broadcastExcept is broadcastRoom, leaving out the connections of one user.
+func (h *hub) broadcastExcept(room string, b []byte, except int64) {
+	b, err := h.sequence(room, b)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	m := broker.Message{Room: room, Except: except, Data: b}
+	h.deliver(m)
+	h.publish(m)
+}
+
+//broadcastStatus passes a status message to a room, leaving out the connections of one user, without sequencing
+//it.  Presence changes all the time and is only worth anything as it happens, so there's no sense keeping it for
+//replay: a client that reconnects hears where everyone stands in its "users" instead.
+func (h *hub) broadcastStatus(room string, b []byte, except int64) {
+	m := broker.Message{Room: room, Except: except, Data: b}
+	h.deliver(m)
+	h.publish(m)
+}
+
+//sendToUsers passes a message along to every connection in a room belonging to one of the users.
+func (h *hub) sendToUsers(room string, users []int64, b []byte) {
+	m := broker.Message{Room: room, Users: users, Data: b}
+	h.deliver(m)
+	h.publish(m)
+}
+
+//deliver passes a message to the connections in a room on this instance, dropping connections that can't keep up.
+func (h *hub) deliver(m broker.Message) {
+	connections := h.rooms[m.Room]
+	for c := range connections {
+		if c.user == m.Except || (m.Users != nil && !containsUser(m.Users, c.user)) {
+			continue
+		}
+		h.sendTo(m.Room, c, m.Data)
+	}
+}
+
+//sendTo passes a message to a single connection in a room, dropping it if it can't keep up.
+func (h *hub) sendTo(room string, c *connection, b []byte) {
+	select {
+	case c.send <- b:
+	//timeout
+	default:
+		connections := h.rooms[room]
+		close(c.send)
+		delete(connections, c)
+		if len(connections) == 0 {
+			delete(h.rooms, room)
+		}
+	}
+}
+
+func containsUser(users []int64, user int64) bool {
+	for _, u := range users {
+		if u == user {
+			return true
+		}
+	}
+	return false
+}
+
+//publish hands a message to the broker for the other instances.  Mock drafts only live on the instance that made
+//them, so their rooms stay local.
+func (h *hub) publish(m broker.Message) {
+	if strings.HasPrefix(m.Room, mockRoomPrefix) {
+		return
+	}
+	if err := h.broker.Publish(m); err != nil {
+		fmt.Println(err)
+	}
+}
+
+//remoteMessage delivers a message another instance sent to a room.  Whoever picked or paused last runs the draft
+//clock, so when another instance starts a clock or pauses the draft, we stand ours down to match.  Status messages
+//tell us who's around on the other instances.
+func (h *hub) remoteMessage(m broker.Message) {
+	h.deliver(m)
+	if m.Users != nil {
+		return
+	}
+	var event struct {
+		clockFrame
+		Paused    bool
+		User      int64
+		State     string
+		Spectator bool
+	}
+	if err := json.Unmarshal(m.Data, &event); err != nil {
+		return
+	}
+	c := h.clocks[m.Room]
+	switch event.Kind {
+	case "status":
+		h.remotePresence(m.Room, event.User, event.State, event.Spectator)
+	case "clock":
+		if c == nil {
+			league, err := roomLeague(m.Room)
 			if err != nil {
-				fmt.Println(err)
+				return
 			}
-			//Then broadcast back to the other clients in room
-			connections := h.rooms[p.room]
-			for c := range connections {
-				select {
-				case c.send <- b:
-				//timeout
-				default:
-					close(c.send)
-					delete(connections, c)
-					if len(connections) == 0 {
-						delete(h.rooms, p.room)
-					}
-				}
+			c = h.draftClock(m.Room, league)
+		}
+		c.stop()
+		c.pick, c.team, c.manager, c.deadline = event.Pick, event.Team, event.Manager, event.Deadline
+	case "pause":
+		if c == nil {
+			league, err := roomLeague(m.Room)
+			if err != nil {
+				return
 			}
+			c = h.draftClock(m.Room, league)
+		}
+		c.stop()
+		c.paused = event.Paused
+	}
+}
+
+//dequeue removes a freshly drafted player from the league's queues.  The picking team presumably knows
+//what they just did, so we only send a "dequeue" to the managers of the other teams that had them queued.
+func (h *hub) dequeue(p draftPick) {
+	db := h.repos.DB
+	teams, err := dequeuePlayer(db, p.league, p.player)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	for _, team := range teams {
+		if team == p.team {
+			continue
+		}
+		var manager int64
+		row := db.QueryRow("SELECT manager FROM teams WHERE league=? AND ID=?", p.league, team)
+		if err = row.Scan(&manager); err != nil {
+			fmt.Println(err)
+			continue
+		}
+		b, err := json.Marshal(dequeueFrame{"dequeue", p.player, team})
+		if err != nil {
+			fmt.Println(err)
+			continue
 		}
+		h.sendToUsers(p.room, []int64{manager}, b)
 	}
 }
@@ -0,0 +1,286 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/PhiloTFarnsworth/FantasySportsAF/store"
+	"github.com/gin-gonic/gin"
+)
+
+//Supplemental drafts are the smaller drafts dynasty leagues hold each year for incoming rookies.  They run in
+//their own draft room, but go through the same hub and pick path as the main draft.  The order is straight
+//rather than snake, with the worst team from the standings picking first every round.
+
+var errNotDrafting = errors.New("supplemental draft isn't running")
+
+type supplementalDraft struct {
+	ID     int64
+	Season int
+	Rounds int
+	State  string
+	//team IDs, first pick to last
+	Order []int64
+	Picks []draftSlot
+}
+
+//supplementalForLeague makes sure a supplemental draft belongs to the league, so nobody wanders into another
+//league's draft room by changing the URL.
+func supplementalForLeague(db *sql.DB, league int64, draftParam string) (int64, error) {
+	draft, err := strconv.ParseInt(draftParam, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	var check int64
+	row := db.QueryRow("SELECT league FROM supplemental_draft WHERE ID=?", draft)
+	if err = row.Scan(&check); err != nil {
+		return 0, err
+	}
+	if check != league {
+		return 0, errors.New("supplemental draft not in league")
+	}
+	return draft, nil
+}
+
+//loadSupplementalBoard builds a draft board from a supplemental draft's order and picks.
+func loadSupplementalBoard(db *sql.DB, league int64, draft int64) (*draftBoard, error) {
+	b := &draftBoard{league: league, order: "STRAIGHT", taken: make(map[int64]int64)}
+	row := db.QueryRow("SELECT rounds FROM supplemental_draft WHERE ID=?", draft)
+	if err := row.Scan(&b.rounds); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`SELECT t.ID, t.manager, o.slot, t.autodraft FROM supplemental_order AS o JOIN teams AS t
+		ON t.league=? AND o.team=t.ID WHERE o.draft=? ORDER BY o.slot`, league, draft)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var t boardTeam
+		if err = rows.Scan(&t.ID, &t.Manager, &t.Slot, &t.Autodraft); err != nil {
+			return nil, err
+		}
+		b.teams = append(b.teams, t)
+	}
+
+	rows, err = db.Query("SELECT ID, player FROM supplemental_picks WHERE draft=?", draft)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var pick, player int64
+		if err = rows.Scan(&pick, &player); err != nil {
+			return nil, err
+		}
+		b.taken[pick] = player
+	}
+	return b, nil
+}
+
+//recordSupplementalPick is the supplemental half of hub.makePick.  Besides the pick itself, the rookie goes
+//straight onto the team's roster, and the draft wraps up once the last pick is in.
+func recordSupplementalPick(tx *sql.Tx, p draftPick) error {
+	var state string
+	var rounds, teams int64
+	row := tx.QueryRow("SELECT state, rounds FROM supplemental_draft WHERE ID=? AND league=?", p.draft, p.league)
+	if err := row.Scan(&state, &rounds); err != nil {
+		return err
+	}
+	if state != "DRAFT" {
+		return errNotDrafting
+	}
+
+	var eligible int
+	row = tx.QueryRow("SELECT COUNT(*) FROM player WHERE ID=? AND "+store.RookieClause, p.player, p.league)
+	if err := row.Scan(&eligible); err != nil {
+		return err
+	}
+	if eligible == 0 {
+		return errors.New("player isn't a rookie")
+	}
+
+	_, err := tx.Exec("INSERT INTO supplemental_picks (draft, ID, player, team) VALUES (?,?,?,?)", p.draft, p.pick, p.player, p.team)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("INSERT INTO rosters (league, player, team) VALUES (?,?,?)", p.league, p.player, p.team)
+	if err != nil {
+		return err
+	}
+
+	var picks int64
+	row = tx.QueryRow("SELECT COUNT(*), (SELECT COUNT(*) FROM supplemental_order WHERE draft=?) FROM supplemental_picks WHERE draft=?", p.draft, p.draft)
+	if err = row.Scan(&picks, &teams); err != nil {
+		return err
+	}
+	if picks >= rounds*teams {
+		_, err = tx.Exec("UPDATE supplemental_draft SET state='COMPLETE' WHERE ID=?", p.draft)
+	}
+	return err
+}
+
+//createSupplemental sets up a supplemental draft for the commissioner.  We don't keep standings yet, so the
+//commissioner passes them along, best team first, and we flip them for the draft order.
+func createSupplemental(c *gin.Context, repos store.Repos) {
+	db := repos.DB
+	type SupplementalBody struct {
+		League    int64   `json:"league"`
+		Rounds    int     `json:"rounds"`
+		Standings []int64 `json:"standings"`
+	}
+	var b SupplementalBody
+	if err := c.BindJSON(&b); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var state string
+	var season int
+	row := db.QueryRow("SELECT state, season FROM league WHERE ID=?", b.League)
+	if err := row.Scan(&state, &season); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	if state == "INIT" || state == "PREDRAFT" {
+		c.JSON(http.StatusBadRequest, "League needs to hold its main draft first")
+		return
+	}
+	if b.Rounds < 1 {
+		c.JSON(http.StatusBadRequest, "Supplemental draft needs at least one round")
+		return
+	}
+
+	//Standings have to list each team in the league exactly once.
+	var teamCount int
+	row = db.QueryRow("SELECT COUNT(*) FROM teams WHERE league=?", b.League)
+	if err := row.Scan(&teamCount); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	seen := make(map[int64]bool)
+	for _, t := range b.Standings {
+		var exists int
+		row = db.QueryRow("SELECT COUNT(*) FROM teams WHERE league=? AND ID=?", b.League, t)
+		if err := row.Scan(&exists); err != nil {
+			c.JSON(http.StatusBadRequest, err.Error())
+			return
+		}
+		if exists == 0 || seen[t] {
+			c.JSON(http.StatusBadRequest, "Bad standings")
+			return
+		}
+		seen[t] = true
+	}
+	if len(seen) != teamCount {
+		c.JSON(http.StatusBadRequest, "Standings must include every team")
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	defer tx.Rollback()
+
+	draft, err := store.InsertID(tx, "INSERT INTO supplemental_draft (league, season, rounds) VALUES (?,?,?)", b.League, season, b.Rounds)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	for i := range b.Standings {
+		//Worst team picks first
+		team := b.Standings[len(b.Standings)-1-i]
+		_, err = tx.Exec("INSERT INTO supplemental_order (draft, team, slot) VALUES (?,?,?)", draft, team, i+1)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"draft": draft})
+}
+
+//startSupplemental opens a supplemental draft for picks.
+func startSupplemental(c *gin.Context, repos store.Repos) {
+	db := repos.DB
+	type StartBody struct {
+		League int64 `json:"league"`
+		Draft  int64 `json:"draft"`
+	}
+	var b StartBody
+	if err := c.BindJSON(&b); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result, err := db.Exec("UPDATE supplemental_draft SET state='DRAFT' WHERE ID=? AND league=? AND state='PREDRAFT'", b.Draft, b.League)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		c.JSON(http.StatusBadRequest, "Draft can't be started")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"state": "DRAFT"})
+}
+
+//getSupplemental returns a league's supplemental drafts, with their order and picks so far.
+func getSupplemental(c *gin.Context, repos store.Repos) {
+	db := repos.DB
+	league := access(c).League
+
+	drafts := make([]supplementalDraft, 0)
+	rows, err := db.Query("SELECT ID, season, rounds, state FROM supplemental_draft WHERE league=? ORDER BY ID", league)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var d supplementalDraft
+		if err = rows.Scan(&d.ID, &d.Season, &d.Rounds, &d.State); err != nil {
+			c.JSON(http.StatusBadRequest, err.Error())
+			return
+		}
+		drafts = append(drafts, d)
+	}
+
+	for i := range drafts {
+		board, err := loadSupplementalBoard(db, league, drafts[i].ID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, err.Error())
+			return
+		}
+		drafts[i].Order = make([]int64, 0)
+		for _, t := range board.teams {
+			drafts[i].Order = append(drafts[i].Order, t.ID)
+		}
+		drafts[i].Picks = make([]draftSlot, 0)
+		picks, err := db.Query("SELECT ID, player, team FROM supplemental_picks WHERE draft=? ORDER BY ID", drafts[i].ID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, err.Error())
+			return
+		}
+		for picks.Next() {
+			var d draftSlot
+			if err = picks.Scan(&d.Slot, &d.Player, &d.Team); err != nil {
+				picks.Close()
+				c.JSON(http.StatusBadRequest, err.Error())
+				return
+			}
+			drafts[i].Picks = append(drafts[i].Picks, d)
+		}
+		picks.Close()
+	}
+	c.JSON(http.StatusOK, drafts)
+}
@@ -7,8 +7,20 @@ const BS_WARNING = '#ffc107aa'
 const BS_PRIMARY = '#0d6efdaa'
 const BS_SECONDARY = '#6c757daa'
 
+// Managers who are active or idle are still at their draft, away or disconnected ones aren't.
+function present (state) {
+  return state === 'active' || state === 'idle'
+}
+
 // History = [{Slot: int, Player: ID, Team: ID}]
 
+// The pick on the clock is the first slot nobody has filled.  Keepers fill their slots ahead of time, so that isn't
+// always the number of picks made.
+function nextOpenPick (history) {
+  const open = history.find(p => p.Player == null)
+  return open ? open.Slot : history.length
+}
+
 function Draft (props) {
   const [draftPool, setDraftPool] = useState([])
   const [availablePlayers, setAvailablePlayers] = useState([])
@@ -20,25 +32,56 @@ function Draft (props) {
   const [userStatus, setUserStatus] = useState([])
   const [loading, setLoading] = useState(true)
   const [chat, setChat] = useState([])
+  const [chatLog, setChatLog] = useState([])
   const [smacks, setSmacks] = useState([])
   const [lastMessage, setLastMessage] = useState('')
+  const [paused, setPaused] = useState(false)
+  const [clock, setClock] = useState(null)
+  const [connection, setConnection] = useState(0)
   const draftSocket = useRef(null)
+  // The Seq of the last room event we've seen, so a new connection can ask for just what it missed.
+  const lastSeq = useRef(0)
+  const leaving = useRef(false)
   const User = useContext(UserContext)
   const Notify = useContext(NotifyContext)
 
   useEffect(() => {
     fetchDraftHistory()
     fetchDraftPool()
-    // So we're going to use a websocket to update the draft as it progresses.
+    connect()
+    const initSmack = props.teams.map(t => { return { team: t.ID, smack: '' } })
+    setSmacks(initSmack)
+    // Let the room know when we step away from the tab, and when we come back.
+    const visibility = () => {
+      if (draftSocket.current.readyState === WebSocket.OPEN) {
+        draftSocket.current.send(JSON.stringify({ Kind: 'presence', Payload: { State: document.hidden ? 'away' : 'active' } }))
+      }
+    }
+    document.addEventListener('visibilitychange', visibility)
+    return () => {
+      document.removeEventListener('visibilitychange', visibility)
+      leaving.current = true
+      draftSocket.current.close()
+    }
+  }, [])
+
+  // So we're going to use a websocket to update the draft as it progresses.  Each new socket bumps connection, so the
+  // controller below gets bound to it.
+  function connect () {
     draftSocket.current = new WebSocket(
       'ws://' +
             window.location.host +
             '/ws/draft/' +
-            props.league.ID
+            props.league.ID +
+            // The protocol version we speak, see protocol.json
+            '?v=1'
     )
-    const initSmack = props.teams.map(t => { return { team: t.ID, smack: '' } })
-    setSmacks(initSmack)
-  }, [])
+    setConnection(n => n + 1)
+  }
+
+  function sync (seq) {
+    draftSocket.current.send(JSON.stringify({ Kind: 'sync', Payload: { Seq: seq } }))
+  }
   // we wait for draftPool and DraftHistory to be filled, then load the page
   useEffect(() => {
     if (draftPool.length > 0 && draftHistory.length > 0 && userStatus !== []) {
@@ -64,24 +107,46 @@ function Draft (props) {
   // transitions from null, but we want to update this anytime we have a state change on any states we access
   // within this controller.
   useEffect(() => {
-    if (draftSocket !== null) {
+    if (draftSocket.current !== null) {
       draftSocket.current.onclose = (e) => {
-        console.log('Websocket closed unexpectedly')
+        if (!leaving.current) {
+          console.log('Websocket closed unexpectedly, reconnecting')
+          setTimeout(connect, 2000)
+        }
       }
       draftSocket.current.onopen = (e) => {
-        // When we join, all users in the room receive a status message, while the joiner gets
-        // a user list.  So we probably don't need this...
+        // When we join, all users in the room receive a status message, while the joiner gets a user list.  If we've
+        // been here before, we also ask for whatever happened in the room while we were gone.
+        if (lastSeq.current > 0) {
+          sync(lastSeq.current)
+        }
       }
       draftSocket.current.onmessage = (e) => {
         const data = JSON.parse(e.data)
+        // Room events carry a Seq, people coming and going don't.
+        if (data.Seq) {
+          lastSeq.current = data.Seq
+        }
         switch (data.Kind) {
+          // The replay is done.  It stops short when there's a lot to catch up on, so we ask for the rest.
+          case 'synced': {
+            lastSeq.current = data.Seq
+            if (data.More) {
+              sync(data.Seq)
+            }
+            break }
           // "users" is only sent upon joining a room.  it passes a list of user ids that are
           // currently in a draft instance
           case 'users': {
             // create a temp status, with all teams managers ids and an active false
-            const tempStatus = props.teams.map(team => { return { ID: team.Manager.ID, active: false } })
+            const tempStatus = props.teams.map(team => { return { ID: team.Manager.ID, active: false, state: 'disconnected' } })
             tempStatus.forEach(user => {
-              if (data.Users.includes(user.ID)) {
+              const presence = (data.Presence || []).find(p => p.User === user.ID)
+              if (presence) {
+                user.state = presence.State
+                user.active = present(presence.State)
+              } else if (data.Users.includes(user.ID)) {
+                user.state = 'active'
                 user.active = true
               }
             })
@@ -93,7 +158,9 @@ function Draft (props) {
             const tempStatus = [...userStatus]
             tempStatus.forEach(user => {
               if (user.ID === data.User) {
-                user.active = data.Active
+                // Idle managers are still around, away ones have left their draft to autopick
+                user.state = data.State || (data.Active ? 'active' : 'disconnected')
+                user.active = present(user.state)
               }
             })
             setUserStatus(tempStatus)
@@ -108,14 +175,76 @@ function Draft (props) {
             setAvailablePlayers(avail)
             setDraftHistory(history)
             shiftFocus({ context: 'default' })
-            setCurrentPick(currentPick + 1)
+            setCurrentPick(nextOpenPick(history))
             Notify(props.teams.find(t => t.ID === data.Team).Name + ' has selected ' + draftPool.find(p => p.ID === data.Player).Name, 1)
             break }
+          // The commissioner took picks back, latest first.  Their slots open up and the players go back in the pool.
+          case 'rollback': {
+            const history = [...draftHistory]
+            const avail = [...availablePlayers]
+            data.Picks.forEach(p => {
+              const slot = history.find(h => h.Slot === p.Slot)
+              if (slot) {
+                slot.Player = null
+              }
+              if (!avail.includes(p.Player)) {
+                avail.push(p.Player)
+              }
+            })
+            setDraftHistory(history)
+            setAvailablePlayers(avail)
+            setCurrentPick(nextOpenPick(history))
+            Notify('The commissioner took back ' + data.Picks.length + (data.Picks.length === 1 ? ' pick' : ' picks'), 1)
+            break }
+          case 'pause': {
+            setPaused(data.Paused)
+            Notify(data.Paused ? 'The commissioner has paused the draft' : 'The draft is back on', 1)
+            break }
+          // Whoever is on the clock, and when their time runs out.  Pausing keeps what's left, so a resume sends a new one.
+          case 'clock': {
+            setClock({ pick: data.Pick, team: data.Team, deadline: new Date(data.Deadline) })
+            setCurrentPick(data.Pick)
+            break }
           case 'chat': {
             const chatClone = [...chat]
             const team = props.teams.find(t => t.Manager.ID === data.User)
-            chatClone.push({ team: team, message: data.Payload })
+            chatClone.push({ ID: data.ID, team: team, message: data.Payload })
             setChat(chatClone)
+            // A sync can replay a message the history already gave us.
+            if (!chatLog.some(m => m.ID === data.ID)) {
+              setChatLog([...chatLog, { ID: data.ID, team: team, message: data.Payload }])
+            }
+            break }
+          // The recent chat, oldest first, which we get each time we join the room.
+          case 'history': {
+            setChatLog(data.Messages.map(m => {
+              return { ID: m.ID, team: props.teams.find(t => t.Manager.ID === m.User), message: m.Payload }
+            }))
+            break }
+          // The commissioner took a message down, so it comes out of the log, the queue and any speech bubble.
+          case 'chatDeleted': {
+            setChatLog(chatLog.filter(m => m.ID !== data.ID))
+            setChat(chat.filter(m => m.ID !== data.ID))
+            const tempSmacks = [...smacks]
+            tempSmacks.forEach(s => {
+              if (s.ID === data.ID) {
+                s.smack = ''
+              }
+            })
+            setSmacks(tempSmacks)
+            break }
+          case 'mute': {
+            if (data.User === User.ID) {
+              Notify(data.Muted ? "You've been muted for this draft" : 'You can chat again', data.Muted ? 0 : 1)
+            } else {
+              const team = props.teams.find(t => t.Manager.ID === data.User)
+              const name = team ? team.Name : 'A manager'
+              Notify(name + (data.Muted ? ' has been muted' : ' can chat again'), 1)
+            }
+            break }
+          // The server couldn't use something we sent, or it didn't go through.  Codes are in protocol.json
+          case 'error': {
+            Notify(data.Message, 0)
             break }
           default: {
             console.log('sent ' + data.Kind + ' message type, why did you do that?')
@@ -123,7 +252,7 @@ function Draft (props) {
         }
       }
     }
-  }, [draftSocket, draftHistory, availablePlayers, userStatus, currentPick, chat])
+  }, [connection, draftHistory, availablePlayers, userStatus, currentPick, chat, chatLog, smacks, paused, clock])
 
   // To start, we want to fetch our draft history and draft class.  While these could be gathered from
   // our initial websocket connection or as a single request, I can see a scenario where we want to have
@@ -135,23 +264,26 @@ function Draft (props) {
       const data = await response.json()
 
       if (response.ok) {
-        const history = data.map(p => p)
-        setCurrentPick(history.length)
-        // We'll pass an empty or incomplete list of picks.  We want to then expand the array
-        // to hold all potential picks in the future.
-        if (history.length !== props.settings.draft.Rounds * props.teams.length) {
-          const snakeFirst = [...props.teams].sort((a, b) => a.Slot - b.Slot)
-          const snakeSecond = [...props.teams].sort((a, b) => b.Slot - a.Slot)
-          const draftLength = props.settings.draft.Rounds * props.teams.length
-          for (let i = history.length; i < draftLength; i++) {
-            const roundPick = i % props.teams.length
-            if (Math.floor(i / props.teams.length) % 2 === 0) {
-              history.push({ Player: null, Slot: i, Team: snakeFirst[roundPick].ID })
-            } else {
-              history.push({ Player: null, Slot: i, Team: snakeSecond[roundPick].ID })
-            }
+        // We get the picks made so far, which aren't always the first ones since keepers sit in later slots.  The
+        // board holds every pick in the draft, by slot, with the made ones filled in.
+        const snakeFirst = [...props.teams].sort((a, b) => a.Slot - b.Slot)
+        const snakeSecond = [...props.teams].sort((a, b) => b.Slot - a.Slot)
+        const draftLength = props.settings.draft.Rounds * props.teams.length
+        const history = []
+        for (let i = 0; i < draftLength; i++) {
+          const roundPick = i % props.teams.length
+          if (Math.floor(i / props.teams.length) % 2 === 0) {
+            history.push({ Player: null, Slot: i, Team: snakeFirst[roundPick].ID })
+          } else {
+            history.push({ Player: null, Slot: i, Team: snakeSecond[roundPick].ID })
           }
         }
+        data.forEach(p => {
+          if (p.Slot < draftLength) {
+            history[p.Slot] = p
+          }
+        })
+        setCurrentPick(nextOpenPick(history))
         setDraftHistory(history)
       } else {
         Notify('Failed to fetch history', 0)
@@ -274,6 +406,7 @@ function Draft (props) {
       tempChat.shift()
       const newSmack = tempSmacks.find(s => s.team === lastMessage.team.ID)
       newSmack.smack = lastMessage.message
+      newSmack.ID = lastMessage.ID
       setSmacks(tempSmacks)
       setChat(tempChat)
       setLastMessage('')
@@ -337,6 +470,7 @@ function Draft (props) {
   return (
         <div className='text-center'>
           <h1 className='display-4'>{props.league.name} Draft</h1>
+          <DraftClock clock={clock} paused={paused} teams={props.teams}/>
           <div className='row m-2 g-1'>
             <div className='col-8'>
               <DraftBoard
@@ -357,6 +491,7 @@ function Draft (props) {
                 shiftFocus={shiftFocus}
                 smacks={smacks}
                 rounds={props.settings.draft.Rounds}/>
+              <ChatLog messages={chatLog}/>
             </div>
           </div>
           <DraftPool
@@ -998,6 +1133,30 @@ function SlotBox (props) {
 // across the chat display area.  Once the animation ends, we pull the next messages on the queue, otherwise
 // we'll just replay the animation until new chat messages are submitted.  Should be good for moments someone picking
 // a kicker/defense too early, and I don't know if you miss much not being able to hold a regular discussion in a draft.
+// Counts down the pick on the clock.  While the draft is paused the clock stands still, so we just say so.
+function DraftClock (props) {
+  const [now, setNow] = useState(Date.now())
+
+  useEffect(() => {
+    const tick = setInterval(() => setNow(Date.now()), 1000)
+    return () => clearInterval(tick)
+  }, [])
+
+  if (props.paused) {
+    return (<p className='lead text-warning'>Draft paused</p>)
+  }
+  if (props.clock === null) {
+    return null
+  }
+  const team = props.teams.find(t => t.ID === props.clock.team)
+  const left = Math.max(0, Math.floor((props.clock.deadline - now) / 1000))
+  return (
+    <p className='lead'>
+      {team ? team.Name : 'Pick ' + (props.clock.pick + 1)} on the clock, {Math.floor(left / 60)}:{String(left % 60).padStart(2, '0')}
+    </p>
+  )
+}
+
 function DraftChat (props) {
   const [message, setMessage] = useState('')
 
@@ -1032,6 +1191,19 @@ function DraftChat (props) {
   )
 }
 
+// Everything said in the room so far, where the speech bubbles only hold each team's latest.
+function ChatLog (props) {
+  return (
+    <ul className='list-group list-group-flush text-start mt-2'>
+      {props.messages.map(m =>
+        <li key={m.ID} className='list-group-item small'>
+          <strong>{m.team ? m.team.Name : 'Commissioner'}:</strong> {m.message}
+        </li>
+      )}
+    </ul>
+  )
+}
+
 // If we're going to incorporate a transition, we want to run it on as simple a component as possible.
 function ChatHighlight (props) {
   useEffect(() => {
@@ -538,7 +538,7 @@ function DraftSettings (props) {
   const Notify = useContext(NotifyContext)
 
   // We should Identify which keys need which type of inputs.
-  const selects = ['Kind', 'DraftOrder']
+  const selects = ['Kind', 'DraftOrder', 'Pace']
   const times = ['Time']
   const numbers = ['Rounds', 'DraftClock']
   // And we need to identify our keys for draft and positional.
@@ -680,6 +680,11 @@ function DraftSettings (props) {
             selectMeat = [
               <option key="TRAD" value="TRAD">Traditional</option>,
               <option key="AUCTION" value="AUCTION">Auction</option>]
+          } else if (key === 'Pace') {
+            // Slow drafts run over days, with the draft clock in hours
+            selectMeat = [
+              <option key="LIVE" value="LIVE">Live</option>,
+              <option key="SLOW" value="SLOW">Slow</option>]
           } else {
             selectMeat = [
             <option key="SNAKE" value="SNAKE">Snake</option>,
@@ -2,6 +2,15 @@
 import React, { useState, useContext } from 'react'
 import { NotifyContext, csrftoken } from './util.js'
 
+// Pages that need a login, like an invite link, send people here with where to go next.  Only paths on this site
+// count, so a link can't bounce someone somewhere else.
+function returnTo () {
+  const next = new URLSearchParams(window.location.search).get('next')
+  if (next && next.startsWith('/') && !next.startsWith('//')) {
+    window.location.assign(next)
+  }
+}
+
 function LoginForm (props) {
   const [username, setUsername] = useState('')
   const [password, setPassword] = useState('')
@@ -26,6 +35,7 @@ function LoginForm (props) {
       if (response.ok) {
         const userObj = { ID: data.ID, name: data.name, email: data.email }
         props.onLogin(userObj)
+        returnTo()
       } else {
         Notify(data, 0)
       }
@@ -103,6 +113,7 @@ function RegisterForm (props) {
       if (response.ok) {
         const userObj = { ID: data.ID, name: data.name, email: data.email }
         props.onRegister(userObj)
+        returnTo()
       } else {
         Notify(data, 0)
       }
@@ -0,0 +1,94 @@
+package store
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+)
+
+//We run on MySQL, PostgreSQL or SQLite.  Queries are written the way MySQL likes them, with ? placeholders, and on
+//postgres the driver in postgres.go rewrites them as they go out.  Most of what's left differs little enough that
+//portable SQL does the job (COALESCE, TRUE and FALSE), and the helpers here cover the rest: getting an inserted ID
+//back, and inserts that skip or update rows that are already there.  The schema differs more, so each dialect gets
+//its own migrations.
+
+type Dialect string
+
+const (
+	MySQL    Dialect = "mysql"
+	Postgres Dialect = "postgres"
+	SQLite   Dialect = "sqlite"
+)
+
+//dialect is whatever ConnectDB connected to.
+var dialect = MySQL
+
+//CurrentDialect is the dialect of the database from ConnectDB.
+func CurrentDialect() Dialect {
+	return dialect
+}
+
+//Execer is a *sql.DB or a *sql.Tx.
+type Execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+//InsertID runs an insert into a table with an auto incrementing ID, and returns the ID it got.  Postgres doesn't do
+//LastInsertId, so there we ask for the ID back instead.
+func InsertID(e Execer, query string, args ...interface{}) (int64, error) {
+	if dialect == Postgres {
+		var ID int64
+		err := e.QueryRow(query+" RETURNING ID", args...).Scan(&ID)
+		return ID, err
+	}
+	res, err := e.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+//InsertIgnore turns "INTO table (columns) VALUES (...)" into an insert that does nothing when the row would clash
+//with one already there.
+func InsertIgnore(insert string) string {
+	switch dialect {
+	case Postgres:
+		return "INSERT " + insert + " ON CONFLICT DO NOTHING"
+	case SQLite:
+		return "INSERT OR IGNORE " + insert
+	}
+	return "INSERT IGNORE " + insert
+}
+
+//Upsert turns "INTO table (columns) VALUES (...)" into an insert that updates columns instead when a row with the
+//same keys is already there.
+func Upsert(insert string, keys []string, columns []string) string {
+	set := make([]string, len(columns))
+	if dialect == MySQL {
+		for i, c := range columns {
+			set[i] = c + "=VALUES(" + c + ")"
+		}
+		return "INSERT " + insert + " ON DUPLICATE KEY UPDATE " + strings.Join(set, ", ")
+	}
+	for i, c := range columns {
+		set[i] = c + "=excluded." + c
+	}
+	return "INSERT " + insert + " ON CONFLICT (" + strings.Join(keys, ", ") + ") DO UPDATE SET " + strings.Join(set, ", ")
+}
+
+//IsDuplicate tells whether err is an insert or update running into a primary key or unique column.
+func IsDuplicate(err error) bool {
+	switch e := err.(type) {
+	case *mysql.MySQLError:
+		return e.Number == 1062
+	case *pq.Error:
+		return e.Code == "23505"
+	case sqlite3.Error:
+		return e.ExtendedCode == sqlite3.ErrConstraintPrimaryKey || e.ExtendedCode == sqlite3.ErrConstraintUnique
+	}
+	return false
+}
@@ -0,0 +1,748 @@
+package store
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PhiloTFarnsworth/FantasySportsAF/store/scanners"
+)
+
+//Memory keeps everything the repositories do in maps, for trying handlers out without a database.  It starts out
+//empty, with no players, so anything that wants a player pool has to be given one with AddPlayer.  Settings start
+//at the same defaults as the migrations give them.
+type Memory struct {
+	mu       sync.Mutex
+	users    []User
+	leagues  []League
+	teams    map[int64][]Team
+	managers map[int64][]int64
+	invites  map[int64][]User
+	settings map[int64]FullDraftSettings
+	picks    map[int64][]Pick
+	keepers  map[int64][]Keeper
+	rosters  map[int64][]int64
+	roles    map[int64][]LeagueRole
+	offers   map[int64]Transfer
+	history  map[int64][]RoleChange
+	players  []scanners.Player
+	//each player's first season of stats.  Rookies are the players without one before the league's season.
+	debuts map[int64]int
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		teams:    make(map[int64][]Team),
+		managers: make(map[int64][]int64),
+		invites:  make(map[int64][]User),
+		settings: make(map[int64]FullDraftSettings),
+		picks:    make(map[int64][]Pick),
+		keepers:  make(map[int64][]Keeper),
+		rosters:  make(map[int64][]int64),
+		roles:    make(map[int64][]LeagueRole),
+		offers:   make(map[int64]Transfer),
+		history:  make(map[int64][]RoleChange),
+		debuts:   make(map[int64]int),
+	}
+}
+
+//Repos hands out the repositories, all sharing the one Memory.
+func (m *Memory) Repos() Repos {
+	return Repos{
+		Users:    memoryUsers{m},
+		Leagues:  memoryLeagues{m},
+		Teams:    memoryTeams{m},
+		Invites:  memoryInvites{m},
+		Settings: memorySettings{m},
+		Drafts:   memoryDrafts{m},
+		Players:  memoryPlayers{m},
+		Roles:    memoryRoles{m},
+	}
+}
+
+//AddPlayer puts a player in the pool.  A debut of 0 means we don't know their first season.
+func (m *Memory) AddPlayer(p scanners.Player, debut int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.players = append(m.players, p)
+	sort.Slice(m.players, func(i, j int) bool { return m.players[i].ID < m.players[j].ID })
+	if debut != 0 {
+		m.debuts[p.ID] = debut
+	}
+}
+
+//AddKeeper sets a team to keep a player when the draft starts.
+func (m *Memory) AddKeeper(league int64, k Keeper) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.keepers[league] = append(m.keepers[league], k)
+}
+
+//AddToRoster puts a player on a team without drafting them.
+func (m *Memory) AddToRoster(league int64, player int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rosters[league] = append(m.rosters[league], player)
+}
+
+func (m *Memory) user(ID int64) (User, bool) {
+	if ID < 1 || int(ID) > len(m.users) {
+		return User{}, false
+	}
+	u := m.users[ID-1]
+	u.Passhash = nil
+	return u, true
+}
+
+func (m *Memory) league(ID int64) (*League, bool) {
+	if ID < 1 || int(ID) > len(m.leagues) {
+		return nil, false
+	}
+	return &m.leagues[ID-1], true
+}
+
+func (m *Memory) summary(l League) LeagueSummary {
+	return LeagueSummary{ID: l.ID, Name: l.Name, Commissioner: l.Commissioner.Name}
+}
+
+func defaultSettings(ID int) FullDraftSettings {
+	var f FullDraftSettings
+	f.D = DraftSettings{ID: ID, Kind: "TRAD", DraftOrder: "SNAKE", Rounds: 15, Pace: "LIVE"}
+	f.P = scanners.PositionalSettings{ID: ID, Kind: "TRAD", QB: 1, RB: 2, WR: 2, TE: 1, Flex: 1, Bench: 6, Def: 1, K: 1}
+	f.S.O = scanners.ScoringSettingsOff{ID: ID, PassYard: 0.04, PassTouchdown: 6, PassInterception: -3, RushYard: 0.1,
+		RushTouchdown: 6, ReceivingYard: 0.1, ReceivingTouchdown: 6, Fumble: -1, FumbleLost: -2, MiscTouchdown: 6,
+		TwoPointConversion: 2, TwoPointPass: 2}
+	f.S.D = scanners.ScoringSettingDef{ID: ID, Touchdown: 6, Sack: 1, Interception: 3, Safety: 2, Shutout: 10, Points6: 7,
+		Points13: 4, Points20: 1, Points34: -1, Points35: -4, YardBonus: 3, Yards: -0.01}
+	f.S.S = scanners.ScoringSettingsSpe{ID: ID, Fg29: 3, Fg39: 3, Fg49: 3, Fg50: 3, ExtraPoint: 1}
+	return f
+}
+
+type memoryUsers struct{ m *Memory }
+
+func (r memoryUsers) ByID(ID int64) (User, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	if u, ok := r.m.user(ID); ok {
+		return u, nil
+	}
+	return User{}, ErrNotFound
+}
+
+func (r memoryUsers) ByName(name string) (User, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	for _, u := range r.m.users {
+		if u.Name == name {
+			return u, nil
+		}
+	}
+	return User{}, ErrNotFound
+}
+
+func (r memoryUsers) Create(name string, email string, passhash []byte) (User, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	//Names and emails are unique on the user table too.
+	for _, u := range r.m.users {
+		if u.Name == name || u.Email == email {
+			return User{}, fmt.Errorf("user %s already exists", name)
+		}
+	}
+	u := User{ID: int64(len(r.m.users) + 1), Name: name, Email: email, Passhash: passhash}
+	r.m.users = append(r.m.users, u)
+	for league, invites := range r.m.invites {
+		for i, v := range invites {
+			if v.ID == 0 && v.Email == email {
+				r.m.invites[league][i] = User{ID: u.ID, Name: u.Name, Email: u.Email}
+			}
+		}
+	}
+	u.Passhash = nil
+	return u, nil
+}
+
+func (r memoryUsers) SetPassword(ID int64, passhash []byte) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	if _, ok := r.m.user(ID); !ok {
+		return ErrNotFound
+	}
+	r.m.users[ID-1].Passhash = passhash
+	return nil
+}
+
+func (r memoryUsers) SetAdmin(ID int64, admin bool) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	if _, ok := r.m.user(ID); !ok {
+		return ErrNotFound
+	}
+	r.m.users[ID-1].Admin = admin
+	return nil
+}
+
+type memoryLeagues struct{ m *Memory }
+
+func (r memoryLeagues) Create(name string, commissioner int64, maxOwner int64, season int, team string) (int64, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	commish, ok := r.m.user(commissioner)
+	if !ok {
+		return 0, ErrNotFound
+	}
+	ID := int64(len(r.m.leagues) + 1)
+	r.m.leagues = append(r.m.leagues, League{ID: ID, Name: name, Commissioner: commish, State: "INIT",
+		MaxOwner: maxOwner, Kind: "TRAD", Season: season})
+	r.m.settings[ID] = defaultSettings(int(ID))
+	r.m.addTeam(ID, team, commish)
+	return ID, nil
+}
+
+func (m *Memory) addTeam(league int64, name string, manager User) {
+	m.teams[league] = append(m.teams[league], Team{ID: int64(len(m.teams[league]) + 1), Name: name, Manager: manager})
+	m.managers[manager.ID] = append(m.managers[manager.ID], league)
+}
+
+func (r memoryLeagues) Get(ID int64) (League, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	if l, ok := r.m.league(ID); ok {
+		return *l, nil
+	}
+	return League{}, ErrNotFound
+}
+
+func (r memoryLeagues) Managed(user int64) ([]LeagueSummary, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	var leagues []LeagueSummary
+	for _, ID := range r.m.managers[user] {
+		l, _ := r.m.league(ID)
+		leagues = append(leagues, r.m.summary(*l))
+	}
+	sort.Slice(leagues, func(i, j int) bool { return leagues[i].ID < leagues[j].ID })
+	return leagues, nil
+}
+
+func (r memoryLeagues) Invited(user int64) ([]LeagueSummary, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	var leagues []LeagueSummary
+	for _, l := range r.m.leagues {
+		for _, v := range r.m.invites[l.ID] {
+			if v.ID == user {
+				leagues = append(leagues, r.m.summary(l))
+			}
+		}
+	}
+	return leagues, nil
+}
+
+func (r memoryLeagues) Update(s LeagueSettings) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	l, ok := r.m.league(s.ID)
+	if !ok {
+		return ErrNotFound
+	}
+	if s.MaxOwner < int64(len(r.m.teams[s.ID])) {
+		return ErrTooManyTeams
+	}
+	l.Name, l.MaxOwner, l.Kind = s.Name, s.MaxOwner, s.Kind
+	return nil
+}
+
+func (r memoryLeagues) SetState(ID int64, state string) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	if l, ok := r.m.league(ID); ok {
+		l.State = state
+	}
+	return nil
+}
+
+type memoryTeams struct{ m *Memory }
+
+func (r memoryTeams) List(league int64) ([]Team, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	return append([]Team(nil), r.m.teams[league]...), nil
+}
+
+func (r memoryTeams) Get(league int64, ID int64) (Team, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	teams := r.m.teams[league]
+	if ID < 1 || int(ID) > len(teams) {
+		return Team{}, ErrNotFound
+	}
+	return teams[ID-1], nil
+}
+
+func (r memoryTeams) Rename(league int64, ID int64, name string) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	teams := r.m.teams[league]
+	if ID >= 1 && int(ID) <= len(teams) {
+		teams[ID-1].Name = name
+	}
+	return nil
+}
+
+func (r memoryTeams) Join(league int64, name string, manager int64) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	l, ok := r.m.league(league)
+	if !ok {
+		return ErrNotFound
+	}
+	u, ok := r.m.user(manager)
+	if !ok {
+		return ErrNotFound
+	}
+	if int64(len(r.m.teams[league])) >= l.MaxOwner {
+		return ErrLeagueFull
+	}
+	for _, t := range r.m.teams[league] {
+		if t.Manager.ID == manager {
+			return fmt.Errorf("user %d already has a team in league %d", manager, league)
+		}
+	}
+	r.m.addTeam(league, name, u)
+	r.m.dropInvite(league, func(v User) bool { return v.ID == manager })
+	return nil
+}
+
+func (m *Memory) dropInvite(league int64, match func(User) bool) {
+	kept := m.invites[league][:0]
+	for _, v := range m.invites[league] {
+		if !match(v) {
+			kept = append(kept, v)
+		}
+	}
+	m.invites[league] = kept
+}
+
+type memoryInvites struct{ m *Memory }
+
+func (r memoryInvites) Invite(league int64, email string) (User, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	invitee := User{Name: email, Email: email}
+	for _, u := range r.m.users {
+		if u.Email == email {
+			invitee = User{ID: u.ID, Name: u.Name, Email: u.Email}
+		}
+	}
+	for _, v := range r.m.invites[league] {
+		if v.ID == invitee.ID && v.Email == invitee.Email {
+			return invitee, fmt.Errorf("%s is already invited", email)
+		}
+	}
+	r.m.invites[league] = append(r.m.invites[league], invitee)
+	if invitee.ID == 0 {
+		invitee.Name = ""
+	}
+	return invitee, nil
+}
+
+func (r memoryInvites) Revoke(league int64, email string) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	r.m.dropInvite(league, func(v User) bool { return v.Email == email })
+	return nil
+}
+
+func (r memoryInvites) List(league int64) ([]User, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	//Registered users first, same as MySQL.
+	var invites []User
+	for _, registered := range []bool{true, false} {
+		for _, v := range r.m.invites[league] {
+			if (v.ID != 0) == registered {
+				invites = append(invites, v)
+			}
+		}
+	}
+	return invites, nil
+}
+
+type memorySettings struct{ m *Memory }
+
+func (r memorySettings) Draft(league int64) (FullDraftSettings, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	f, ok := r.m.settings[league]
+	if !ok {
+		return f, ErrNotFound
+	}
+	return f, nil
+}
+
+func (r memorySettings) SaveDraft(f FullDraftSettings) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	//Each table goes by its own ID, so that's where each part lands.
+	save := func(ID int, change func(*FullDraftSettings)) {
+		if s, ok := r.m.settings[int64(ID)]; ok {
+			change(&s)
+			r.m.settings[int64(ID)] = s
+		}
+	}
+	save(f.P.ID, func(s *FullDraftSettings) { s.P = f.P })
+	save(f.S.O.ID, func(s *FullDraftSettings) { s.S.O = f.S.O })
+	save(f.S.D.ID, func(s *FullDraftSettings) { s.S.D = f.S.D })
+	save(f.S.S.ID, func(s *FullDraftSettings) { s.S.S = f.S.S })
+	save(f.D.ID, func(s *FullDraftSettings) { s.D = f.D })
+	return nil
+}
+
+func (r memorySettings) Scoring(league int64) (ScoringSettingsTotal, error) {
+	f, err := r.Draft(league)
+	return f.S, err
+}
+
+type memoryDrafts struct{ m *Memory }
+
+func (r memoryDrafts) Picks(league int64) ([]Pick, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	return append(make([]Pick, 0), r.m.picks[league]...), nil
+}
+
+func (r memoryDrafts) Keepers(league int64) ([]Keeper, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	return append([]Keeper(nil), r.m.keepers[league]...), nil
+}
+
+func (r memoryDrafts) Start(league int64, order []TeamSlot, keepers []Pick) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	l, ok := r.m.league(league)
+	if !ok {
+		return ErrNotFound
+	}
+	teams := r.m.teams[league]
+	for _, o := range order {
+		if o.Team >= 1 && int(o.Team) <= len(teams) {
+			teams[o.Team-1].Slot = o.Slot
+		}
+	}
+	r.m.picks[league] = append(r.m.picks[league], keepers...)
+	sort.Slice(r.m.picks[league], func(i, j int) bool { return r.m.picks[league][i].Slot < r.m.picks[league][j].Slot })
+	l.State = "DRAFT"
+	return nil
+}
+
+func (r memoryDrafts) Reset(league int64) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	l, ok := r.m.league(league)
+	if !ok {
+		return ErrNotFound
+	}
+	delete(r.m.picks, league)
+	for i := range r.m.teams[league] {
+		r.m.teams[league][i].Slot = 0
+	}
+	l.State = "PREDRAFT"
+	return nil
+}
+
+type memoryPlayers struct{ m *Memory }
+
+func (r memoryPlayers) Get(ID int64) (scanners.Player, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	for _, p := range r.m.players {
+		if p.ID == ID {
+			return p, nil
+		}
+	}
+	return scanners.Player{}, ErrNotFound
+}
+
+func (r memoryPlayers) Pool(q PoolQuery) ([]scanners.Player, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	taken := make(map[int64]bool)
+	var season int
+	if q.League != 0 {
+		for _, p := range r.m.picks[q.League] {
+			taken[p.Player] = true
+		}
+		for _, p := range r.m.rosters[q.League] {
+			taken[p] = true
+		}
+		if l, ok := r.m.league(q.League); ok {
+			season = l.Season
+		}
+	}
+
+	field := "ID"
+	for _, c := range scanners.PlayerColumns {
+		if c.Column == q.Sort {
+			field = c.Field
+		}
+	}
+	//before is the order the pool comes out in, with ties broken on ID.
+	before := func(a sortKey, aID int64, b sortKey, bID int64) bool {
+		if c := a.compare(b); c != 0 {
+			return (c < 0) != q.Desc
+		}
+		return (aID < bID) != q.Desc
+	}
+
+	var pool []scanners.Player
+	for _, p := range r.m.players {
+		switch {
+		case q.Position != "" && p.Position != q.Position,
+			q.Team != "" && p.Team != q.Team,
+			q.Search != "" && !strings.Contains(strings.ToLower(p.Name), strings.ToLower(q.Search)),
+			taken[p.ID],
+			q.Rookies && r.m.debuts[p.ID] != 0 && r.m.debuts[p.ID] < season,
+			q.After != nil && !before(toSortKey(q.After.Value), q.After.ID, playerKey(p, field), p.ID):
+			continue
+		}
+		pool = append(pool, p)
+	}
+	sort.SliceStable(pool, func(i, j int) bool {
+		return before(playerKey(pool[i], field), pool[i].ID, playerKey(pool[j], field), pool[j].ID)
+	})
+	if q.Limit > 0 && len(pool) > q.Limit {
+		pool = pool[:q.Limit]
+	}
+	return pool, nil
+}
+
+//Stats is always empty, since Memory doesn't keep stats.
+func (r memoryPlayers) Stats(ID int64) ([]scanners.PlayerStats, []scanners.PlayerStats, error) {
+	return make([]scanners.PlayerStats, 0), make([]scanners.PlayerStats, 0), nil
+}
+
+//Owners only finds drafted players, since AddToRoster doesn't say which team a player is on.
+func (r memoryPlayers) Owners(user int64, ID int64) ([]Ownership, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	owners := make([]Ownership, 0)
+	for _, league := range r.m.managers[user] {
+		l, _ := r.m.league(league)
+		o := Ownership{League: l.ID, Name: l.Name}
+		for _, p := range r.m.picks[league] {
+			if p.Player != ID {
+				continue
+			}
+			for _, t := range r.m.teams[league] {
+				if t.ID == p.Team {
+					o.Team, o.TeamName = t.ID, t.Name
+				}
+			}
+		}
+		owners = append(owners, o)
+	}
+	sort.Slice(owners, func(i, j int) bool { return owners[i].League < owners[j].League })
+	return owners, nil
+}
+
+//sortKey is a player field as something we can compare, whatever it started as.  Cursors come back from JSON, so
+//numbers there are all float64.
+type sortKey struct {
+	number float64
+	text   string
+}
+
+func (k sortKey) compare(o sortKey) int {
+	switch {
+	case k.text < o.text, k.text == o.text && k.number < o.number:
+		return -1
+	case k.text == o.text && k.number == o.number:
+		return 0
+	}
+	return 1
+}
+
+func playerKey(p scanners.Player, field string) sortKey {
+	return toSortKey(reflect.ValueOf(p).FieldByName(field).Interface())
+}
+
+func toSortKey(v interface{}) sortKey {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return sortKey{number: float64(rv.Int())}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return sortKey{number: float64(rv.Uint())}
+	case reflect.Float32, reflect.Float64:
+		return sortKey{number: rv.Float()}
+	case reflect.String:
+		return sortKey{text: rv.String()}
+	}
+	return sortKey{}
+}
+
+type memoryRoles struct{ m *Memory }
+
+func (r memoryRoles) Member(league int64, user int64) (Member, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	m := Member{League: league, User: user}
+	l, ok := r.m.league(league)
+	if !ok {
+		return m, ErrNotFound
+	}
+	m.Commissioner = l.Commissioner.ID == user
+	if u, ok := r.m.user(user); ok {
+		m.Admin = u.Admin
+	}
+	for _, t := range r.m.teams[league] {
+		if t.Manager.ID == user {
+			m.Team = t.ID
+		}
+	}
+	for _, v := range r.m.invites[league] {
+		m.Invited = m.Invited || v.ID == user && user != 0
+	}
+	m.Permissions = []string{}
+	for _, g := range r.m.roles[league] {
+		if g.User.ID == user {
+			m.Role, m.Permissions = g.Role, g.Permissions
+		}
+	}
+	return m, nil
+}
+
+func (r memoryRoles) List(league int64) ([]LeagueRole, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	return append(make([]LeagueRole, 0), r.m.roles[league]...), nil
+}
+
+func (r memoryRoles) Grant(league int64, actor int64, user int64, role string, permissions []string) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	u, ok := r.m.user(user)
+	if !ok {
+		return ErrNotFound
+	}
+	permissions = append(make([]string, 0), permissions...)
+	r.m.revoke(league, user)
+	r.m.roles[league] = append(r.m.roles[league], LeagueRole{User: u, Role: role, Permissions: permissions})
+	sort.Slice(r.m.roles[league], func(i, j int) bool { return r.m.roles[league][i].User.ID < r.m.roles[league][j].User.ID })
+	r.m.logRole(league, actor, user, ActionGrant, role, permissions)
+	return nil
+}
+
+func (r memoryRoles) Revoke(league int64, actor int64, user int64) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	r.m.revoke(league, user)
+	r.m.logRole(league, actor, user, ActionRevoke, "", nil)
+	return nil
+}
+
+func (r memoryRoles) Offer(league int64, actor int64, to int64) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	l, ok := r.m.league(league)
+	if !ok {
+		return ErrNotFound
+	}
+	u, ok := r.m.user(to)
+	if !ok {
+		return ErrNotFound
+	}
+	r.m.offers[league] = Transfer{League: league, From: l.Commissioner, To: u, Created: time.Now()}
+	r.m.logRole(league, actor, to, ActionOffer, "", nil)
+	return nil
+}
+
+func (r memoryRoles) Transfer(league int64) (Transfer, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	t, ok := r.m.offers[league]
+	if !ok {
+		return Transfer{League: league}, ErrNotFound
+	}
+	return t, nil
+}
+
+//endTransfer is the memory version of the SQL one.
+func (m *Memory) endTransfer(league int64, actor int64, user int64, action string) (Transfer, error) {
+	t, ok := m.offers[league]
+	if !ok || user != 0 && t.To.ID != user {
+		return t, ErrNotFound
+	}
+	delete(m.offers, league)
+	subject := t.To.ID
+	if action == ActionAccept {
+		subject = t.From.ID
+	}
+	m.logRole(league, actor, subject, action, "", nil)
+	return t, nil
+}
+
+func (r memoryRoles) Cancel(league int64, actor int64) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	_, err := r.m.endTransfer(league, actor, 0, ActionCancel)
+	return err
+}
+
+func (r memoryRoles) Decline(league int64, user int64) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	_, err := r.m.endTransfer(league, user, user, ActionDecline)
+	return err
+}
+
+func (r memoryRoles) Accept(league int64, user int64) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	t, err := r.m.endTransfer(league, user, user, ActionAccept)
+	if err != nil {
+		return err
+	}
+	if l, ok := r.m.league(league); ok {
+		l.Commissioner = t.To
+	}
+	r.m.revoke(league, user)
+	return nil
+}
+
+func (r memoryRoles) History(league int64) ([]RoleChange, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	changes := make([]RoleChange, 0, len(r.m.history[league]))
+	for i := len(r.m.history[league]) - 1; i >= 0; i-- {
+		changes = append(changes, r.m.history[league][i])
+	}
+	return changes, nil
+}
+
+func (m *Memory) logRole(league int64, actor int64, user int64, action string, role string, permissions []string) {
+	a, _ := m.user(actor)
+	u, _ := m.user(user)
+	ID := int64(1)
+	if n := len(m.history[league]); n > 0 {
+		ID = m.history[league][n-1].ID + 1
+	}
+	m.history[league] = append(m.history[league], RoleChange{ID: ID, Actor: a, User: u, Action: action, Role: role,
+		Permissions: append(make([]string, 0), permissions...), Created: time.Now()})
+}
+
+func (m *Memory) revoke(league int64, user int64) {
+	kept := m.roles[league][:0]
+	for _, g := range m.roles[league] {
+		if g.User.ID != user {
+			kept = append(kept, g)
+		}
+	}
+	m.roles[league] = kept
+}
@@ -0,0 +1,292 @@
+package store
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//The schema is built up by numbered migrations in store/migrations, each a pair of files: NNNN_name.up.sql and
+//NNNN_name.down.sql.  Every dialect has its own directory of them, numbered the same, so a version means the same
+//schema whichever database it's on.  They're embedded in the binary, and schema_migrations records which ones a
+//database has had.  MySQL commits DDL as it goes, so a migration that fails halfway can't be rolled back.  We mark a
+//migration dirty while it runs, and refuse to go any further until someone fixes things up by hand and runs
+//ForceMigration.  Postgres and SQLite can run DDL in a transaction, so there a failed migration just never happened.
+//ForceMigration is also how a database set up before migrations gets on board.
+
+//go:embed migrations/*/*.sql
+var migrationFiles embed.FS
+
+var migrationFile = regexp.MustCompile(`^([0-9]+)_(\w+)\.(up|down)\.sql$`)
+
+//ErrDirty is returned while a migration that failed partway is waiting on ForceMigration.
+var ErrDirty = errors.New("a migration failed partway, fix the database by hand and then force the version it's at")
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+//MigrationState is a migration and where a database is with it.  Applied is zero for pending migrations.
+type MigrationState struct {
+	Migration
+	Applied time.Time
+	Dirty   bool
+}
+
+//Migrations lists every migration we have for the current dialect, oldest first.
+func Migrations() ([]Migration, error) {
+	dir := path.Join("migrations", string(dialect))
+	files, err := migrationFiles.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int]*Migration)
+	for _, f := range files {
+		m := migrationFile.FindStringSubmatch(f.Name())
+		if m == nil {
+			return nil, fmt.Errorf("badly named migration %s", f.Name())
+		}
+		version, _ := strconv.Atoi(m[1])
+		raw, err := migrationFiles.ReadFile(path.Join(dir, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		mig := byVersion[version]
+		if mig == nil {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		} else if mig.Name != m[2] {
+			return nil, fmt.Errorf("migration %d is called both %s and %s", version, mig.Name, m[2])
+		}
+		if m[3] == "up" {
+			mig.Up = string(raw)
+		} else {
+			mig.Down = string(raw)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d needs both an up and a down file", m.Version)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+func migrationTable(db *sql.DB) error {
+	timestamp := "DATETIME"
+	if dialect == Postgres {
+		timestamp = "TIMESTAMPTZ"
+	}
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INT NOT NULL PRIMARY KEY,
+		name VARCHAR(128) NOT NULL,
+		applied ` + timestamp + ` NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		dirty BOOLEAN NOT NULL DEFAULT FALSE)`)
+	return err
+}
+
+//MigrationStatus lines up every migration we know of with what the database has recorded.
+func MigrationStatus(db *sql.DB) ([]MigrationState, error) {
+	if err := migrationTable(db); err != nil {
+		return nil, err
+	}
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	states := make(map[int]*MigrationState)
+	list := make([]MigrationState, 0, len(migrations))
+	for _, m := range migrations {
+		list = append(list, MigrationState{Migration: m})
+	}
+	for i := range list {
+		states[list[i].Version] = &list[i]
+	}
+
+	rows, err := db.Query("SELECT version, name, applied, dirty FROM schema_migrations ORDER BY version")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var unknown []MigrationState
+	for rows.Next() {
+		var s MigrationState
+		if err = rows.Scan(&s.Version, &s.Name, &s.Applied, &s.Dirty); err != nil {
+			return nil, err
+		}
+		if known, ok := states[s.Version]; ok {
+			known.Applied = s.Applied
+			known.Dirty = s.Dirty
+		} else {
+			//Applied by a newer build than this one.
+			unknown = append(unknown, s)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	list = append(list, unknown...)
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+//Pending counts the migrations a database hasn't had yet.
+func Pending(db *sql.DB) (int, error) {
+	states, err := MigrationStatus(db)
+	if err != nil {
+		return 0, err
+	}
+	pending := 0
+	for _, s := range states {
+		if s.Applied.IsZero() {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+func checkDirty(states []MigrationState) error {
+	for _, s := range states {
+		if s.Dirty {
+			return fmt.Errorf("%v: %w", s.Migration, ErrDirty)
+		}
+	}
+	return nil
+}
+
+//Migrate applies pending migrations up to and including target, or all of them when target is 0.
+func Migrate(db *sql.DB, target int) error {
+	states, err := MigrationStatus(db)
+	if err != nil {
+		return err
+	}
+	if err = checkDirty(states); err != nil {
+		return err
+	}
+	for _, s := range states {
+		if !s.Applied.IsZero() || (target > 0 && s.Version > target) {
+			continue
+		}
+		if err = runMigration(db, s.Migration, true); err != nil {
+			return err
+		}
+		fmt.Println("Applied", s.Migration)
+	}
+	return nil
+}
+
+//Rollback undoes the latest steps migrations.
+func Rollback(db *sql.DB, steps int) error {
+	states, err := MigrationStatus(db)
+	if err != nil {
+		return err
+	}
+	if err = checkDirty(states); err != nil {
+		return err
+	}
+	for i := len(states) - 1; i >= 0 && steps > 0; i-- {
+		s := states[i]
+		if s.Applied.IsZero() {
+			continue
+		}
+		if s.Down == "" {
+			return fmt.Errorf("%v was applied by a newer build, roll it back with that one", s.Migration)
+		}
+		if err = runMigration(db, s.Migration, false); err != nil {
+			return err
+		}
+		fmt.Println("Rolled back", s.Migration)
+		steps--
+	}
+	return nil
+}
+
+//runMigration runs one direction of a migration, marking it dirty until it's done.
+func runMigration(db *sql.DB, m Migration, up bool) error {
+	var e Execer = db
+	if dialect != MySQL {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		e = tx
+	}
+
+	script := m.Down
+	if up {
+		script = m.Up
+		_, err := e.Exec("INSERT INTO schema_migrations (version, name, dirty) VALUES (?,?,TRUE)", m.Version, m.Name)
+		if err != nil {
+			return err
+		}
+	} else if _, err := e.Exec("UPDATE schema_migrations SET dirty=TRUE WHERE version=?", m.Version); err != nil {
+		return err
+	}
+
+	for i, s := range SplitSQL(script) {
+		if _, err := e.Exec(s); err != nil {
+			return fmt.Errorf("%v statement %d: %w", m, i+1, err)
+		}
+	}
+
+	var err error
+	if up {
+		_, err = e.Exec("UPDATE schema_migrations SET dirty=FALSE, applied=? WHERE version=?", time.Now(), m.Version)
+	} else {
+		_, err = e.Exec("DELETE FROM schema_migrations WHERE version=?", m.Version)
+	}
+	if tx, ok := e.(*sql.Tx); ok && err == nil {
+		err = tx.Commit()
+	}
+	return err
+}
+
+//ForceMigration records a database as being at version, without running anything.  Every migration up to it is
+//marked applied and clean, and anything after it is forgotten.  Use it after fixing a failed migration by hand, or
+//to bring a database that predates migrations on board.
+func ForceMigration(db *sql.DB, version int) error {
+	migrations, err := Migrations()
+	if err != nil {
+		return err
+	}
+	if err = migrationTable(db); err != nil {
+		return err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err = tx.Exec("DELETE FROM schema_migrations WHERE version>?", version); err != nil {
+		return err
+	}
+	for _, m := range migrations {
+		if m.Version > version {
+			break
+		}
+		_, err = tx.Exec(Upsert("INTO schema_migrations (version, name, dirty) VALUES (?,?,FALSE)",
+			[]string{"version"}, []string{"dirty"}), m.Version, m.Name)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -3,11 +3,9 @@
     but for the moment it'll suit our needs.
 */
 
-DROP TABLE IF EXISTS user;
 CREATE TABLE user (
     ID INT AUTO_INCREMENT NOT NULL UNIQUE PRIMARY KEY,
     name VARCHAR(128) NOT NULL UNIQUE,
     passhash VARCHAR(128) NOT NULL,
     email VARCHAR(256) NOT NULL UNIQUE
-)
-
+);
@@ -1,4 +1,6 @@
-DROP TABLE IF EXISTS player;
+/*
+The player pool.  This only has the columns the player import fills in, see store/playerimport.
+*/
 CREATE TABLE player (
     ID INT AUTO_INCREMENT NOT NULL PRIMARY KEY,
     name VARCHAR(128) NOT NULL,
@@ -28,4 +30,4 @@ CREATE TABLE player (
     fantasy_points SMALLINT NOT NULL,
     point_per_reception DECIMAL(4,1) NOT NULL,
     value_based SMALLINT NOT NULL
-);
\ No newline at end of file
+);
@@ -0,0 +1,23 @@
+-- Tables go in the reverse order of their foreign keys.
+DROP TABLE invite_links;
+DROP TABLE draft_clocks;
+DROP TABLE draft_mutes;
+DROP TABLE draft_chat;
+DROP TABLE draft_events;
+DROP TABLE supplemental_picks;
+DROP TABLE supplemental_order;
+DROP TABLE supplemental_draft;
+DROP TABLE keepers;
+DROP TABLE keeper_settings;
+DROP TABLE draft_queue;
+DROP TABLE invites;
+DROP TABLE transactions;
+DROP TABLE rosters;
+DROP TABLE draft_picks;
+DROP TABLE scoring_settings_special;
+DROP TABLE scoring_settings_defense;
+DROP TABLE scoring_settings_offense;
+DROP TABLE teams;
+DROP TABLE draft_settings;
+DROP TABLE positional_settings;
+DROP TABLE league;
@@ -0,0 +1,18 @@
+/*
+Roles in a league beyond the commissioner and the managers of its teams.  Co-commissioners can do what the
+commissioner does, except hand out roles, and spectators can follow along without a team.
+*/
+CREATE TABLE league_roles (
+    league INT NOT NULL,
+    user INT NOT NULL,
+    role ENUM('COCOMMISSIONER', 'SPECTATOR') NOT NULL,
+    PRIMARY KEY (league, user),
+    FOREIGN KEY (league)
+        REFERENCES league(ID)
+        ON UPDATE CASCADE
+        ON DELETE CASCADE,
+    FOREIGN KEY (user)
+        REFERENCES user(ID)
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+);
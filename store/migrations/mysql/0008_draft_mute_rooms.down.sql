@@ -0,0 +1,3 @@
+DELETE FROM draft_mutes WHERE room<>CAST(league AS CHAR);
+ALTER TABLE draft_mutes DROP PRIMARY KEY, ADD PRIMARY KEY (league, user);
+ALTER TABLE draft_mutes DROP COLUMN room;
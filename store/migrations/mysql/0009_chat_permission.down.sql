@@ -0,0 +1,2 @@
+UPDATE league_roles SET permissions = REPLACE(REPLACE(permissions, ',CHAT', ''), 'CHAT,', '') WHERE permissions <> 'CHAT';
+UPDATE league_roles SET permissions = '' WHERE permissions = 'CHAT';
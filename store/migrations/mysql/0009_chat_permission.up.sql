@@ -0,0 +1,5 @@
+/*
+Moderating draft chat used to come with running drafts, and now it's a permission of its own, CHAT.  Co-commissioners
+who could run drafts keep moderating.
+*/
+UPDATE league_roles SET permissions = CONCAT(permissions, ',CHAT') WHERE CONCAT(',', permissions, ',') LIKE '%,DRAFT,%';
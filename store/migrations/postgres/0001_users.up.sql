@@ -0,0 +1,11 @@
+/*
+    We fire this script up for our user database.  This could become more complicated, 
+    but for the moment it'll suit our needs.
+*/
+
+CREATE TABLE "user" (
+    ID SERIAL PRIMARY KEY,
+    name VARCHAR(128) NOT NULL UNIQUE,
+    passhash VARCHAR(128) NOT NULL,
+    email VARCHAR(256) NOT NULL UNIQUE
+);
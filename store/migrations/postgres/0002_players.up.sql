@@ -0,0 +1,33 @@
+/*
+The player pool.  This only has the columns the player import fills in, see store/playerimport.
+*/
+CREATE TABLE player (
+    ID SERIAL PRIMARY KEY,
+    name VARCHAR(128) NOT NULL,
+    pfbr_name VARCHAR(128) NOT NULL,
+    team VARCHAR(3) NOT NULL,
+    position VARCHAR(2) NOT NULL CHECK (position IN ('QB', 'RB', 'WR', 'TE')),
+    age SMALLINT NOT NULL,
+    games INT NOT NULL,
+    starts INT NOT NULL,
+    pass_completions INT NOT NULL,
+    pass_attempts INT NOT NULL,
+    pass_yards INT NOT NULL,
+    pass_touchdowns INT NOT NULL,
+    pass_interceptions INT NOT NULL,
+    rush_attempts INT NOT NULL,
+    rush_yards SMALLINT NOT NULL,
+    rush_touchdowns INT NOT NULL,
+    targets INT NOT NULL,
+    receptions INT NOT NULL,
+    receiving_yards SMALLINT NOT NULL,
+    receiving_touchdowns INT NOT NULL,
+    fumbles INT NOT NULL,
+    fumbles_lost INT NOT NULL,
+    all_touchdowns INT NOT NULL,
+    two_point_conversion INT NOT NULL,
+    two_point_pass INT NOT NULL,
+    fantasy_points SMALLINT NOT NULL,
+    point_per_reception DECIMAL(4,1) NOT NULL,
+    value_based SMALLINT NOT NULL
+);
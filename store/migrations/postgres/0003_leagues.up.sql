@@ -0,0 +1,462 @@
+/*
+These are the tables to create when starting a fresh instance for Fantasy Football leagues.  We've got the league identifying
+table, which gives the broad outline of the identity of the league, as well as sub tables that give a fuller understanding
+of the specifics.  We're going to attempt to create a highly customizable format that allows commissioners to dream up new
+types of league games.  While we're going to have to show discipline on the front end to enable this creativity without 
+overwhelming casual users, but I think being able to create leagues where you score more for picking players with bad
+stats, or a fantasy punters league brings a lot to the table.
+
+Keeping with our original structure, We need to create a league to assign teams to.  To keep things snappy, I think this
+is the big table.  When we look up a league (through ID), we return the League's name, a user's primary key and a nice 
+little bool to inform us if the draft is complete.  We'll also track the state of the league through the state enum,
+and give ourselves a kind which informs the type of competition this league is engaging in.
+*/
+
+CREATE TABLE league (
+    ID SERIAL PRIMARY KEY,
+    name VARCHAR(128) NOT NULL,
+    commissioner INT NOT NULL,
+    state VARCHAR(10) DEFAULT 'INIT' CHECK (state IN ('INIT', 'PREDRAFT', 'DRAFT', 'INPROGRESS', 'COMPLETE')),
+    maxOwner SMALLINT NOT NULL,
+    kind VARCHAR(10) DEFAULT 'TRAD' CHECK (kind IN ('TRAD', 'TP', 'ALLPLAY', 'PIRATE', 'GUILLOTINE')),
+    season SMALLINT NOT NULL DEFAULT 0,
+    previous INT DEFAULT NULL,
+    FOREIGN KEY (previous)
+        REFERENCES league(ID)
+        ON UPDATE CASCADE
+        ON DELETE SET NULL
+);
+
+/*
+Leagues used to be single use.  Now a commissioner can renew a league into a new season, which copies the settings,
+teams and managers into a fresh league that points back at the old one through previous.  Season is the NFL season
+the league plays.
+*/
+
+/*
+We'll keep draft settings on it's own table.  It's only accessible for a while and it's not terribly relevant after the draft,
+so we'll more effectively resist the temptation to call for this info.  draftClock is the seconds each team gets per pick,
+with 0 meaning no clock.  Slow drafts are run over days rather than in one sitting, so their draftClock is in hours.
+*/
+CREATE TABLE draft_settings (
+    ID INT NOT NULL UNIQUE,
+    kind VARCHAR(7) DEFAULT 'TRAD' CHECK (kind IN ('TRAD', 'AUCTION')),
+    draftOrder VARCHAR(8) DEFAULT 'SNAKE' CHECK (draftOrder IN ('SNAKE', 'STRAIGHT', 'CURSED', 'CUSTOM')),
+    time TIMESTAMPTZ NOT NULL DEFAULT '0001-01-01 00:00:00+00',
+    draftClock SMALLINT NOT NULL DEFAULT 0,
+    rounds SMALLINT NOT NULL DEFAULT 15,
+    pace VARCHAR(4) DEFAULT 'LIVE' CHECK (pace IN ('LIVE', 'SLOW')),
+    FOREIGN KEY (ID)
+        REFERENCES league(ID)
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+);
+
+/*
+The same logic applies to positional settings.  We'll allow commissioners to define
+how many starters a team can use at each position.  Much like draft settings, we'll want to lock (or soft lock)
+these values after a draft has officially started, though we may add an option to allow commissioners to add
+a bench spot during a season.  
+*/
+CREATE TABLE positional_settings (
+    ID INT NOT NULL UNIQUE,
+    kind VARCHAR(6) DEFAULT 'TRAD' CHECK (kind IN ('TRAD', 'IDP', 'CUSTOM')),
+    qb SMALLINT NOT NULL DEFAULT 1,
+    rb SMALLINT NOT NULL DEFAULT 2,
+    wr SMALLINT NOT NULL DEFAULT 2,
+    te SMALLINT NOT NULL DEFAULT 1,
+    flex SMALLINT NOT NULL DEFAULT 1,
+    bench SMALLINT NOT NULL DEFAULT 6,
+    superflex SMALLINT NOT NULL DEFAULT 0,
+    def SMALLINT NOT NULL DEFAULT 1,
+    k SMALLINT NOT NULL DEFAULT 1,
+    FOREIGN KEY (ID)
+        REFERENCES league(ID)
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+);
+
+/*
+    TODO:
+    p TINYINT NOT NULL DEFAULT 0 --All Punters League 1 day
+    dl TINYINT NOT NULL DEFAULT 0, -- Individual defensive player 
+    lb TINYINT NOT NULL DEFAULT 0,
+    db TINYINT NOT NULL DEFAULT 0,
+*/
+
+/*
+We're going to allow a decent sized range for point per stat.  .01 - 99 per 1 in each stat seems like fair
+compromise.  We're also trying to be comprehensive in what stats the user can apply scoring to.  While
+the final implementation might be limited by what we can get, we should aspire to cover as many stats as
+possible
+*/
+CREATE TABLE scoring_settings_offense (
+    ID INT NOT NULL UNIQUE,
+    pass_att DECIMAL(4,2) NOT NULL DEFAULT 0,
+    pass_comp DECIMAL(4,2) NOT NULL DEFAULT 0,
+    pass_yard DECIMAL(4,2) NOT NULL DEFAULT 0.04,
+    pass_td DECIMAL (4,2) NOT NULL DEFAULT 6,
+    pass_int DECIMAL (4,2) NOT NULL DEFAULT -3,
+    pass_sack DECIMAL (4,2) NOT NULL DEFAULT 0,
+    rush_att DECIMAL (4,2) NOT NULL DEFAULT 0,
+    rush_yard DECIMAL(4,2) NOT NULL DEFAULT 0.1,
+    rush_td DECIMAL (4,2) NOT NULL DEFAULT 6,
+    rec_tar DECIMAL (4,2) NOT NULL DEFAULT 0,
+    rec DECIMAL (4,2) NOT NULL DEFAULT 0,
+    rec_yard DECIMAL(4,2) NOT NULL DEFAULT 0.1,
+    rec_td DECIMAL (4,2) NOT NULL DEFAULT 6,
+    fum DECIMAL (4,2) NOT NULL DEFAULT -1,
+    fum_lost DECIMAL (4,2) NOT NULL DEFAULT -2,
+    misc_td DECIMAL (4,2) NOT NULL DEFAULT 6,
+    two_point DECIMAL (4,2) NOT NULL DEFAULT 2,
+    two_point_pass DECIMAL (4,2) NOT NULL DEFAULT 2,
+    FOREIGN KEY (ID)
+        REFERENCES league(ID)
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+);
+
+/* after some reflection, we're only really going to have these settings called all at
+once when users are checking the scoring settings of a league.  When we reference them
+to apply scoring, we'll likely be applying a only a subset for each player.  So instead
+of one big scoring table, we'll split it into three, regarding offense, defense and special
+teams.  We'll also replace the scaling yard thresholds (like the points allowed) and instead
+have the defense start with a bonus, that diminishes for every yard gained.  So default
+you get 3 points for 0 yards, and start going negative when the defense gives up 300 yards.
+*/
+CREATE TABLE scoring_settings_defense (
+    ID INT NOT NULL UNIQUE,
+    touchdown DECIMAL (4,2) NOT NULL DEFAULT 6,
+    sack DECIMAL (4,2) NOT NULL DEFAULT 1,
+    interception DECIMAL (4,2) NOT NULL DEFAULT 3,
+    safety DECIMAL (4,2) NOT NULL DEFAULT 2,
+    shutout DECIMAL (4,2) NOT NULL DEFAULT 10,
+    points_6 DECIMAL (4,2) NOT NULL DEFAULT 7,
+    points_13 DECIMAL (4,2) NOT NULL DEFAULT 4,
+    points_20 DECIMAL (4,2) NOT NULL DEFAULT 1,
+    points_27 DECIMAL (4,2) NOT NULL DEFAULT 0,
+    points_34 DECIMAL (4,2) NOT NULL DEFAULT -1,
+    points_35 DECIMAL (4,2) NOT NULL DEFAULT -4,
+    yardBonus DECIMAL (4,2) NOT NULL DEFAULT 3,
+    yards DECIMAL (4,2) NOT NULL DEFAULT -0.01,
+    FOREIGN KEY (ID)
+        REFERENCES league(ID)
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+);
+
+/*
+For the time being we'll just inlude kicker stats.  Returns can be covered by defensive touchdowns
+until IDP is implemented.
+*/
+CREATE TABLE scoring_settings_special (
+    ID INT NOT NULL UNIQUE,
+    fg_29 DECIMAL (4,2) NOT NULL DEFAULT 3,
+    fg_39 DECIMAL (4,2) NOT NULL DEFAULT 3,
+    fg_49 DECIMAL (4,2) NOT NULL DEFAULT 3,
+    fg_50 DECIMAL (4,2) NOT NULL DEFAULT 3,
+    extra_point DECIMAL (4,2) NOT NULL DEFAULT 1,
+    FOREIGN KEY (ID)
+        REFERENCES league(ID)
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+);
+
+
+/*
+    TODO:
+    spec_punt DECIMAL (4,2) NOT NULL DEFAULT 0 --Not important at the moment
+    def_tackle DECIMAL (4,2) NOT NULL DEFAULT 0, --Individual Defensive players
+    spec_return_yards DECIMAL (4,2) NOT NULL DEFAULT 0, --I don't think I'm tracking this stat in demo
+    spec_return_td DECIMAL (4,2) NOT NULL DEFAULT 6, --covered by misc td atm
+*/
+
+
+
+/*
+Leagues used to get their own teams, draft, roster, transactions and invites tables, and users their own leagues and
+invites tables, all created on the fly.  That worked until we had tens of thousands of tables, so now each lives in
+one table keyed by league.  Team IDs still count up from 1 within a league (see addTeam in store/sql.go), since
+the draft, queues and keepers all refer to a team by its number in the league.  A user's leagues are the ones they
+manage a team in.
+*/
+CREATE TABLE teams (
+    league INT NOT NULL,
+    ID INT NOT NULL,
+    name VARCHAR(128) NOT NULL,
+    manager INT NOT NULL,
+    slot INT NOT NULL DEFAULT 0,
+    autodraft BOOLEAN NOT NULL DEFAULT FALSE,
+    PRIMARY KEY (league, ID),
+    UNIQUE (league, manager),
+    FOREIGN KEY (league)
+        REFERENCES league(ID)
+        ON UPDATE CASCADE
+        ON DELETE CASCADE,
+    FOREIGN KEY (manager)
+        REFERENCES "user"(ID)
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+);
+CREATE INDEX teams_manager ON teams (manager);
+
+-- Drafted players.  ID is the pick, counting from 0.
+CREATE TABLE draft_picks (
+    league INT NOT NULL,
+    ID INT NOT NULL,
+    player INT NOT NULL,
+    team INT NOT NULL,
+    PRIMARY KEY (league, ID),
+    UNIQUE (league, player),
+    FOREIGN KEY (league, team)
+        REFERENCES teams(league, ID)
+        ON UPDATE CASCADE
+        ON DELETE CASCADE,
+    FOREIGN KEY (player)
+        REFERENCES player(ID)
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+);
+
+-- Players on a team outside of the draft, like supplemental picks and (some day) free agents.
+CREATE TABLE rosters (
+    league INT NOT NULL,
+    player INT NOT NULL,
+    active BOOLEAN DEFAULT FALSE,
+    team INT NOT NULL,
+    PRIMARY KEY (league, player),
+    FOREIGN KEY (league, team)
+        REFERENCES teams(league, ID)
+        ON UPDATE CASCADE
+        ON DELETE CASCADE,
+    FOREIGN KEY (player)
+        REFERENCES player(ID)
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+);
+
+/*
+Transactions will cover all roster moves outside of the draft.  A player always goes somewhere and comes from
+somewhere else, and the general player pool is a NULL team or source.  Associated points at another transaction in
+the same trade.  Nothing writes these yet.
+*/
+CREATE TABLE transactions (
+    ID SERIAL PRIMARY KEY,
+    league INT NOT NULL,
+    player INT NOT NULL,
+    team INT DEFAULT NULL,
+    source INT DEFAULT NULL,
+    associated INT DEFAULT NULL,
+    initiated TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (league, team)
+        REFERENCES teams(league, ID)
+        ON UPDATE CASCADE
+        ON DELETE CASCADE,
+    FOREIGN KEY (league, source)
+        REFERENCES teams(league, ID)
+        ON UPDATE CASCADE
+        ON DELETE CASCADE,
+    FOREIGN KEY (associated)
+        REFERENCES transactions(ID)
+        ON UPDATE CASCADE
+        ON DELETE SET NULL,
+    FOREIGN KEY (player)
+        REFERENCES player(ID)
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+);
+
+/*
+Open invites to a league.  Invites to registered users have a user, while anyone we only know by email has just the
+email until they register, when the invite becomes theirs.  Joining the league clears the invite.
+*/
+CREATE TABLE invites (
+    ID SERIAL PRIMARY KEY,
+    league INT NOT NULL,
+    "user" INT DEFAULT NULL,
+    email VARCHAR(256) DEFAULT NULL,
+    UNIQUE (league, "user"),
+    UNIQUE (league, email),
+    FOREIGN KEY (league)
+        REFERENCES league(ID)
+        ON UPDATE CASCADE
+        ON DELETE CASCADE,
+    FOREIGN KEY ("user")
+        REFERENCES "user"(ID)
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+);
+
+/*
+Each team can keep an ordered queue of players they're targeting in the draft.  We keep it on the server so it
+follows the manager between devices, and so the draft room can lean on it when a team needs to pick.
+*/
+CREATE TABLE draft_queue (
+    league INT NOT NULL,
+    team INT NOT NULL,
+    player INT NOT NULL,
+    priority SMALLINT NOT NULL,
+    PRIMARY KEY (league, team, player),
+    FOREIGN KEY (league, team)
+        REFERENCES teams(league, ID)
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+);
+
+/*
+Keeper settings govern what a renewed league carries over from the previous season.  Keepers is how many
+players each team may keep (0 for a redraft league).  A kept player costs the pick in the round they were drafted
+minus roundCost, so the classic "kept in the round drafted minus one" is roundCost 1, and 0 keeps them in the
+same round.  Players that weren't drafted (free agent pickups) cost a pick in undraftedRound, with 0 meaning
+the last round.
+*/
+CREATE TABLE keeper_settings (
+    ID INT NOT NULL UNIQUE,
+    keepers SMALLINT NOT NULL DEFAULT 0,
+    roundCost SMALLINT NOT NULL DEFAULT 1,
+    undraftedRound SMALLINT NOT NULL DEFAULT 0,
+    FOREIGN KEY (ID)
+        REFERENCES league(ID)
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+);
+
+/*
+Keepers designated for a renewed league.  League is the new league and team is one of its teams, while round is the
+round of the pick the keeper costs.  When the draft starts, these are filled in on draft_picks.
+*/
+CREATE TABLE keepers (
+    league INT NOT NULL,
+    team INT NOT NULL,
+    player INT NOT NULL,
+    round SMALLINT NOT NULL,
+    PRIMARY KEY (league, player),
+    FOREIGN KEY (league, team)
+        REFERENCES teams(league, ID)
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+);
+
+/*
+Dynasty leagues hold a smaller draft each year for incoming rookies, on top of the startup draft.  A supplemental
+draft gets its own rounds and order (worst team first), and its picks are kept much like draft_picks.  Picked
+players also go on rosters so the rest of the site knows who owns them.
+*/
+CREATE TABLE supplemental_draft (
+    ID SERIAL PRIMARY KEY,
+    league INT NOT NULL,
+    season SMALLINT NOT NULL,
+    rounds SMALLINT NOT NULL DEFAULT 3,
+    state VARCHAR(8) DEFAULT 'PREDRAFT' CHECK (state IN ('PREDRAFT', 'DRAFT', 'COMPLETE')),
+    FOREIGN KEY (league)
+        REFERENCES league(ID)
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+);
+
+CREATE TABLE supplemental_order (
+    draft INT NOT NULL,
+    team INT NOT NULL,
+    slot INT NOT NULL,
+    PRIMARY KEY (draft, team),
+    FOREIGN KEY (draft)
+        REFERENCES supplemental_draft(ID)
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+);
+
+CREATE TABLE supplemental_picks (
+    draft INT NOT NULL,
+    ID INT NOT NULL,
+    player INT NOT NULL,
+    team INT NOT NULL,
+    PRIMARY KEY (draft, ID),
+    UNIQUE (draft, player),
+    FOREIGN KEY (draft)
+        REFERENCES supplemental_draft(ID)
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+);
+
+/*
+Everything the hub broadcasts to a draft room gets a sequence number and a row here, so a client that dropped off
+can ask for whatever it missed and end up with exactly what everyone else saw.  Room is the league ID, or
+league/draft for supplemental drafts.  Data is the message as it went out.
+*/
+CREATE TABLE draft_events (
+    league INT NOT NULL,
+    room VARCHAR(32) NOT NULL,
+    seq INT NOT NULL,
+    data TEXT NOT NULL,
+    created TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (room, seq),
+    FOREIGN KEY (league)
+        REFERENCES league(ID)
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+);
+
+/*
+Draft chat sticks around now, so people joining late can catch up and leagues can keep a record of the trash talk.
+Room works like it does on draft_events.  Commissioners can delete a message, which hides it rather than removing
+it, and mute a manager for the rest of the league's drafts.
+*/
+CREATE TABLE draft_chat (
+    ID SERIAL PRIMARY KEY,
+    league INT NOT NULL,
+    room VARCHAR(32) NOT NULL,
+    "user" INT NOT NULL,
+    message VARCHAR(512) NOT NULL,
+    sent TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    deleted BOOLEAN NOT NULL DEFAULT FALSE,
+    FOREIGN KEY (league)
+        REFERENCES league(ID)
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+);
+CREATE INDEX draft_chat_room_ID ON draft_chat (room, ID);
+
+CREATE TABLE draft_mutes (
+    league INT NOT NULL,
+    "user" INT NOT NULL,
+    PRIMARY KEY (league, "user"),
+    FOREIGN KEY (league)
+        REFERENCES league(ID)
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+);
+
+/*
+The draft clock for the pick a league is on, so other instances can answer for it and a restart picks the clock up
+where it left off.  A slow draft can go days between picks, which is a long time to only keep something in memory.
+*/
+CREATE TABLE draft_clocks (
+    league INT NOT NULL UNIQUE PRIMARY KEY,
+    pick INT NOT NULL,
+    team INT NOT NULL,
+    deadline TIMESTAMPTZ NOT NULL,
+    FOREIGN KEY (league, team)
+        REFERENCES teams(league, ID)
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+);
+
+/*
+Invite links let a commissioner invite people without knowing their email.  The link itself is signed (see
+server/invitelinks.go), and this row is what lets us expire it, revoke it, or stop it after one use.  Nonce goes into
+the signature, so a link can be handed out again from the list but not guessed.
+*/
+CREATE TABLE invite_links (
+    ID SERIAL PRIMARY KEY,
+    league INT NOT NULL,
+    nonce CHAR(32) NOT NULL,
+    created TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires TIMESTAMPTZ NOT NULL,
+    singleUse BOOLEAN NOT NULL DEFAULT FALSE,
+    uses INT NOT NULL DEFAULT 0,
+    revoked BOOLEAN NOT NULL DEFAULT FALSE,
+    FOREIGN KEY (league)
+        REFERENCES league(ID)
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+);
@@ -0,0 +1 @@
+DROP TABLE league_roles;
@@ -0,0 +1,3 @@
+DELETE FROM draft_mutes WHERE room<>CAST(league AS VARCHAR);
+ALTER TABLE draft_mutes DROP CONSTRAINT draft_mutes_pkey, ADD PRIMARY KEY (league, "user");
+ALTER TABLE draft_mutes DROP COLUMN room;
@@ -0,0 +1,8 @@
+/*
+Mutes only last for the draft they were handed out in.  Room says which draft, the same way it does on draft_chat, so
+a manager muted in the main draft can still talk in the league's supplemental drafts.  Mutes from before this were
+all handed out in main drafts.
+*/
+ALTER TABLE draft_mutes ADD room VARCHAR(32) NOT NULL DEFAULT '';
+UPDATE draft_mutes SET room=CAST(league AS VARCHAR);
+ALTER TABLE draft_mutes DROP CONSTRAINT draft_mutes_pkey, ADD PRIMARY KEY (league, room, "user");
@@ -0,0 +1,33 @@
+/*
+The player pool.  This only has the columns the player import fills in, see store/playerimport.
+*/
+CREATE TABLE player (
+    ID INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(128) NOT NULL,
+    pfbr_name VARCHAR(128) NOT NULL,
+    team VARCHAR(3) NOT NULL,
+    position VARCHAR(2) NOT NULL CHECK (position IN ('QB', 'RB', 'WR', 'TE')),
+    age TINYINT UNSIGNED NOT NULL,
+    games SMALLINT UNSIGNED NOT NULL,
+    starts SMALLINT UNSIGNED NOT NULL,
+    pass_completions SMALLINT UNSIGNED NOT NULL,
+    pass_attempts SMALLINT UNSIGNED NOT NULL,
+    pass_yards MEDIUMINT NOT NULL,
+    pass_touchdowns SMALLINT UNSIGNED NOT NULL,
+    pass_interceptions SMALLINT UNSIGNED NOT NULL,
+    rush_attempts SMALLINT UNSIGNED NOT NULL,
+    rush_yards SMALLINT NOT NULL,
+    rush_touchdowns SMALLINT UNSIGNED NOT NULL,
+    targets SMALLINT UNSIGNED NOT NULL,
+    receptions SMALLINT UNSIGNED NOT NULL,
+    receiving_yards SMALLINT NOT NULL,
+    receiving_touchdowns SMALLINT UNSIGNED NOT NULL,
+    fumbles SMALLINT UNSIGNED NOT NULL,
+    fumbles_lost SMALLINT UNSIGNED NOT NULL,
+    all_touchdowns SMALLINT UNSIGNED NOT NULL,
+    two_point_conversion SMALLINT UNSIGNED NOT NULL,
+    two_point_pass SMALLINT UNSIGNED NOT NULL,
+    fantasy_points SMALLINT NOT NULL,
+    point_per_reception DECIMAL(4,1) NOT NULL,
+    value_based SMALLINT NOT NULL
+);
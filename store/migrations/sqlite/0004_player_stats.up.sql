@@ -0,0 +1,36 @@
+/*
+    The player table only carries a single season summary, which is enough to draft off of but
+    not much else.  player_stats keeps a row per player per week, so we can build out a stat
+    history and score it however a league likes.  We use week 0 for seasons where we only have
+    the totals.
+*/
+
+CREATE TABLE player_stats (
+    player INT NOT NULL,
+    season SMALLINT NOT NULL,
+    week TINYINT NOT NULL DEFAULT 0,
+    games TINYINT UNSIGNED NOT NULL DEFAULT 0,
+    starts TINYINT UNSIGNED NOT NULL DEFAULT 0,
+    pass_completions SMALLINT UNSIGNED NOT NULL DEFAULT 0,
+    pass_attempts SMALLINT UNSIGNED NOT NULL DEFAULT 0,
+    pass_yards SMALLINT NOT NULL DEFAULT 0,
+    pass_touchdowns TINYINT UNSIGNED NOT NULL DEFAULT 0,
+    pass_interceptions TINYINT UNSIGNED NOT NULL DEFAULT 0,
+    rush_attempts SMALLINT UNSIGNED NOT NULL DEFAULT 0,
+    rush_yards SMALLINT NOT NULL DEFAULT 0,
+    rush_touchdowns TINYINT UNSIGNED NOT NULL DEFAULT 0,
+    targets SMALLINT UNSIGNED NOT NULL DEFAULT 0,
+    receptions SMALLINT UNSIGNED NOT NULL DEFAULT 0,
+    receiving_yards SMALLINT NOT NULL DEFAULT 0,
+    receiving_touchdowns TINYINT UNSIGNED NOT NULL DEFAULT 0,
+    fumbles TINYINT UNSIGNED NOT NULL DEFAULT 0,
+    fumbles_lost TINYINT UNSIGNED NOT NULL DEFAULT 0,
+    all_touchdowns TINYINT UNSIGNED NOT NULL DEFAULT 0,
+    two_point_conversion TINYINT UNSIGNED NOT NULL DEFAULT 0,
+    two_point_pass TINYINT UNSIGNED NOT NULL DEFAULT 0,
+    PRIMARY KEY (player, season, week),
+    FOREIGN KEY (player)
+        REFERENCES player(ID)
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+);
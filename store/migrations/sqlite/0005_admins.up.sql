@@ -0,0 +1,6 @@
+/*
+    Admins are accounts that can look after the whole site, rather than just the leagues they run.  There's no way
+    to become one from the site itself; main create-admin makes them.
+*/
+
+ALTER TABLE user ADD COLUMN admin BOOLEAN NOT NULL DEFAULT FALSE;
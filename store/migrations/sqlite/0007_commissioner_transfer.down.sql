@@ -0,0 +1,3 @@
+DROP TABLE league_role_changes;
+DROP TABLE commissioner_transfers;
+ALTER TABLE league_roles DROP COLUMN permissions;
@@ -0,0 +1,50 @@
+/*
+Co-commissioners can be limited to some of the commissioner's jobs: INVITES, SETTINGS and DRAFT, comma separated.
+Anyone made a co-commissioner before this keeps all three.
+*/
+ALTER TABLE league_roles ADD COLUMN permissions VARCHAR(64) NOT NULL DEFAULT 'INVITES,SETTINGS,DRAFT';
+
+/*
+A league's commissioner can offer the league to someone else in it, and it's only theirs once they accept.  There's
+one offer out per league at most.  fromUser is the commissioner when it was offered.
+*/
+CREATE TABLE commissioner_transfers (
+    league INT NOT NULL PRIMARY KEY,
+    fromUser INT NOT NULL,
+    toUser INT NOT NULL,
+    created DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (league)
+        REFERENCES league(ID)
+        ON UPDATE CASCADE
+        ON DELETE CASCADE,
+    FOREIGN KEY (fromUser)
+        REFERENCES user(ID)
+        ON UPDATE CASCADE
+        ON DELETE CASCADE,
+    FOREIGN KEY (toUser)
+        REFERENCES user(ID)
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+);
+
+/*
+Every change to who does what in a league, so there's no arguing later about who made who a co-commissioner.  Actor
+is whoever made the change and user is who it happened to.  Action is GRANT or REVOKE for league_roles, and OFFER,
+CANCEL, DECLINE or ACCEPT for commissioner transfers.  On an ACCEPT, actor is the new commissioner and user the one
+they took over from.
+*/
+CREATE TABLE league_role_changes (
+    ID INTEGER PRIMARY KEY AUTOINCREMENT,
+    league INT NOT NULL,
+    actor INT NOT NULL,
+    user INT NOT NULL,
+    action VARCHAR(8) NOT NULL,
+    role VARCHAR(14) NOT NULL DEFAULT '',
+    permissions VARCHAR(64) NOT NULL DEFAULT '',
+    created DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (league)
+        REFERENCES league(ID)
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+);
+CREATE INDEX league_role_changes_league ON league_role_changes (league, ID);
@@ -0,0 +1,12 @@
+CREATE TABLE draft_mutes_leagues (
+    league INT NOT NULL,
+    user INT NOT NULL,
+    PRIMARY KEY (league, user),
+    FOREIGN KEY (league)
+        REFERENCES league(ID)
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+);
+INSERT INTO draft_mutes_leagues (league, user) SELECT league, user FROM draft_mutes WHERE room=CAST(league AS TEXT);
+DROP TABLE draft_mutes;
+ALTER TABLE draft_mutes_leagues RENAME TO draft_mutes;
@@ -0,0 +1,18 @@
+/*
+Mutes only last for the draft they were handed out in.  Room says which draft, the same way it does on draft_chat, so
+a manager muted in the main draft can still talk in the league's supplemental drafts.  Mutes from before this were
+all handed out in main drafts.
+*/
+CREATE TABLE draft_mutes_rooms (
+    league INT NOT NULL,
+    room VARCHAR(32) NOT NULL,
+    user INT NOT NULL,
+    PRIMARY KEY (league, room, user),
+    FOREIGN KEY (league)
+        REFERENCES league(ID)
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+);
+INSERT INTO draft_mutes_rooms (league, room, user) SELECT league, CAST(league AS TEXT), user FROM draft_mutes;
+DROP TABLE draft_mutes;
+ALTER TABLE draft_mutes_rooms RENAME TO draft_mutes;
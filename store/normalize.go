@@ -0,0 +1,306 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+//We used to give every league its own teams, draft, roster, transactions and invites tables, and every user their
+//own leagues and invites tables.  FoldLeagueTables moves a database from that layout to the shared tables in the
+//leagues migration.  It's a one time job (main -normalize), but it can be run again if it gets interrupted: a
+//league whose teams are already in the shared table is only cleaned up, and everything else is copied with INSERT
+//IGNORE.  A database that old has none of the tables the migrations added since, so we build them from the
+//migrations first, add the columns its own tables are missing, and record those migrations as applied.  migrate
+//does the rest.
+
+//foldedVersion is the last migration the fold builds tables from.
+const foldedVersion = 4
+
+var createTable = regexp.MustCompile(`^CREATE TABLE (\w+)`)
+
+//addedColumns are the columns the migrations up to foldedVersion have on tables that were already around before them.
+var addedColumns = []struct {
+	table  string
+	column string
+	add    string
+}{
+	{"league", "season", "ADD season SMALLINT NOT NULL DEFAULT 0"},
+	{"league", "previous", "ADD previous INT DEFAULT NULL, ADD FOREIGN KEY (previous) REFERENCES league(ID) ON UPDATE CASCADE ON DELETE SET NULL"},
+	{"draft_settings", "pace", "ADD pace ENUM('LIVE', 'SLOW') DEFAULT 'LIVE'"},
+}
+
+var (
+	leagueTable    = regexp.MustCompile(`^(teams|draft|roster|transactions)_([0-9]+)$`)
+	leagueInvites  = regexp.MustCompile(`^league_([0-9]+)_invites$`)
+	userTable      = regexp.MustCompile(`^(leagues|invites)_([0-9]+)$`)
+	leagueTableSet = []string{"draft", "roster", "transactions", "teams"}
+)
+
+//FoldLeagueTables copies the old per league and per user tables into the shared ones, and drops them.
+func FoldLeagueTables(db *sql.DB) error {
+	if dialect != MySQL {
+		//The old layout never made it off of MySQL.
+		return errors.New("only MySQL databases have per league tables to fold")
+	}
+	if err := createTables(db); err != nil {
+		return err
+	}
+
+	rows, err := db.Query("SELECT TABLE_NAME FROM information_schema.tables WHERE table_schema=DATABASE()")
+	if err != nil {
+		return err
+	}
+	leagues := make(map[int64]map[string]bool)
+	var invites []int64
+	users := make(map[int64]map[string]bool)
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		if m := leagueTable.FindStringSubmatch(name); m != nil {
+			ID, _ := strconv.ParseInt(m[2], 10, 64)
+			if leagues[ID] == nil {
+				leagues[ID] = make(map[string]bool)
+			}
+			leagues[ID][m[1]] = true
+		} else if m := leagueInvites.FindStringSubmatch(name); m != nil {
+			ID, _ := strconv.ParseInt(m[1], 10, 64)
+			invites = append(invites, ID)
+		} else if m := userTable.FindStringSubmatch(name); m != nil {
+			ID, _ := strconv.ParseInt(m[2], 10, 64)
+			if users[ID] == nil {
+				users[ID] = make(map[string]bool)
+			}
+			users[ID][m[1]] = true
+		}
+	}
+	rows.Close()
+
+	for _, ID := range sortedKeys(leagues) {
+		if err = foldLeague(db, ID, leagues[ID]); err != nil {
+			return fmt.Errorf("league %d: %w", ID, err)
+		}
+	}
+	fmt.Println("Folded", len(leagues), "leagues")
+
+	for _, ID := range invites {
+		stringID := strconv.FormatInt(ID, 10)
+		//0 stood in for everyone invited by email, who are on invites_0.
+		_, err = db.Exec(`INSERT IGNORE INTO invites (league, user) SELECT ?, i.user FROM league_`+stringID+`_invites AS i
+			JOIN user ON i.user=user.ID JOIN league ON league.ID=?`, ID, ID)
+		if err != nil {
+			return fmt.Errorf("league %d invites: %w", ID, err)
+		}
+		if _, err = db.Exec("DROP TABLE league_" + stringID + "_invites"); err != nil {
+			return err
+		}
+	}
+
+	for _, ID := range sortedKeys(users) {
+		stringID := strconv.FormatInt(ID, 10)
+		if users[ID]["invites"] {
+			if ID == 0 {
+				_, err = db.Exec("INSERT IGNORE INTO invites (league, email) SELECT league, email FROM invites_0")
+			} else {
+				_, err = db.Exec("INSERT IGNORE INTO invites (league, user) SELECT league, ? FROM invites_"+stringID, ID)
+			}
+			if err != nil {
+				return fmt.Errorf("user %d invites: %w", ID, err)
+			}
+			if _, err = db.Exec("DROP TABLE invites_" + stringID); err != nil {
+				return err
+			}
+		}
+		//A user's leagues are the ones they manage a team in, so there's nothing to copy.
+		if users[ID]["leagues"] {
+			if _, err = db.Exec("DROP TABLE leagues_" + stringID); err != nil {
+				return err
+			}
+		}
+	}
+	fmt.Println("Folded invites for", len(users), "users")
+
+	if err = linkTeams(db); err != nil {
+		return err
+	}
+	return recordFold(db)
+}
+
+//foldLeague copies one league's tables in a single transaction, then drops them.
+func foldLeague(db *sql.DB, league int64, tables map[string]bool) error {
+	stringID := strconv.FormatInt(league, 10)
+	var folded int
+	row := db.QueryRow("SELECT COUNT(*) FROM teams WHERE league=?", league)
+	if err := row.Scan(&folded); err != nil {
+		return err
+	}
+
+	if folded == 0 && tables["teams"] {
+		//Autodraft came along after these tables, and only leagues made since then have it.
+		has, err := columnExists(db, "teams_"+stringID, "autodraft")
+		if err != nil {
+			return err
+		}
+		autodraft := "0"
+		if has {
+			autodraft = "autodraft"
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		_, err = tx.Exec("INSERT INTO teams (league, ID, name, manager, slot, autodraft) SELECT ?, ID, name, manager, slot, "+
+			autodraft+" FROM teams_"+stringID, league)
+		if err != nil {
+			return err
+		}
+		if tables["draft"] {
+			_, err = tx.Exec("INSERT INTO draft_picks (league, ID, player, team) SELECT ?, ID, player, team FROM draft_"+stringID, league)
+			if err != nil {
+				return err
+			}
+		}
+		if tables["roster"] {
+			_, err = tx.Exec("INSERT INTO rosters (league, player, active, team) SELECT ?, player, active, team FROM roster_"+stringID, league)
+			if err != nil {
+				return err
+			}
+		}
+		if tables["transactions"] {
+			//Transaction IDs are shared between leagues now, so each league's get shifted past the ones already there.
+			//Team 0 was the player pool, and associated 0 meant no trade.  Both are NULL now.
+			var offset int64
+			row = tx.QueryRow("SELECT COALESCE(MAX(ID), 0) FROM transactions")
+			if err = row.Scan(&offset); err != nil {
+				return err
+			}
+			_, err = tx.Exec(`INSERT INTO transactions (ID, league, player, team, source, initiated)
+				SELECT ID+?, ?, player, NULLIF(team, 0), NULLIF(source, 0), initiated FROM transactions_`+stringID, offset, league)
+			if err != nil {
+				return err
+			}
+			_, err = tx.Exec(`UPDATE transactions AS t JOIN transactions_`+stringID+` AS o ON t.ID=o.ID+?
+				SET t.associated=o.associated+? WHERE o.associated<>0`, offset, offset)
+			if err != nil {
+				return err
+			}
+		}
+		if err = tx.Commit(); err != nil {
+			return err
+		}
+	}
+
+	//Teams go last, since the draft and roster tables point at them.
+	for _, t := range leagueTableSet {
+		if tables[t] {
+			if _, err := db.Exec("DROP TABLE " + t + "_" + stringID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+//createTables makes any table from the migrations up to foldedVersion that the database doesn't have yet, and adds
+//any of their columns it's missing.  Those migrations are nothing but CREATE TABLE statements, which we tell to skip
+//the tables that are already there.
+func createTables(db *sql.DB) error {
+	migrations, err := Migrations()
+	if err != nil {
+		return err
+	}
+	for _, m := range migrations {
+		if m.Version > foldedVersion {
+			break
+		}
+		for i, s := range SplitSQL(m.Up) {
+			if !createTable.MatchString(s) {
+				return fmt.Errorf("%v statement %d isn't a CREATE TABLE", m, i+1)
+			}
+			if _, err = db.Exec(createTable.ReplaceAllString(s, "CREATE TABLE IF NOT EXISTS $1")); err != nil {
+				return fmt.Errorf("%v statement %d: %w", m, i+1, err)
+			}
+		}
+	}
+	for _, c := range addedColumns {
+		has, err := columnExists(db, c.table, c.column)
+		if err != nil {
+			return err
+		}
+		if !has {
+			if _, err = db.Exec("ALTER TABLE " + c.table + " " + c.add); err != nil {
+				return fmt.Errorf("%s.%s: %w", c.table, c.column, err)
+			}
+		}
+	}
+	return nil
+}
+
+//recordFold marks the migrations the fold built as applied, unless the database already keeps track of its
+//migrations, in which case they were applied for real.
+func recordFold(db *sql.DB) error {
+	states, err := MigrationStatus(db)
+	if err != nil {
+		return err
+	}
+	for _, s := range states {
+		if !s.Applied.IsZero() {
+			return nil
+		}
+	}
+	fmt.Println("Recorded migrations up to", foldedVersion)
+	return ForceMigration(db, foldedVersion)
+}
+
+//linkTeams gives the tables that refer to a league's teams the foreign keys they couldn't have before.  Anything
+//pointing at a team that doesn't exist goes first.
+func linkTeams(db *sql.DB) error {
+	for _, table := range []string{"draft_queue", "keepers", "draft_clocks"} {
+		var linked int
+		row := db.QueryRow(`SELECT COUNT(*) FROM information_schema.REFERENTIAL_CONSTRAINTS
+			WHERE CONSTRAINT_SCHEMA=DATABASE() AND TABLE_NAME=? AND REFERENCED_TABLE_NAME='teams'`, table)
+		if err := row.Scan(&linked); err != nil {
+			return err
+		}
+		if linked > 0 {
+			continue
+		}
+		_, err := db.Exec("DELETE FROM " + table + " WHERE NOT EXISTS (SELECT 1 FROM teams AS t WHERE t.league=" +
+			table + ".league AND t.ID=" + table + ".team)")
+		if err != nil {
+			return err
+		}
+		_, err = db.Exec("ALTER TABLE " + table + " ADD FOREIGN KEY (league, team) REFERENCES teams(league, ID) ON UPDATE CASCADE ON DELETE CASCADE")
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//columnExists checks the table has the column.
+func columnExists(db *sql.DB, table string, column string) (bool, error) {
+	var n int
+	row := db.QueryRow(`SELECT COUNT(*) FROM information_schema.COLUMNS
+		WHERE TABLE_SCHEMA=DATABASE() AND TABLE_NAME=? AND COLUMN_NAME=?`, table, column)
+	err := row.Scan(&n)
+	return n > 0, err
+}
+
+func sortedKeys(m map[int64]map[string]bool) []int64 {
+	keys := make([]int64, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
@@ -9,28 +9,15 @@ import (
 	"os"
 	"strings"
 
+	"github.com/PhiloTFarnsworth/FantasySportsAF/config"
 	"github.com/PhiloTFarnsworth/FantasySportsAF/store"
-	"github.com/go-sql-driver/mysql"
 )
 
-var db *sql.DB
-
-func importToFSGO() {
-	Import("fsgo")
-}
-
+//Import fills the player table of the database DBName from the configured player CSV.  The tests lean on it, and
+//it gives up on the first problem, where ImportFile hands it back.
 func Import(DBName string) {
-	// Capture connection properties.
-	cfg := mysql.Config{
-		User:   os.Getenv("DBUSER"),
-		Passwd: os.Getenv("DBPASS"),
-		Net:    "tcp",
-		Addr:   "127.0.0.1:3306",
-		DBName: DBName,
-	}
-	// Get a database handle.
-	var err error
-	db, err = sql.Open("mysql", cfg.FormatDSN())
+	// Get a database handle, on whichever backend store is set up for.
+	db, err := store.Open(DBName)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -41,29 +28,41 @@ func Import(DBName string) {
 	}
 	fmt.Println("Connected!")
 
-	if err = store.BatchSQLFromFile(os.Getenv("FSPSA"), db); err != nil {
+	//The player table comes from the players migration, so migrate before importing.
+	if _, err = ImportFile(db, config.Current().PlayerCSV); err != nil {
 		log.Fatal(err)
 	}
+}
 
-	//After the heavy lifting of copy and pasting the above, we need to open nfl_2020.csv and then pass it into our database.
-	f, err := os.Open(os.Getenv("nflcsv"))
+//ImportFile adds every player in a CSV laid out like nfl_2020.csv, a season's fantasy stats off pro-football-reference,
+//and says how many it added.
+func ImportFile(db *sql.DB, path string) (int, error) {
+	f, err := os.Open(path)
 	if err != nil {
-		panic(err)
+		return 0, err
 	}
+	defer f.Close()
 	r := csv.NewReader(f)
-	for {
+	added := 0
+	for line := 1; ; line++ {
 		player, err := r.Read()
 		if err == io.EOF {
 			break
 		}
 		if err != nil {
-			log.Fatal(err)
+			return added, err
 		}
 		if player[0] == "Rk" {
 			continue
 		}
+		if len(player) < 31 {
+			return added, fmt.Errorf("%s line %d: want 31 columns, got %d", path, line, len(player))
+		}
 
 		names := strings.Split(player[1], "\\")
+		if len(names) < 2 {
+			return added, fmt.Errorf("%s line %d: no pro-football-reference name for %s", path, line, player[1])
+		}
 		position := player[3]
 		if position == "" {
 			position = "WR"
@@ -98,16 +97,105 @@ func Import(DBName string) {
 			Normalize(player[27]),
 			Normalize(player[30]))
 		if err != nil {
-			fmt.Print(err)
-			panic(err)
+			return added, fmt.Errorf("%s line %d: %w", path, line, err)
 		}
+		added++
+	}
+	return added, nil
+}
 
-		// ID, err := result.LastInsertId()
-		// if err != nil {
-		// 	panic(err)
-		// }
-		// fmt.Println(ID)
+//StatColumns are the player_stats columns a stats CSV can fill in.
+var StatColumns = []string{"games", "starts", "pass_completions", "pass_attempts", "pass_yards", "pass_touchdowns",
+	"pass_interceptions", "rush_attempts", "rush_yards", "rush_touchdowns", "targets", "receptions", "receiving_yards",
+	"receiving_touchdowns", "fumbles", "fumbles_lost", "all_touchdowns", "two_point_conversion", "two_point_pass"}
+
+//ImportStats adds rows to player_stats from a CSV whose header names the columns.  Each row needs a season, and
+//either the player's ID as player or their pfbr_name.  Week is 0, the season totals, unless there's a week column,
+//and any of StatColumns can follow.  A row that's already there for the player, season and week is overwritten, so
+//a corrected file can just be imported again.  It says how many rows it wrote.
+func ImportStats(db *sql.DB, path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	header, err := r.Read()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", path, err)
+	}
+
+	known := map[string]bool{"player": true, "pfbr_name": true, "season": true, "week": true}
+	for _, c := range StatColumns {
+		known[c] = true
+	}
+	index := make(map[string]int)
+	var stats []string
+	for i, c := range header {
+		c = strings.ToLower(strings.TrimSpace(c))
+		if !known[c] {
+			return 0, fmt.Errorf("%s: unknown column %q", path, c)
+		}
+		index[c] = i
+		if c != "player" && c != "pfbr_name" && c != "season" && c != "week" {
+			stats = append(stats, c)
+		}
+	}
+	_, byID := index["player"]
+	_, byName := index["pfbr_name"]
+	if _, ok := index["season"]; !ok || !byID && !byName {
+		return 0, fmt.Errorf("%s: needs a season column, and a player or pfbr_name column", path)
+	}
+
+	columns := append([]string{"player", "season", "week"}, stats...)
+	insert := store.Upsert("INTO player_stats ("+strings.Join(columns, ", ")+") VALUES (?"+strings.Repeat(",?", len(columns)-1)+")",
+		[]string{"player", "season", "week"}, stats)
+	if len(stats) == 0 {
+		insert = store.InsertIgnore("INTO player_stats (player, season, week) VALUES (?,?,?)")
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	written := 0
+	for line := 2; ; line++ {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		var player interface{}
+		if byID {
+			player = row[index["player"]]
+		} else {
+			var ID int64
+			err = tx.QueryRow("SELECT ID FROM player WHERE pfbr_name=?", row[index["pfbr_name"]]).Scan(&ID)
+			if err == sql.ErrNoRows {
+				return 0, fmt.Errorf("%s line %d: no player %s", path, line, row[index["pfbr_name"]])
+			}
+			if err != nil {
+				return 0, err
+			}
+			player = ID
+		}
+		week := "0"
+		if i, ok := index["week"]; ok {
+			week = Normalize(row[i])
+		}
+		args := []interface{}{player, row[index["season"]], week}
+		for _, c := range stats {
+			args = append(args, Normalize(row[index[c]]))
+		}
+		if _, err = tx.Exec(insert, args...); err != nil {
+			return 0, fmt.Errorf("%s line %d: %w", path, line, err)
+		}
+		written++
 	}
+	return written, tx.Commit()
 }
 
 func Normalize(value string) string {
@@ -0,0 +1,117 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+//Rather than keep a postgres copy of every query, we register lib/pq again under our own name, wrapped so each query
+//is rewritten on its way out.  ? placeholders become $1, $2 and so on, and user, which postgres reserves, gets quoted
+//wherever it's a table or column.  Strings, quoted names and comments are left alone.
+
+const postgresDriver = "fsaf-postgres"
+
+func init() {
+	sql.Register(postgresDriver, pgDriver{})
+}
+
+type pgDriver struct{}
+
+func (pgDriver) Open(name string) (driver.Conn, error) {
+	conn, err := pq.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return pgConn{conn}, nil
+}
+
+//pgConn passes everything through to pq, after rebind.
+type pgConn struct {
+	driver.Conn
+}
+
+func (c pgConn) Prepare(query string) (driver.Stmt, error) {
+	return c.Conn.Prepare(rebind(query))
+}
+
+func (c pgConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	return c.Conn.(driver.ConnPrepareContext).PrepareContext(ctx, rebind(query))
+}
+
+func (c pgConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.Conn.(driver.QueryerContext).QueryContext(ctx, rebind(query), args)
+}
+
+func (c pgConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.Conn.(driver.ExecerContext).ExecContext(ctx, rebind(query), args)
+}
+
+func (c pgConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return c.Conn.(driver.ConnBeginTx).BeginTx(ctx, opts)
+}
+
+func (c pgConn) Ping(ctx context.Context) error {
+	return c.Conn.(driver.Pinger).Ping(ctx)
+}
+
+func (c pgConn) ResetSession(ctx context.Context) error {
+	return c.Conn.(driver.SessionResetter).ResetSession(ctx)
+}
+
+//rebind rewrites a query written for MySQL into one postgres will take.
+func rebind(query string) string {
+	var b strings.Builder
+	param := 0
+	for i := 0; i < len(query); {
+		switch c := query[i]; {
+		case c == '\'' || c == '"':
+			end := quoteEnd(query, i)
+			b.WriteString(query[i:end])
+			i = end
+		case c == '-' && strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				end = len(query) - i
+			}
+			b.WriteString(query[i : i+end])
+			i += end
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				end = len(query) - i
+			} else {
+				end += 4
+			}
+			b.WriteString(query[i : i+end])
+			i += end
+		case c == '?':
+			param++
+			b.WriteString("$" + strconv.Itoa(param))
+			i++
+		case isWordByte(c):
+			end := i
+			for end < len(query) && isWordByte(query[end]) {
+				end++
+			}
+			if strings.EqualFold(query[i:end], "user") {
+				b.WriteString(`"user"`)
+			} else {
+				b.WriteString(query[i:end])
+			}
+			i = end
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return b.String()
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c == '$' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
@@ -0,0 +1,292 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/PhiloTFarnsworth/FantasySportsAF/store/scanners"
+)
+
+//Handlers get at the database through these repositories instead of writing SQL of their own.  NewSQL is what the
+//server runs on, and NewMemory keeps everything in maps, so handlers can be tried out without a database.  Both
+//return the same Repos, which the router hands down to the handlers that need it.  Invite links, keepers and the
+//draft room still write their own SQL, so they only run on NewSQL.
+
+var (
+	//ErrNotFound is what a repository returns for a row that isn't there, whichever backend it is.
+	ErrNotFound = errors.New("not found")
+	//ErrLeagueFull is returned when a team tries to join a league at maxOwner.
+	ErrLeagueFull = errors.New("Max teams in league reached.  Contact commissioner to increase team cap")
+	//ErrTooManyTeams is returned when maxOwner would drop below the teams a league already has.
+	ErrTooManyTeams = errors.New("Max owners less than current teams in league")
+)
+
+type Repos struct {
+	Users    UserRepo
+	Leagues  LeagueRepo
+	Teams    TeamRepo
+	Invites  InviteRepo
+	Settings SettingsRepo
+	Drafts   DraftRepo
+	Players  PlayerRepo
+	Roles    RoleRepo
+	//DB is the database under the SQL repositories, for the handlers that haven't moved over to repositories yet.
+	//It's nil under NewMemory.
+	DB *sql.DB
+}
+
+//User is an account.  Passhash is only filled in by ByName, for checking a login.  Admins look after the whole site,
+//and are only made from the command line.
+type User struct {
+	ID       int64  `json:"ID" form:"ID"`
+	Name     string `json:"name" form:"name"`
+	Email    string `json:"email" form:"email"`
+	Passhash []byte `json:"-"`
+	Admin    bool   `json:"-"`
+}
+
+type League struct {
+	ID           int64
+	Name         string
+	Commissioner User
+	State        string
+	MaxOwner     int64
+	Kind         string
+	//Season stays out of the league home page, which never had it.
+	Season int `json:"-"`
+}
+
+//LeagueSummary is what a user sees of a league in their lobby.
+type LeagueSummary struct {
+	ID           int64
+	Name         string
+	Commissioner string
+}
+
+//LeagueSettings are the parts of a league its commissioner can change.
+type LeagueSettings struct {
+	ID       int64  `json:"league"`
+	Name     string `json:"name"`
+	MaxOwner int64  `json:"maxOwner"`
+	Kind     string `json:"kind"`
+}
+
+type Team struct {
+	ID      int64
+	Name    string
+	Manager User
+	Slot    int64
+}
+
+//TeamSlot is where a team picks in the draft.
+type TeamSlot struct {
+	Team int64
+	Slot int64
+}
+
+//Pick is a pick in a league's draft.  Slot is the pick number.
+type Pick struct {
+	Slot   int64
+	Player int64
+	Team   int64
+}
+
+//Keeper is a player a team keeps from last season, at the cost of their pick in Round.
+type Keeper struct {
+	Team   int64
+	Player int64
+	Round  int
+}
+
+type DraftSettings struct {
+	ID         int
+	Kind       string
+	DraftOrder string
+	Time       time.Time
+	DraftClock int
+	Rounds     int
+	//LIVE or SLOW.  Slow drafts count DraftClock in hours.
+	Pace string
+}
+
+type ScoringSettingsTotal struct {
+	O scanners.ScoringSettingsOff `json:"offense"`
+	D scanners.ScoringSettingDef  `json:"defense"`
+	S scanners.ScoringSettingsSpe `json:"special"`
+}
+
+type FullDraftSettings struct {
+	D DraftSettings               `json:"draft"`
+	P scanners.PositionalSettings `json:"positional"`
+	S ScoringSettingsTotal        `json:"scoring"`
+}
+
+//SetLeague points every part of the settings at one league.
+func (f *FullDraftSettings) SetLeague(ID int64) {
+	f.D.ID, f.P.ID = int(ID), int(ID)
+	f.S.O.ID, f.S.D.ID, f.S.S.ID = int(ID), int(ID), int(ID)
+}
+
+//Member is everything that says what a user can do in a league.  Team is 0 when they don't have one there, and Role
+//is any role they've been given in league_roles, COCOMMISSIONER or SPECTATOR.  Permissions are what a co-commissioner
+//has been allowed to do.
+type Member struct {
+	League       int64
+	User         int64
+	Commissioner bool
+	Admin        bool
+	Team         int64
+	Invited      bool
+	Role         string
+	Permissions  []string
+}
+
+//LeagueRole is a role someone's been given in a league.
+type LeagueRole struct {
+	User        User     `json:"user"`
+	Role        string   `json:"role"`
+	Permissions []string `json:"permissions"`
+}
+
+//Transfer is a commissioner's offer to hand their league to someone else.
+type Transfer struct {
+	League  int64     `json:"league"`
+	From    User      `json:"from"`
+	To      User      `json:"to"`
+	Created time.Time `json:"created"`
+}
+
+//RoleChange is a line of a league's role history.  See the league_role_changes migration for what the actions mean.
+type RoleChange struct {
+	ID          int64     `json:"ID"`
+	Actor       User      `json:"actor"`
+	User        User      `json:"user"`
+	Action      string    `json:"action"`
+	Role        string    `json:"role"`
+	Permissions []string  `json:"permissions"`
+	Created     time.Time `json:"created"`
+}
+
+//The actions in a league's role history.
+const (
+	ActionGrant   = "GRANT"
+	ActionRevoke  = "REVOKE"
+	ActionOffer   = "OFFER"
+	ActionCancel  = "CANCEL"
+	ActionDecline = "DECLINE"
+	ActionAccept  = "ACCEPT"
+)
+
+//PoolQuery narrows down the player pool.  Sort is a player table column, already checked against
+//scanners.PlayerColumns, and After is the sort value and ID of the last player on the previous page.
+type PoolQuery struct {
+	Position string
+	Team     string
+	Search   string
+	//League leaves out players drafted or rostered in it, and Rookies then keeps only its rookies.
+	League  int64
+	Rookies bool
+	Sort    string
+	Desc    bool
+	After   *PoolCursor
+	Limit   int
+}
+
+//Ownership tells a user whether a player is spoken for in one of their leagues.  Team is 0 when the player is still
+//available.
+type Ownership struct {
+	League   int64
+	Name     string
+	Team     int64
+	TeamName string
+}
+
+type PoolCursor struct {
+	ID    int64
+	Value interface{}
+}
+
+type UserRepo interface {
+	ByID(ID int64) (User, error)
+	ByName(name string) (User, error)
+	//Create adds an account, and hands it any invites sent to its email before it existed.
+	Create(name string, email string, passhash []byte) (User, error)
+	SetPassword(ID int64, passhash []byte) error
+	SetAdmin(ID int64, admin bool) error
+}
+
+type LeagueRepo interface {
+	//Create makes a league with default settings, and the commissioner's team in it.
+	Create(name string, commissioner int64, maxOwner int64, season int, team string) (int64, error)
+	Get(ID int64) (League, error)
+	//Managed is every league a user has a team in, and Invited every league they've been invited to.
+	Managed(user int64) ([]LeagueSummary, error)
+	Invited(user int64) ([]LeagueSummary, error)
+	Update(s LeagueSettings) error
+	SetState(ID int64, state string) error
+}
+
+type TeamRepo interface {
+	//List is a league's teams, in ID order.
+	List(league int64) ([]Team, error)
+	Get(league int64, ID int64) (Team, error)
+	Rename(league int64, ID int64, name string) error
+	//Join gives a user a team in a league, and clears their invite.
+	Join(league int64, name string, manager int64) error
+}
+
+type InviteRepo interface {
+	//Invite invites an email to a league.  The User returned has an ID of 0 when nobody has signed up with it yet.
+	Invite(league int64, email string) (User, error)
+	Revoke(league int64, email string) error
+	//List is a league's invites.  Invites by email are users with an ID of 0, named by their email.
+	List(league int64) ([]User, error)
+}
+
+type SettingsRepo interface {
+	Draft(league int64) (FullDraftSettings, error)
+	SaveDraft(f FullDraftSettings) error
+	Scoring(league int64) (ScoringSettingsTotal, error)
+}
+
+type DraftRepo interface {
+	Picks(league int64) ([]Pick, error)
+	Keepers(league int64) ([]Keeper, error)
+	//Start sets the draft order, fills in keepers' picks and puts the league in its draft, all or nothing.
+	Start(league int64, order []TeamSlot, keepers []Pick) error
+	//Reset takes a league back to before its draft started: no picks, no draft order and no clock.
+	Reset(league int64) error
+}
+
+type PlayerRepo interface {
+	Get(ID int64) (scanners.Player, error)
+	Pool(q PoolQuery) ([]scanners.Player, error)
+	//Stats is a player's weekly stats, and their totals for each season.
+	Stats(ID int64) (weeks []scanners.PlayerStats, seasons []scanners.PlayerStats, err error)
+	//Owners looks for a player in each of the leagues a user has a team in, in league order.
+	Owners(user int64, ID int64) ([]Ownership, error)
+}
+
+type RoleRepo interface {
+	//Member looks a user up in a league.  It's ErrNotFound if the league isn't there, but a user with nothing to do
+	//with the league just gets a Member with nothing set.
+	Member(league int64, user int64) (Member, error)
+	List(league int64) ([]LeagueRole, error)
+	//Grant gives a user a role in a league, in place of any they had.  Permissions only mean anything for
+	//co-commissioners.  Grants, revokes and everything to do with transfers go in the league's history, under the
+	//actor who made them.
+	Grant(league int64, actor int64, user int64, role string, permissions []string) error
+	Revoke(league int64, actor int64, user int64) error
+	//Offer offers a league to a user, from whoever's commissioner now, in place of any offer already out.
+	Offer(league int64, actor int64, to int64) error
+	//Transfer is the offer out for a league, ErrNotFound if there isn't one.
+	Transfer(league int64) (Transfer, error)
+	//Cancel takes back a league's offer, and Decline turns it down for the user it went to.  Accept makes that user
+	//the commissioner, dropping any role they had.  Each is ErrNotFound without an offer to act on.
+	Cancel(league int64, actor int64) error
+	Decline(league int64, user int64) error
+	Accept(league int64, user int64) error
+	//History is the league's role changes, newest first.
+	History(league int64) ([]RoleChange, error)
+}
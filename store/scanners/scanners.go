@@ -1,5 +1,7 @@
 package scanners
 
+import "strings"
+
 // //https://stackoverflow.com/questions/53175792/how-to-make-scanning-db-rows-in-go-dry I'm not sure how much I want to implement of this,
 // //and it's likely at least a little obsolete as soon as 1.18 drops, but some informative information on implementing interfaces
 type Row interface {
@@ -7,12 +9,21 @@ type Row interface {
 }
 
 type Player struct {
-	ID                  int64
-	Name                string
-	PfbrName            string
-	Team                string
-	Position            string
-	Age                 uint8
+	ID       int64
+	Name     string
+	PfbrName string
+	Team     string
+	Position string
+	Age      uint8
+	StatLine
+	FantasyPoints     int
+	PointPerReception float64
+	ValueBased        int
+}
+
+//StatLine holds the counting stats we track for a player, whether that's over a week, a season or the
+//summary on the player table.  Embedding it keeps Player's JSON flat, so the front end doesn't notice.
+type StatLine struct {
 	Games               uint
 	Starts              uint
 	PassCompletions     uint
@@ -32,40 +43,130 @@ type Player struct {
 	AllTouchdowns       uint
 	TwoPointConversion  uint
 	TwoPointPass        uint
-	FantasyPoints       int
-	PointPerReception   float64
-	ValueBased          int
+}
+
+//Score applies a league's offensive scoring to a stat line.  We don't track sacks taken yet, so PassSack
+//is ignored, and misc touchdowns are whatever is left of AllTouchdowns after rushing and receiving scores.
+func (l *StatLine) Score(s *ScoringSettingsOff) float64 {
+	misc := int(l.AllTouchdowns) - int(l.RushTouchdowns) - int(l.ReceivingTouchdowns)
+	if misc < 0 {
+		misc = 0
+	}
+	return s.PassAttempt*float64(l.PassAttempts) +
+		s.PassCompletion*float64(l.PassCompletions) +
+		s.PassYard*float64(l.PassYards) +
+		s.PassTouchdown*float64(l.PassTouchdowns) +
+		s.PassInterception*float64(l.PassInterceptions) +
+		s.RushAttempt*float64(l.RushAttempts) +
+		s.RushYard*float64(l.RushYards) +
+		s.RushTouchdown*float64(l.RushTouchdowns) +
+		s.ReceivingTarget*float64(l.Targets) +
+		s.Reception*float64(l.Receptions) +
+		s.ReceivingYard*float64(l.ReceivingYards) +
+		s.ReceivingTouchdown*float64(l.ReceivingTouchdowns) +
+		s.Fumble*float64(l.Fumbles) +
+		s.FumbleLost*float64(l.FumblesLost) +
+		s.MiscTouchdown*float64(misc) +
+		s.TwoPointConversion*float64(l.TwoPointConversion) +
+		s.TwoPointPass*float64(l.TwoPointPass)
+}
+
+//Scans the stat columns, in table order.  Used by both the player table and player_stats.
+func (l *StatLine) fields() []interface{} {
+	return []interface{}{&l.Games,
+		&l.Starts,
+		&l.PassCompletions,
+		&l.PassAttempts,
+		&l.PassYards,
+		&l.PassTouchdowns,
+		&l.PassInterceptions,
+		&l.RushAttempts,
+		&l.RushYards,
+		&l.RushTouchdowns,
+		&l.Targets,
+		&l.Receptions,
+		&l.ReceivingYards,
+		&l.ReceivingTouchdowns,
+		&l.Fumbles,
+		&l.FumblesLost,
+		&l.AllTouchdowns,
+		&l.TwoPointConversion,
+		&l.TwoPointPass}
+}
+
+//PlayerStats is a row of player_stats.  Week 0 is used for season totals.
+type PlayerStats struct {
+	Player int64
+	Season int
+	Week   int
+	StatLine
+}
+
+func (p *PlayerStats) ScanRow(r Row) error {
+	dest := []interface{}{&p.Player, &p.Season, &p.Week}
+	return r.Scan(append(dest, p.StatLine.fields()...)...)
 }
 
 func (p *Player) ScanRow(r Row) error {
-	return r.Scan(&p.ID,
+	dest := []interface{}{&p.ID,
 		&p.Name,
 		&p.PfbrName,
 		&p.Team,
 		&p.Position,
-		&p.Age,
-		&p.Games,
-		&p.Starts,
-		&p.PassCompletions,
-		&p.PassAttempts,
-		&p.PassYards,
-		&p.PassTouchdowns,
-		&p.PassInterceptions,
-		&p.RushAttempts,
-		&p.RushYards,
-		&p.RushTouchdowns,
-		&p.Targets,
-		&p.Receptions,
-		&p.ReceivingYards,
-		&p.ReceivingTouchdowns,
-		&p.Fumbles,
-		&p.FumblesLost,
-		&p.AllTouchdowns,
-		&p.TwoPointConversion,
-		&p.TwoPointPass,
+		&p.Age}
+	dest = append(dest, p.StatLine.fields()...)
+	return r.Scan(append(dest,
 		&p.FantasyPoints,
 		&p.PointPerReception,
-		&p.ValueBased)
+		&p.ValueBased)...)
+}
+
+//PlayerColumns maps the fields of Player to their columns on the player table, in the same order
+//ScanRow expects them.  We use it to whitelist sort keys from the query string, since we can't bind
+//a column name as a parameter.
+var PlayerColumns = []struct {
+	Field  string
+	Column string
+}{
+	{"ID", "ID"},
+	{"Name", "name"},
+	{"PfbrName", "pfbr_name"},
+	{"Team", "team"},
+	{"Position", "position"},
+	{"Age", "age"},
+	{"Games", "games"},
+	{"Starts", "starts"},
+	{"PassCompletions", "pass_completions"},
+	{"PassAttempts", "pass_attempts"},
+	{"PassYards", "pass_yards"},
+	{"PassTouchdowns", "pass_touchdowns"},
+	{"PassInterceptions", "pass_interceptions"},
+	{"RushAttempts", "rush_attempts"},
+	{"RushYards", "rush_yards"},
+	{"RushTouchdowns", "rush_touchdowns"},
+	{"Targets", "targets"},
+	{"Receptions", "receptions"},
+	{"ReceivingYards", "receiving_yards"},
+	{"ReceivingTouchdowns", "receiving_touchdowns"},
+	{"Fumbles", "fumbles"},
+	{"FumblesLost", "fumbles_lost"},
+	{"AllTouchdowns", "all_touchdowns"},
+	{"TwoPointConversion", "two_point_conversion"},
+	{"TwoPointPass", "two_point_pass"},
+	{"FantasyPoints", "fantasy_points"},
+	{"PointPerReception", "point_per_reception"},
+	{"ValueBased", "value_based"},
+}
+
+//PlayerColumn returns the player table column for a Player field name.  Matching is case insensitive,
+//so the front end can pass along the keys it already has from the JSON.
+func PlayerColumn(field string) (string, bool) {
+	for _, c := range PlayerColumns {
+		if strings.EqualFold(c.Field, field) {
+			return c.Column, true
+		}
+	}
+	return "", false
 }
 
 type PlayerList struct {
@@ -100,6 +201,38 @@ func (s *PositionalSettings) CountPositions() int {
 	return s.QB + s.RB + s.WR + s.TE + s.Flex + s.Bench + s.Superflex + s.Def + s.K
 }
 
+//CanRoster checks whether a team with players at the given positions can fit them all into a lineup.
+//Each position fills its own starting spots first, then running backs, receivers and tight ends can
+//spill into flex, anybody but defense and kickers can spill into superflex, and everyone can sit on the bench.
+func (s *PositionalSettings) CanRoster(positions []string) bool {
+	counts := make(map[string]int)
+	for _, p := range positions {
+		counts[strings.ToUpper(p)]++
+	}
+	over := func(count int, slots int) int {
+		if count > slots {
+			return count - slots
+		}
+		return 0
+	}
+	qb := over(counts["QB"], s.QB)
+	flexible := over(counts["RB"], s.RB) + over(counts["WR"], s.WR) + over(counts["TE"], s.TE)
+	benchOnly := over(counts["DEF"], s.Def) + over(counts["K"], s.K)
+	for p, count := range counts {
+		switch p {
+		case "QB", "RB", "WR", "TE", "DEF", "K":
+		default:
+			benchOnly += count
+		}
+	}
+
+	if benchOnly > s.Bench {
+		return false
+	}
+	bench := s.Bench - benchOnly
+	return qb <= s.Superflex+bench && qb+flexible <= s.Flex+s.Superflex+bench
+}
+
 func (s *PositionalSettings) ScanRow(r Row) error {
 	return r.Scan(&s.ID,
 		&s.Kind,
@@ -212,3 +345,18 @@ func (s *ScoringSettingsOff) ScanRow(r Row) error {
 		&s.TwoPointConversion,
 		&s.TwoPointPass)
 }
+
+type KeeperSettings struct {
+	ID             int
+	Keepers        int
+	RoundCost      int
+	UndraftedRound int
+}
+
+func (s *KeeperSettings) ScanRow(r Row) error {
+	return r.Scan(
+		&s.ID,
+		&s.Keepers,
+		&s.RoundCost,
+		&s.UndraftedRound)
+}
@@ -0,0 +1,103 @@
+package store
+
+import (
+	"strings"
+)
+
+//SplitSQL breaks a script into statements the way the mysql client would.  Semicolons only end a statement outside
+//of quotes and comments, and a DELIMITER line swaps the semicolon for something else, for the sake of triggers and
+//procedures that have semicolons of their own.  Comments are dropped, except /*! */ ones, which MySQL runs.
+func SplitSQL(script string) []string {
+	var statements []string
+	var current strings.Builder
+	delimiter := ";"
+	lineStart := true
+
+	flush := func() {
+		if s := strings.TrimSpace(current.String()); s != "" {
+			statements = append(statements, s)
+		}
+		current.Reset()
+	}
+
+	for i := 0; i < len(script); {
+		if lineStart {
+			//DELIMITER only counts at the start of a line, and takes the rest of it.
+			rest := strings.TrimLeft(script[i:], " \t")
+			if len(rest) > 10 && strings.EqualFold(rest[:10], "DELIMITER ") {
+				end := strings.IndexByte(rest, '\n')
+				if end < 0 {
+					end = len(rest)
+				}
+				flush()
+				delimiter = strings.TrimSpace(rest[10:end])
+				i += len(script[i:]) - len(rest) + end
+				continue
+			}
+		}
+
+		c := script[i]
+		lineStart = c == '\n'
+		switch {
+		case strings.HasPrefix(script[i:], delimiter):
+			flush()
+			i += len(delimiter)
+
+		case c == '\'' || c == '"' || c == '`':
+			end := quoteEnd(script, i)
+			current.WriteString(script[i:end])
+			i = end
+
+		case c == '#' || (strings.HasPrefix(script[i:], "--") && (i+2 == len(script) || strings.ContainsRune(" \t\r\n", rune(script[i+2])))):
+			//Line comments run up to, but not including, the newline.
+			end := strings.IndexByte(script[i:], '\n')
+			if end < 0 {
+				i = len(script)
+			} else {
+				i += end
+			}
+
+		case strings.HasPrefix(script[i:], "/*"):
+			end := strings.Index(script[i+2:], "*/")
+			if end < 0 {
+				end = len(script)
+			} else {
+				end += i + 4
+			}
+			if strings.HasPrefix(script[i:], "/*!") {
+				current.WriteString(script[i:end])
+			} else {
+				//Keep the words on either side apart.
+				current.WriteByte(' ')
+			}
+			i = end
+
+		default:
+			current.WriteByte(c)
+			i++
+		}
+	}
+	flush()
+	return statements
+}
+
+//quoteEnd finds where the quoted string starting at start ends.  Quotes are escaped by doubling them up, or with a
+//backslash outside of backticks.
+func quoteEnd(script string, start int) int {
+	quote := script[start]
+	for i := start + 1; i < len(script); i++ {
+		switch script[i] {
+		case '\\':
+			if quote != '`' {
+				i++
+			}
+		case quote:
+			if i+1 < len(script) && script[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return len(script)
+}
@@ -0,0 +1,810 @@
+package store
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+
+	"github.com/PhiloTFarnsworth/FantasySportsAF/store/scanners"
+)
+
+//RookieClause limits a player query to rookies: players without a player_stats row from any season before the
+//league's.  A player whose stats only start in the league's season is as much a rookie as one with none at all.
+const RookieClause = "ID NOT IN (SELECT player FROM player_stats WHERE season < (SELECT season FROM league WHERE ID=?))"
+
+//NewSQL builds the repositories on a database handle, usually GetDB().
+func NewSQL(db *sql.DB) Repos {
+	return Repos{
+		Users:    sqlUsers{db},
+		Leagues:  sqlLeagues{db},
+		Teams:    sqlTeams{db},
+		Invites:  sqlInvites{db},
+		Settings: sqlSettings{db},
+		Drafts:   sqlDrafts{db},
+		Players:  sqlPlayers{db},
+		Roles:    sqlRoles{db},
+		DB:       db,
+	}
+}
+
+//notFound swaps sql.ErrNoRows for ErrNotFound, so callers don't have to know which backend they've got.
+func notFound(err error) error {
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	return err
+}
+
+type sqlUsers struct{ db *sql.DB }
+
+func (r sqlUsers) ByID(ID int64) (User, error) {
+	u := User{ID: ID}
+	row := r.db.QueryRow("SELECT name, email, admin FROM user WHERE ID=?", ID)
+	return u, notFound(row.Scan(&u.Name, &u.Email, &u.Admin))
+}
+
+func (r sqlUsers) ByName(name string) (User, error) {
+	u := User{Name: name}
+	row := r.db.QueryRow("SELECT ID, passhash, email, admin FROM user WHERE name=?", name)
+	return u, notFound(row.Scan(&u.ID, &u.Passhash, &u.Email, &u.Admin))
+}
+
+func (r sqlUsers) Create(name string, email string, passhash []byte) (User, error) {
+	u := User{Name: name, Email: email}
+	tx, err := r.db.Begin()
+	if err != nil {
+		return u, err
+	}
+	defer tx.Rollback()
+	if u.ID, err = InsertID(tx, "INSERT INTO user (name, passhash, email) VALUES (?,?,?)", name, passhash, email); err != nil {
+		return u, err
+	}
+	_, err = tx.Exec("UPDATE invites SET user=?, email=NULL WHERE email=? AND user IS NULL", u.ID, email)
+	if err != nil {
+		return u, err
+	}
+	return u, tx.Commit()
+}
+
+func (r sqlUsers) SetPassword(ID int64, passhash []byte) error {
+	_, err := r.db.Exec("UPDATE user SET passhash=? WHERE ID=?", passhash, ID)
+	return err
+}
+
+func (r sqlUsers) SetAdmin(ID int64, admin bool) error {
+	_, err := r.db.Exec("UPDATE user SET admin=? WHERE ID=?", admin, ID)
+	return err
+}
+
+type sqlLeagues struct{ db *sql.DB }
+
+func (r sqlLeagues) Create(name string, commissioner int64, maxOwner int64, season int, team string) (int64, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	ID, err := InsertID(tx, "INSERT INTO league (name, commissioner, maxOwner, season) VALUES (?,?,?,?)", name, commissioner, maxOwner, season)
+	if err != nil {
+		return 0, err
+	}
+	//Every settings table starts a league off with its defaults.
+	for _, table := range []string{"draft_settings", "positional_settings", "scoring_settings_offense",
+		"scoring_settings_defense", "scoring_settings_special", "keeper_settings"} {
+		if _, err = tx.Exec("INSERT INTO "+table+" (ID) VALUES (?)", ID); err != nil {
+			return 0, err
+		}
+	}
+	if err = addTeam(tx, ID, team, commissioner); err != nil {
+		return 0, err
+	}
+	return ID, tx.Commit()
+}
+
+//addTeam puts a new team in a league.  Team IDs count up from 1 within each league, so we work out the next one
+//first.  Two teams joining at once can land on the same one, but the primary key turns the second away.
+func addTeam(tx *sql.Tx, league int64, name string, manager int64) error {
+	var ID int64
+	row := tx.QueryRow("SELECT COALESCE(MAX(ID), 0)+1 FROM teams WHERE league=?", league)
+	if err := row.Scan(&ID); err != nil {
+		return err
+	}
+	_, err := tx.Exec("INSERT INTO teams (league, ID, name, manager) VALUES (?,?,?,?)", league, ID, name, manager)
+	return err
+}
+
+func (r sqlLeagues) Get(ID int64) (League, error) {
+	l := League{ID: ID}
+	row := r.db.QueryRow(`SELECT league.name, league.state, league.maxOwner, league.kind, league.season,
+		user.ID, user.name, user.email FROM league JOIN user ON league.commissioner=user.ID
+		WHERE league.ID=?`, ID)
+	err := row.Scan(&l.Name, &l.State, &l.MaxOwner, &l.Kind, &l.Season, &l.Commissioner.ID, &l.Commissioner.Name, &l.Commissioner.Email)
+	return l, notFound(err)
+}
+
+func (r sqlLeagues) Managed(user int64) ([]LeagueSummary, error) {
+	return r.summaries(`SELECT league.ID, league.name, user.name FROM teams AS t
+		INNER JOIN league ON t.league=league.ID LEFT JOIN user ON league.commissioner=user.ID
+		WHERE t.manager=? ORDER BY league.ID`, user)
+}
+
+func (r sqlLeagues) Invited(user int64) ([]LeagueSummary, error) {
+	return r.summaries(`SELECT league.ID, league.name, user.name FROM invites AS i
+		INNER JOIN league ON i.league=league.ID LEFT JOIN user ON league.commissioner=user.ID
+		WHERE i.user=? ORDER BY league.ID`, user)
+}
+
+func (r sqlLeagues) summaries(query string, user int64) ([]LeagueSummary, error) {
+	var leagues []LeagueSummary
+	rows, err := r.db.Query(query, user)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var l LeagueSummary
+		if err = rows.Scan(&l.ID, &l.Name, &l.Commissioner); err != nil {
+			return nil, err
+		}
+		leagues = append(leagues, l)
+	}
+	return leagues, rows.Err()
+}
+
+func (r sqlLeagues) Update(s LeagueSettings) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	var teams int64
+	row := tx.QueryRow("SELECT COUNT(*) FROM teams WHERE league=?", s.ID)
+	if err = row.Scan(&teams); err != nil {
+		return err
+	}
+	if s.MaxOwner < teams {
+		return ErrTooManyTeams
+	}
+	_, err = tx.Exec("UPDATE league SET name=?, maxOwner=?, kind=? WHERE ID=?", s.Name, s.MaxOwner, s.Kind, s.ID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r sqlLeagues) SetState(ID int64, state string) error {
+	_, err := r.db.Exec("UPDATE league SET state=? WHERE ID=?", state, ID)
+	return err
+}
+
+type sqlTeams struct{ db *sql.DB }
+
+func (r sqlTeams) List(league int64) ([]Team, error) {
+	var teams []Team
+	rows, err := r.db.Query(`SELECT t.ID, t.name, t.slot, user.ID, user.name, user.email FROM teams AS t
+		JOIN user ON t.manager=user.ID WHERE t.league=? ORDER BY t.ID`, league)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var t Team
+		if err = rows.Scan(&t.ID, &t.Name, &t.Slot, &t.Manager.ID, &t.Manager.Name, &t.Manager.Email); err != nil {
+			return nil, err
+		}
+		teams = append(teams, t)
+	}
+	return teams, rows.Err()
+}
+
+func (r sqlTeams) Get(league int64, ID int64) (Team, error) {
+	t := Team{ID: ID}
+	row := r.db.QueryRow(`SELECT t.name, t.slot, user.ID, user.name, user.email FROM teams AS t
+		JOIN user ON t.manager=user.ID WHERE t.league=? AND t.ID=?`, league, ID)
+	return t, notFound(row.Scan(&t.Name, &t.Slot, &t.Manager.ID, &t.Manager.Name, &t.Manager.Email))
+}
+
+func (r sqlTeams) Rename(league int64, ID int64, name string) error {
+	_, err := r.db.Exec("UPDATE teams SET name=? WHERE league=? AND ID=?", name, league, ID)
+	return err
+}
+
+func (r sqlTeams) Join(league int64, name string, manager int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var maxOwner, teams int64
+	row := tx.QueryRow("SELECT maxOwner FROM league WHERE ID=?", league)
+	if err = row.Scan(&maxOwner); err != nil {
+		return notFound(err)
+	}
+	row = tx.QueryRow("SELECT COUNT(*) FROM teams WHERE league=?", league)
+	if err = row.Scan(&teams); err != nil {
+		return err
+	}
+	//This should only happen if a commissioner invites every slot, then shrinks the league.
+	if teams >= maxOwner {
+		return ErrLeagueFull
+	}
+	if err = addTeam(tx, league, name, manager); err != nil {
+		return err
+	}
+	if _, err = tx.Exec("DELETE FROM invites WHERE league=? AND user=?", league, manager); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+type sqlInvites struct{ db *sql.DB }
+
+func (r sqlInvites) Invite(league int64, email string) (User, error) {
+	u := User{Email: email}
+	row := r.db.QueryRow("SELECT ID, name FROM user WHERE email=?", email)
+	err := row.Scan(&u.ID, &u.Name)
+	if err == sql.ErrNoRows {
+		//They'll find the invite waiting once they sign up.
+		_, err = r.db.Exec("INSERT INTO invites (league, email) VALUES (?,?)", league, email)
+		return u, err
+	} else if err != nil {
+		return u, err
+	}
+	_, err = r.db.Exec("INSERT INTO invites (league, user) VALUES (?,?)", league, u.ID)
+	return u, err
+}
+
+func (r sqlInvites) Revoke(league int64, email string) error {
+	var user int64
+	row := r.db.QueryRow("SELECT ID FROM user WHERE email=?", email)
+	err := row.Scan(&user)
+	if err == sql.ErrNoRows {
+		//No account means the invite only has their email.
+		_, err = r.db.Exec("DELETE FROM invites WHERE league=? AND email=? AND user IS NULL", league, email)
+	} else if err == nil {
+		_, err = r.db.Exec("DELETE FROM invites WHERE league=? AND user=?", league, user)
+	}
+	return err
+}
+
+func (r sqlInvites) List(league int64) ([]User, error) {
+	var invites []User
+	rows, err := r.db.Query(`SELECT COALESCE(user.ID, 0), COALESCE(user.name, i.email), COALESCE(user.email, i.email)
+		FROM invites AS i LEFT JOIN user ON i.user=user.ID WHERE i.league=? ORDER BY i.user IS NULL, i.ID`, league)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var u User
+		if err = rows.Scan(&u.ID, &u.Name, &u.Email); err != nil {
+			return nil, err
+		}
+		invites = append(invites, u)
+	}
+	return invites, rows.Err()
+}
+
+type sqlSettings struct{ db *sql.DB }
+
+func (r sqlSettings) Draft(league int64) (FullDraftSettings, error) {
+	var f FullDraftSettings
+	row := r.db.QueryRow("SELECT * FROM draft_settings WHERE ID=?", league)
+	if err := row.Scan(&f.D.ID, &f.D.Kind, &f.D.DraftOrder, &f.D.Time, &f.D.DraftClock, &f.D.Rounds, &f.D.Pace); err != nil {
+		return f, notFound(err)
+	}
+	row = r.db.QueryRow("SELECT * FROM positional_settings WHERE ID=?", league)
+	if err := f.P.ScanRow(row); err != nil {
+		return f, notFound(err)
+	}
+	var err error
+	f.S, err = r.Scoring(league)
+	return f, err
+}
+
+func (r sqlSettings) Scoring(league int64) (ScoringSettingsTotal, error) {
+	var t ScoringSettingsTotal
+	row := r.db.QueryRow("SELECT * FROM scoring_settings_offense WHERE ID=?", league)
+	if err := t.O.ScanRow(row); err != nil {
+		return t, notFound(err)
+	}
+	row = r.db.QueryRow("SELECT * FROM scoring_settings_defense WHERE ID=?", league)
+	if err := t.D.ScanRow(row); err != nil {
+		return t, notFound(err)
+	}
+	row = r.db.QueryRow("SELECT * FROM scoring_settings_special WHERE ID=?", league)
+	return t, notFound(t.S.ScanRow(row))
+}
+
+func (r sqlSettings) SaveDraft(f FullDraftSettings) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`UPDATE positional_settings SET
+		kind=?, qb=?, rb=?, wr=?, te=?, flex=?, bench=?, superflex=?, def=?, k=?
+		WHERE ID=?`,
+		f.P.Kind, f.P.QB, f.P.RB, f.P.WR, f.P.TE, f.P.Flex, f.P.Bench, f.P.Superflex, f.P.Def, f.P.K,
+		f.P.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE scoring_settings_offense SET
+		pass_att=?, pass_comp=?, pass_yard=?, pass_td=?, pass_int=?, pass_sack=?,
+		rush_att=?, rush_yard=?, rush_td=?, rec_tar=?, rec=?, rec_yard=?, rec_td=?,
+		fum=?, fum_lost=?, misc_td=?, two_point=?, two_point_pass=?
+		WHERE ID=?`,
+		f.S.O.PassAttempt, f.S.O.PassCompletion, f.S.O.PassYard, f.S.O.PassTouchdown, f.S.O.PassInterception, f.S.O.PassSack,
+		f.S.O.RushAttempt, f.S.O.RushYard, f.S.O.RushTouchdown, f.S.O.ReceivingTarget, f.S.O.Reception, f.S.O.ReceivingYard, f.S.O.ReceivingTouchdown,
+		f.S.O.Fumble, f.S.O.FumbleLost, f.S.O.MiscTouchdown, f.S.O.TwoPointConversion, f.S.O.TwoPointPass,
+		f.S.O.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE scoring_settings_defense SET
+		touchdown=?, sack=?, interception=?, safety=?, shutout=?,
+		points_6=?, points_13=?, points_20=?, points_27=?, points_34=?, points_35=?,
+		yardBonus=?, yards=?
+		WHERE ID=?`,
+		f.S.D.Touchdown, f.S.D.Sack, f.S.D.Interception, f.S.D.Safety, f.S.D.Shutout,
+		f.S.D.Points6, f.S.D.Points13, f.S.D.Points20, f.S.D.Points27, f.S.D.Points34, f.S.D.Points35,
+		f.S.D.YardBonus, f.S.D.Yards,
+		f.S.D.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE scoring_settings_special SET
+		fg_29=?, fg_39=?, fg_49=?, fg_50=?, extra_point=?
+		WHERE ID=?`,
+		f.S.S.Fg29, f.S.S.Fg39, f.S.S.Fg49, f.S.S.Fg50, f.S.S.ExtraPoint,
+		f.S.S.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE draft_settings SET
+		kind=?, draftOrder=?, time=?, draftClock=?, rounds=?, pace=?
+		WHERE ID=?`,
+		f.D.Kind, f.D.DraftOrder, f.D.Time, f.D.DraftClock, f.D.Rounds, f.D.Pace,
+		f.D.ID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+type sqlDrafts struct{ db *sql.DB }
+
+func (r sqlDrafts) Picks(league int64) ([]Pick, error) {
+	picks := make([]Pick, 0)
+	rows, err := r.db.Query("SELECT ID, player, team FROM draft_picks WHERE league=? ORDER BY ID", league)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var p Pick
+		if err = rows.Scan(&p.Slot, &p.Player, &p.Team); err != nil {
+			return nil, err
+		}
+		picks = append(picks, p)
+	}
+	return picks, rows.Err()
+}
+
+func (r sqlDrafts) Keepers(league int64) ([]Keeper, error) {
+	var keepers []Keeper
+	rows, err := r.db.Query("SELECT team, player, round FROM keepers WHERE league=?", league)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var k Keeper
+		if err = rows.Scan(&k.Team, &k.Player, &k.Round); err != nil {
+			return nil, err
+		}
+		keepers = append(keepers, k)
+	}
+	return keepers, rows.Err()
+}
+
+func (r sqlDrafts) Start(league int64, order []TeamSlot, keepers []Pick) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, o := range order {
+		if _, err = tx.Exec("UPDATE teams SET slot=? WHERE league=? AND ID=?", o.Slot, league, o.Team); err != nil {
+			return err
+		}
+	}
+	for _, k := range keepers {
+		_, err = tx.Exec("INSERT INTO draft_picks (league, ID, player, team) VALUES (?,?,?,?)", league, k.Slot, k.Player, k.Team)
+		if err != nil {
+			return err
+		}
+	}
+	if _, err = tx.Exec("UPDATE league SET state='DRAFT' WHERE ID=?", league); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r sqlDrafts) Reset(league int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	var ID int64
+	if err = tx.QueryRow("SELECT ID FROM league WHERE ID=?", league).Scan(&ID); err != nil {
+		return notFound(err)
+	}
+	//The draft room's events go too, so nobody replays picks that are gone.  Chat, and supplemental drafts, stay.
+	reset := []struct {
+		query string
+		args  []interface{}
+	}{
+		{"DELETE FROM draft_picks WHERE league=?", []interface{}{league}},
+		{"DELETE FROM draft_clocks WHERE league=?", []interface{}{league}},
+		{"DELETE FROM draft_events WHERE league=? AND room=?", []interface{}{league, strconv.FormatInt(league, 10)}},
+		{"UPDATE teams SET slot=0 WHERE league=?", []interface{}{league}},
+		{"UPDATE league SET state='PREDRAFT' WHERE ID=?", []interface{}{league}},
+	}
+	for _, q := range reset {
+		if _, err = tx.Exec(q.query, q.args...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+type sqlPlayers struct{ db *sql.DB }
+
+func (r sqlPlayers) Get(ID int64) (scanners.Player, error) {
+	var p scanners.Player
+	row := r.db.QueryRow("SELECT * FROM player WHERE ID=?", ID)
+	return p, notFound(p.ScanRow(row))
+}
+
+func (r sqlPlayers) Pool(q PoolQuery) ([]scanners.Player, error) {
+	var where []string
+	var args []interface{}
+	if q.Position != "" {
+		where = append(where, "position = ?")
+		args = append(args, q.Position)
+	}
+	if q.Team != "" {
+		where = append(where, "team = ?")
+		args = append(args, q.Team)
+	}
+	if q.Search != "" {
+		//MySQL ignores case in LIKE, postgres doesn't.
+		where = append(where, "LOWER(name) LIKE LOWER(?)")
+		args = append(args, "%"+q.Search+"%")
+	}
+	if q.League != 0 {
+		where = append(where, "ID NOT IN (SELECT player FROM draft_picks WHERE league=?)",
+			"ID NOT IN (SELECT player FROM rosters WHERE league=?)")
+		args = append(args, q.League, q.League)
+		if q.Rookies {
+			where = append(where, RookieClause)
+			args = append(args, q.League)
+		}
+	}
+
+	sortColumn := "ID"
+	if q.Sort != "" {
+		sortColumn = q.Sort
+	}
+	direction, comparison := "ASC", ">"
+	if q.Desc {
+		direction, comparison = "DESC", "<"
+	}
+	//We tie break on ID so that pages stay stable even when a whole bunch of players share a value (looking at you,
+	//kickers).
+	if q.After != nil {
+		where = append(where, "("+sortColumn+" "+comparison+" ? OR ("+sortColumn+" = ? AND ID "+comparison+" ?))")
+		args = append(args, q.After.Value, q.After.Value, q.After.ID)
+	}
+
+	query := "SELECT * FROM player"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY " + sortColumn + " " + direction
+	if sortColumn != "ID" {
+		query += ", ID " + direction
+	}
+	if q.Limit > 0 {
+		query += " LIMIT " + strconv.Itoa(q.Limit)
+	}
+
+	var p scanners.PlayerList
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err = p.ScanRow(rows); err != nil {
+			return nil, err
+		}
+	}
+	return p.Players, rows.Err()
+}
+
+func (r sqlPlayers) Stats(ID int64) ([]scanners.PlayerStats, []scanners.PlayerStats, error) {
+	weeks, err := r.stats("SELECT * FROM player_stats WHERE player=? AND week > 0 ORDER BY season, week", ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	//Season totals are the sum of the weeks, unless all we have for a season is the week 0 summary.
+	seasons, err := r.stats(`SELECT player, season, 0,
+		SUM(games), SUM(starts), SUM(pass_completions), SUM(pass_attempts), SUM(pass_yards),
+		SUM(pass_touchdowns), SUM(pass_interceptions), SUM(rush_attempts), SUM(rush_yards), SUM(rush_touchdowns),
+		SUM(targets), SUM(receptions), SUM(receiving_yards), SUM(receiving_touchdowns), SUM(fumbles),
+		SUM(fumbles_lost), SUM(all_touchdowns), SUM(two_point_conversion), SUM(two_point_pass)
+		FROM player_stats AS ps WHERE player=? AND (week > 0 OR NOT EXISTS
+			(SELECT 1 FROM player_stats WHERE player=ps.player AND season=ps.season AND week > 0))
+		GROUP BY player, season ORDER BY season`, ID)
+	return weeks, seasons, err
+}
+
+func (r sqlPlayers) stats(query string, ID int64) ([]scanners.PlayerStats, error) {
+	stats := make([]scanners.PlayerStats, 0)
+	rows, err := r.db.Query(query, ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var s scanners.PlayerStats
+		if err = s.ScanRow(rows); err != nil {
+			return nil, err
+		}
+		stats = append(stats, s)
+	}
+	return stats, rows.Err()
+}
+
+func (r sqlPlayers) Owners(user int64, ID int64) ([]Ownership, error) {
+	owners := make([]Ownership, 0)
+	rows, err := r.db.Query("SELECT league.ID, league.name FROM teams AS t INNER JOIN league ON t.league=league.ID WHERE t.manager=? ORDER BY league.ID", user)
+	if err != nil {
+		return nil, err
+	}
+	//Same busy buffer problem as register, so we collect the leagues before we go looking in them.
+	for rows.Next() {
+		var o Ownership
+		if err = rows.Scan(&o.League, &o.Name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		owners = append(owners, o)
+	}
+	rows.Close()
+
+	for i, o := range owners {
+		row := r.db.QueryRow(`SELECT t.ID, t.name FROM teams AS t WHERE t.league=? AND t.ID IN
+			(SELECT team FROM rosters WHERE league=? AND player=? UNION SELECT team FROM draft_picks WHERE league=? AND player=?)`,
+			o.League, o.League, ID, o.League, ID)
+		if err = row.Scan(&owners[i].Team, &owners[i].TeamName); err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+	}
+	return owners, nil
+}
+
+type sqlRoles struct{ db *sql.DB }
+
+func (r sqlRoles) Member(league int64, user int64) (Member, error) {
+	m := Member{League: league, User: user}
+	var commissioner, invited int64
+	var permissions string
+	row := r.db.QueryRow(`SELECT l.commissioner, COALESCE(u.admin, FALSE),
+		COALESCE((SELECT ID FROM teams WHERE league=l.ID AND manager=?), 0),
+		(SELECT COUNT(*) FROM invites WHERE league=l.ID AND user=?),
+		COALESCE(g.role, ''), COALESCE(g.permissions, '')
+		FROM league AS l LEFT JOIN user AS u ON u.ID=? LEFT JOIN league_roles AS g ON g.league=l.ID AND g.user=?
+		WHERE l.ID=?`, user, user, user, user, league)
+	if err := row.Scan(&commissioner, &m.Admin, &m.Team, &invited, &m.Role, &permissions); err != nil {
+		return m, notFound(err)
+	}
+	m.Commissioner = commissioner == user
+	m.Invited = invited > 0
+	m.Permissions = splitPermissions(permissions)
+	return m, nil
+}
+
+//Permissions are kept comma separated in the database.
+func splitPermissions(permissions string) []string {
+	if permissions == "" {
+		return []string{}
+	}
+	return strings.Split(permissions, ",")
+}
+
+func (r sqlRoles) List(league int64) ([]LeagueRole, error) {
+	roles := make([]LeagueRole, 0)
+	rows, err := r.db.Query(`SELECT u.ID, u.name, u.email, r.role, r.permissions FROM league_roles AS r
+		JOIN user AS u ON r.user=u.ID WHERE r.league=? ORDER BY u.ID`, league)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var l LeagueRole
+		var permissions string
+		if err = rows.Scan(&l.User.ID, &l.User.Name, &l.User.Email, &l.Role, &permissions); err != nil {
+			return nil, err
+		}
+		l.Permissions = splitPermissions(permissions)
+		roles = append(roles, l)
+	}
+	return roles, rows.Err()
+}
+
+//logRole adds a line to a league's role history.
+func logRole(e Execer, league int64, actor int64, user int64, action string, role string, permissions []string) error {
+	_, err := e.Exec(`INSERT INTO league_role_changes (league, actor, user, action, role, permissions)
+		VALUES (?,?,?,?,?,?)`, league, actor, user, action, role, strings.Join(permissions, ","))
+	return err
+}
+
+func (r sqlRoles) Grant(league int64, actor int64, user int64, role string, permissions []string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	_, err = tx.Exec(Upsert("INTO league_roles (league, user, role, permissions) VALUES (?,?,?,?)",
+		[]string{"league", "user"}, []string{"role", "permissions"}), league, user, role, strings.Join(permissions, ","))
+	if err != nil {
+		return err
+	}
+	if err = logRole(tx, league, actor, user, ActionGrant, role, permissions); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r sqlRoles) Revoke(league int64, actor int64, user int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err = tx.Exec("DELETE FROM league_roles WHERE league=? AND user=?", league, user); err != nil {
+		return err
+	}
+	if err = logRole(tx, league, actor, user, ActionRevoke, "", nil); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r sqlRoles) Offer(league int64, actor int64, to int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	var commissioner int64
+	if err = tx.QueryRow("SELECT commissioner FROM league WHERE ID=?", league).Scan(&commissioner); err != nil {
+		return notFound(err)
+	}
+	//Any offer already out goes away without a CANCEL, the OFFER says well enough what happened to it.
+	if _, err = tx.Exec("DELETE FROM commissioner_transfers WHERE league=?", league); err != nil {
+		return err
+	}
+	_, err = tx.Exec("INSERT INTO commissioner_transfers (league, fromUser, toUser) VALUES (?,?,?)", league, commissioner, to)
+	if err != nil {
+		return err
+	}
+	if err = logRole(tx, league, actor, to, ActionOffer, "", nil); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r sqlRoles) Transfer(league int64) (Transfer, error) {
+	t := Transfer{League: league}
+	row := r.db.QueryRow(`SELECT f.ID, f.name, f.email, u.ID, u.name, u.email, t.created FROM commissioner_transfers AS t
+		JOIN user AS f ON t.fromUser=f.ID JOIN user AS u ON t.toUser=u.ID WHERE t.league=?`, league)
+	err := row.Scan(&t.From.ID, &t.From.Name, &t.From.Email, &t.To.ID, &t.To.Name, &t.To.Email, &t.Created)
+	return t, notFound(err)
+}
+
+//endTransfer drops a league's offer, as long as it went to user when there is one, and logs why.
+func endTransfer(tx *sql.Tx, league int64, actor int64, user int64, action string) error {
+	var from, to int64
+	row := tx.QueryRow("SELECT fromUser, toUser FROM commissioner_transfers WHERE league=?", league)
+	if err := row.Scan(&from, &to); err != nil {
+		return notFound(err)
+	}
+	if user != 0 && to != user {
+		return ErrNotFound
+	}
+	if _, err := tx.Exec("DELETE FROM commissioner_transfers WHERE league=?", league); err != nil {
+		return err
+	}
+	if action == ActionAccept {
+		to = from
+	}
+	return logRole(tx, league, actor, to, action, "", nil)
+}
+
+func (r sqlRoles) Cancel(league int64, actor int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err = endTransfer(tx, league, actor, 0, ActionCancel); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r sqlRoles) Decline(league int64, user int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err = endTransfer(tx, league, user, user, ActionDecline); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r sqlRoles) Accept(league int64, user int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err = endTransfer(tx, league, user, user, ActionAccept); err != nil {
+		return err
+	}
+	if _, err = tx.Exec("UPDATE league SET commissioner=? WHERE ID=?", user, league); err != nil {
+		return err
+	}
+	if _, err = tx.Exec("DELETE FROM league_roles WHERE league=? AND user=?", league, user); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r sqlRoles) History(league int64) ([]RoleChange, error) {
+	changes := make([]RoleChange, 0)
+	rows, err := r.db.Query(`SELECT c.ID, a.ID, a.name, a.email, u.ID, u.name, u.email, c.action, c.role, c.permissions,
+		c.created FROM league_role_changes AS c JOIN user AS a ON c.actor=a.ID JOIN user AS u ON c.user=u.ID
+		WHERE c.league=? ORDER BY c.ID DESC`, league)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var g RoleChange
+		var permissions string
+		err = rows.Scan(&g.ID, &g.Actor.ID, &g.Actor.Name, &g.Actor.Email, &g.User.ID, &g.User.Name, &g.User.Email,
+			&g.Action, &g.Role, &permissions, &g.Created)
+		if err != nil {
+			return nil, err
+		}
+		g.Permissions = splitPermissions(permissions)
+		changes = append(changes, g)
+	}
+	return changes, rows.Err()
+}
@@ -2,13 +2,15 @@ package store
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
-	"os"
 	"strings"
 
+	"github.com/PhiloTFarnsworth/FantasySportsAF/config"
 	"github.com/go-sql-driver/mysql"
+	"github.com/mattn/go-sqlite3"
 )
 
 var db *sql.DB
@@ -16,18 +18,8 @@ var db *sql.DB
 //slightly modified from the go.dev example.  We might want to utilize multiple databases, especially if we
 //decide to use our database as a backing store for our websocket chat/draft implementation.
 func ConnectDB(s string) {
-	// Capture connection properties.
-	cfg := mysql.Config{
-		User:      os.Getenv("DBUSER"),
-		Passwd:    os.Getenv("DBPASS"),
-		Net:       "tcp",
-		Addr:      "127.0.0.1:3306",
-		DBName:    s,
-		ParseTime: true,
-	}
 	// Get a database handle.
-	var err error
-	database, err := sql.Open("mysql", cfg.FormatDSN())
+	database, err := Open(s)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -40,6 +32,71 @@ func ConnectDB(s string) {
 	db = database
 }
 
+//Open picks a database from the config.  Its driver is mysql, postgres or sqlite, and its URL says where the
+//database is, in whatever form that driver takes.  Without a URL we go looking for a database called name: on MySQL
+//at the configured address as its user, on postgres wherever the PG environment variables point, and on SQLite in
+//name.db.  Open also sets the dialect everything else in store goes by.
+func Open(name string) (*sql.DB, error) {
+	c := config.Current().DB
+	url := c.URL
+	switch c.Driver {
+	case "", "mysql":
+		cfg := mysql.Config{
+			User:   c.User,
+			Passwd: c.Password,
+			Net:    "tcp",
+			Addr:   c.Addr,
+			DBName: name,
+		}
+		if url != "" {
+			parsed, err := mysql.ParseDSN(url)
+			if err != nil {
+				return nil, err
+			}
+			cfg = *parsed
+		}
+		//We scan DATETIME columns into time.Time all over the place.
+		cfg.ParseTime = true
+		dialect = MySQL
+		return sql.Open("mysql", cfg.FormatDSN())
+	case "postgres":
+		if url == "" {
+			url = "dbname=" + name
+		}
+		dialect = Postgres
+		return sql.Open(postgresDriver, url)
+	case "sqlite":
+		if url == "" {
+			url = "file:" + name + ".db"
+		}
+		//A transaction that reads before it writes can't wait out another writer the way busy_timeout waits, so
+		//transactions take the write lock when they begin.
+		if !strings.Contains(url, "_txlock=") {
+			if strings.Contains(url, "?") {
+				url += "&_txlock=immediate"
+			} else {
+				url += "?_txlock=immediate"
+			}
+		}
+		dialect = SQLite
+		return sql.Open(sqliteDriver, url)
+	}
+	return nil, errors.New("unknown database: " + c.Driver)
+}
+
+//SQLite leaves foreign keys off unless asked, and gives up straight away when another connection is writing.  Rather
+//than count on every DB_URL to ask for the right things, each connection does it as it opens.
+const sqliteDriver = "fsaf-sqlite"
+
+func init() {
+	sql.Register(sqliteDriver, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			_, err := conn.Exec("PRAGMA foreign_keys = ON; PRAGMA busy_timeout = 5000", nil)
+			return err
+		},
+	})
+}
+
 func GetDB() *sql.DB {
 	return db
 }
@@ -47,20 +104,19 @@ func GetDB() *sql.DB {
 //For testing purposes, we need a function that reads an .sql file and executes commands
 //into a database.  This mimics the sql client statement SOURCE.
 //https://stackoverflow.com/questions/38998267/how-to-execute-a-sql-file
-
-//Note: This implementation relies on comments not triggering an error when executed
 func BatchSQLFromFile(fileAddress string, db *sql.DB) error {
 	rawSQL, err := ioutil.ReadFile(fileAddress)
 	if err != nil {
 		return err
 	}
-	statements := strings.Split(string(rawSQL), ";")
-	for _, s := range statements {
-		if strings.TrimSpace(s) != "" {
-			_, err = db.Exec(s)
-			if err != nil {
-				return err
-			}
+	return BatchSQL(string(rawSQL), db)
+}
+
+//BatchSQL does the same for SQL we already have in hand, like the scripts we embed.
+func BatchSQL(rawSQL string, db *sql.DB) error {
+	for _, s := range SplitSQL(rawSQL) {
+		if _, err := db.Exec(s); err != nil {
+			return err
 		}
 	}
 	return nil
@@ -0,0 +1,60 @@
+package tests
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/PhiloTFarnsworth/FantasySportsAF/server/broker"
+)
+
+//Two instances on a broker should see each other's messages, in order, and not their own.
+func testBroker(t *testing.T, a broker.Broker, b broker.Broker) {
+	for _, room := range []string{"1", "1", "2"} {
+		if err := a.Publish(broker.Message{Room: room, Users: []int64{4}, Data: []byte(`{"Kind":"chat"}`)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, want := range []string{"1", "1", "2"} {
+		select {
+		case m := <-b.Messages():
+			if m.Room != want || len(m.Users) != 1 || m.Users[0] != 4 || string(m.Data) != `{"Kind":"chat"}` {
+				t.Errorf("message %v: want room %v got %+v", i, want, m)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("message %v never arrived", i)
+		}
+	}
+	select {
+	case m := <-a.Messages():
+		t.Errorf("publisher got its own message back: %+v", m)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestMemoryBroker(t *testing.T) {
+	bus := broker.NewBus()
+	a, b := bus.Client(), bus.Client()
+	defer a.Close()
+	defer b.Close()
+	testBroker(t, a, b)
+}
+
+//Set FSBROKERURL to a local postgres instance to run this one, e.g. postgres://localhost/fsgo?sslmode=disable
+func TestPostgresBroker(t *testing.T) {
+	url := os.Getenv("FSBROKERURL")
+	if url == "" {
+		t.Skip("FSBROKERURL not set")
+	}
+	a, err := broker.NewPostgres(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	b, err := broker.NewPostgres(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	testBroker(t, a, b)
+}
@@ -0,0 +1,63 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PhiloTFarnsworth/FantasySportsAF/config"
+)
+
+//The file goes over the defaults and the environment over the file.
+func TestConfigLoad(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.json")
+	err := os.WriteFile(file, []byte(`{"listen": ":9000", "broker": {"kind": "postgres", "url": "postgres://file"}}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("BROKER_URL", "postgres://env")
+	t.Setenv("FEATURES", "-mockdrafts")
+	c, err := config.Load(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Listen != ":9000" || c.Broker.Kind != "postgres" || c.Broker.URL != "postgres://env" {
+		t.Errorf("Got listen %v broker %v", c.Listen, c.Broker)
+	}
+	if c.Features.MockDrafts || !c.Features.InviteLinks {
+		t.Errorf("Got features %+v", c.Features)
+	}
+
+	t.Setenv("FEATURES", "mockdrafts,-nothing")
+	if _, err = config.Load(file); err == nil {
+		t.Error("Want an error for an unknown feature")
+	}
+}
+
+//Production won't run on the development secrets or without TLS.
+func TestConfigProduction(t *testing.T) {
+	c := config.Default()
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	c.Production = true
+	err := c.Validate()
+	if err == nil {
+		t.Fatal("Want production to refuse the defaults")
+	}
+	for _, want := range []string{"COOKIESECRET", "CSRFSECRET", "INVITESECRET", "TLS", "SITE_URL"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Want %v in %v", want, err)
+		}
+	}
+
+	secret := strings.Repeat("s", 32)
+	c.Secrets = config.Secrets{Cookie: secret, CSRF: secret + "c", Invite: secret + "i"}
+	c.TLS.Proxied = true
+	c.SiteURL = "https://fantasydraft.example"
+	c.DB.Password = "hunter2"
+	if err = c.Validate(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,118 @@
+package tests
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PhiloTFarnsworth/FantasySportsAF/mail"
+)
+
+func TestMailTemplates(t *testing.T) {
+	m, err := mail.Render(mail.DraftStarting, "larry@test.com", mail.DraftStartingData{League: "Larry's League", Team: "Larry's Team", Slot: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.To != "larry@test.com" || m.Subject != "The Larry's League draft is starting" {
+		t.Errorf("got %+v", m)
+	}
+	if !strings.Contains(m.Body, "Larry's Team picks 2nd") {
+		t.Errorf("got body %v", m.Body)
+	}
+	others := map[string]interface{}{
+		mail.Invite:     mail.InviteData{League: "Larry's League", Commissioner: "larry"},
+		mail.Revoke:     mail.RevokeData{League: "Larry's League"},
+		mail.OnTheClock: mail.OnTheClockData{League: "Larry's League", Pick: 3, Deadline: time.Now()},
+		mail.Trade:      mail.TradeData{League: "Larry's League", From: "Larry's Team", To: "Moe's Team", Offered: []string{"a"}, Requested: []string{"b"}},
+	}
+	for name, data := range others {
+		m, err = mail.Render(name, "larry@test.com", data)
+		if err != nil {
+			t.Errorf("%v: %v", name, err)
+		} else if m.Subject == "" || strings.Contains(m.Subject+m.Body, "<no value>") {
+			t.Errorf("%v: got %+v", name, m)
+		}
+	}
+}
+
+func TestMailFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mail.txt")
+	f := mail.NewFile(path)
+	if err := f.Send(mail.Message{To: "larry@test.com", Subject: "Hello\r\nBcc: everyone@test.com", Body: "Hi Larry"}); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "Subject: Hello Bcc: everyone@test.com\r\n") {
+		t.Errorf("subject let a header through: %q", b)
+	}
+}
+
+//flaky fails the first few sends.
+type flaky struct {
+	mu       sync.Mutex
+	failures int
+	sent     []mail.Message
+}
+
+func (f *flaky) Send(m mail.Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failures > 0 {
+		f.failures--
+		return errors.New("mail server is having a day")
+	}
+	f.sent = append(f.sent, m)
+	return nil
+}
+
+func TestMailQueue(t *testing.T) {
+	f := &flaky{failures: 1}
+	q := mail.NewQueue(f)
+	start := time.Now()
+	if err := q.Send(mail.Message{To: "larry@test.com", Subject: "Hi"}); err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(start) > 100*time.Millisecond {
+		t.Error("Send waited on the sender")
+	}
+	//Closing waits for the queue, and gives the failed message one more go.
+	q.Close()
+	if len(f.sent) != 1 {
+		t.Errorf("want 1 sent got %v", len(f.sent))
+	}
+	if err := q.Send(mail.Message{}); err != mail.ErrQueueClosed {
+		t.Errorf("want %v got %v", mail.ErrQueueClosed, err)
+	}
+}
+
+//A message that fails waits out its retry back on the queue, so the mail behind it goes out in the meantime.
+func TestMailQueueRetryLater(t *testing.T) {
+	f := &flaky{failures: 1}
+	q := mail.NewQueue(f)
+	q.Send(mail.Message{To: "larry@test.com", Subject: "First"})
+	q.Send(mail.Message{To: "garry@test.com", Subject: "Second"})
+	deadline := time.Now().Add(time.Second)
+	for {
+		f.mu.Lock()
+		sent := len(f.sent)
+		f.mu.Unlock()
+		if sent == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the second message waited on the first one's retry")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	q.Close()
+	if len(f.sent) != 2 || f.sent[0].Subject != "Second" || f.sent[1].Subject != "First" {
+		t.Errorf("got %+v want Second then First", f.sent)
+	}
+}
@@ -1,11 +1,17 @@
 package tests
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 	"net/http"
 	"os"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/PhiloTFarnsworth/FantasySportsAF/config"
+	"github.com/PhiloTFarnsworth/FantasySportsAF/mail"
 	"github.com/PhiloTFarnsworth/FantasySportsAF/server"
 	"github.com/PhiloTFarnsworth/FantasySportsAF/store"
 	"github.com/PhiloTFarnsworth/FantasySportsAF/store/playerimport"
@@ -18,33 +24,106 @@ var r *gin.Engine
 //Set up our test server and test database, add csrftoken path to create a client
 func TestMain(m *testing.M) {
 
-	//Setup for ServerSide Tests
+	//Setup for ServerSide Tests.  DB picks the backend, so DB=sqlite runs everything without a database server.
 	store.ConnectDB("testfsgo")
 	db := store.GetDB()
 	//create/clear testfsgo database
-	//./store/cleaner.sql
-	err := store.BatchSQLFromFile(os.Getenv("FSGOPATH")+"\\store\\cleaner.sql", db)
-	if err != nil {
-		fmt.Println("league batch: ", err)
-		os.Exit(1)
-	}
-	//./store/league.sql
-	err = store.BatchSQLFromFile(os.Getenv("FSGOPATH")+"\\store\\league.sql", db)
-	if err != nil {
-		fmt.Println("league batch: ", err)
+	if err := clearDB(db); err != nil {
+		fmt.Println("clear database: ", err)
 		os.Exit(1)
 	}
-	//./store/user.sql
-	err = store.BatchSQLFromFile(os.Getenv("FSGOPATH")+"\\store\\user.sql", db)
-	if err != nil {
-		fmt.Println("user batch: ", err)
+	//Build the schema, then fill the player table
+	if err := store.Migrate(db, 0); err != nil {
+		fmt.Println("migrate: ", err)
 		os.Exit(1)
 	}
-	//Import players
 	playerimport.Import("testfsgo")
 
+	server.SetSender(outbox)
 	r = server.NewRouter()
 	//Fake path to retrieve csrf token
 	r.GET("csrftoken", func(c *gin.Context) { c.String(http.StatusOK, csrf.GetToken(c)) })
 	os.Exit(m.Run())
 }
+
+//clearDB drops every table in the test database, however that's done on the backend we're using.
+func clearDB(db *sql.DB) error {
+	switch store.CurrentDialect() {
+	case store.Postgres:
+		_, err := db.Exec("DROP SCHEMA public CASCADE; CREATE SCHEMA public")
+		return err
+	case store.SQLite:
+		//Foreign keys are per connection in SQLite, so we hold on to one while they're off.
+		ctx := context.Background()
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		rows, err := conn.QueryContext(ctx, "SELECT name FROM sqlite_master WHERE type='table' AND name NOT LIKE 'sqlite_%'")
+		if err != nil {
+			return err
+		}
+		var tables []string
+		for rows.Next() {
+			var table string
+			if err = rows.Scan(&table); err != nil {
+				rows.Close()
+				return err
+			}
+			tables = append(tables, table)
+		}
+		rows.Close()
+		if _, err = conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF"); err != nil {
+			return err
+		}
+		defer conn.ExecContext(ctx, "PRAGMA foreign_keys = ON")
+		for _, table := range tables {
+			if _, err = conn.ExecContext(ctx, "DROP TABLE "+table); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	//./store/cleaner.sql
+	return store.BatchSQLFromFile(config.Current().Root+"\\store\\cleaner.sql", db)
+}
+
+//outbox keeps the mail the server sends, so tests can check who got told what.
+var outbox = &mailbox{}
+
+type mailbox struct {
+	mu   sync.Mutex
+	sent []mail.Message
+}
+
+func (m *mailbox) Send(msg mail.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+//count is how much mail has gone to someone with a subject.
+func (m *mailbox) count(to string, subject string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for _, msg := range m.sent {
+		if msg.To == to && msg.Subject == subject {
+			n++
+		}
+	}
+	return n
+}
+
+//waitForMail waits on mail to someone with a subject, since mail goes out from a queue.
+func waitForMail(t *testing.T, to string, subject string) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); outbox.count(to, subject) == 0; {
+		if time.Now().After(deadline) {
+			t.Fatalf("%v never got %q", to, subject)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -5,12 +5,17 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
 	"strconv"
 	"strings"
 	"testing"
 
+	"github.com/PhiloTFarnsworth/FantasySportsAF/server"
+	"github.com/PhiloTFarnsworth/FantasySportsAF/store"
 	"github.com/PhiloTFarnsworth/FantasySportsAF/store/scanners"
 	"github.com/gin-gonic/gin"
+	csrf "github.com/utrack/gin-csrf"
 )
 
 //We're going to keep a whole heap of tests here.  The first question, is how do we model the database
@@ -28,6 +33,7 @@ type client struct {
 var larryClient client
 var barryClient client
 var marryClient client
+var garryClient client
 
 func TestPlayers(t *testing.T) {
 	w := httptest.NewRecorder()
@@ -57,6 +63,163 @@ func TestPlayers(t *testing.T) {
 	}
 }
 
+//Page through running backs by rushing yards, making sure the filter holds and the second page picks
+//up where the first left off.
+func TestPlayersPaged(t *testing.T) {
+	type page struct {
+		Players []scanners.Player
+		Next    string
+	}
+	var pages []page
+	url := "/draftpool?position=RB&sort=RushYards&dir=desc&limit=10"
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			t.Fatalf("Bad Request: %v", err)
+		}
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("want %v got %v", http.StatusOK, w.Code)
+		}
+		var p page
+		if err = json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+			t.Fatalf("This happened: %v", err)
+		}
+		if len(p.Players) != 10 {
+			t.Fatalf("want 10 got %v players", len(p.Players))
+		}
+		if p.Next == "" {
+			t.Fatalf("wanted a cursor to the next page")
+		}
+		pages = append(pages, p)
+		url = "/draftpool?position=RB&sort=RushYards&dir=desc&limit=10&cursor=" + p.Next
+	}
+
+	if pages[0].Players[0].Name != "Derrick Henry" {
+		t.Errorf("wanted Derrick Henry, got %v", pages[0].Players[0].Name)
+	}
+	last := pages[0].Players[9]
+	for _, p := range pages[1].Players {
+		if p.Position != "RB" {
+			t.Errorf("wanted RB got %v for %v", p.Position, p.Name)
+		}
+		if p.RushYards > last.RushYards || p.ID == last.ID {
+			t.Errorf("%v (%v yards) should come after %v (%v yards)", p.Name, p.RushYards, last.Name, last.RushYards)
+		}
+	}
+}
+
+func TestPlayerDetail(t *testing.T) {
+	w := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/player/1", nil)
+	if err != nil {
+		t.Fatalf("Bad Request: %v", err)
+	}
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("want %v got %v", http.StatusOK, w.Code)
+	}
+	var d struct {
+		Player    scanners.Player `json:"player"`
+		Ownership []interface{}   `json:"ownership"`
+	}
+	if err = json.Unmarshal(w.Body.Bytes(), &d); err != nil {
+		t.Fatalf("This happened: %v", err)
+	}
+	if d.Player.Name != "Derrick Henry" {
+		t.Errorf("wanted Derrick Henry, got %v", d.Player.Name)
+	}
+	//Anonymous users don't have any leagues to own him in.
+	if len(d.Ownership) != 0 {
+		t.Errorf("wanted no ownership got %v", d.Ownership)
+	}
+
+	w = httptest.NewRecorder()
+	req, err = http.NewRequest("GET", "/player/100000", nil)
+	if err != nil {
+		t.Fatalf("Bad Request: %v", err)
+	}
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("want %v got %v", http.StatusNotFound, w.Code)
+	}
+}
+
+//The in-memory repositories run the same router, minus the handlers that still write their own SQL.
+func TestMemoryRouter(t *testing.T) {
+	m := store.NewMemory()
+	m.AddPlayer(scanners.Player{ID: 1, Name: "Derrick Henry", Position: "RB", StatLine: scanners.StatLine{RushYards: 2027, RushTouchdowns: 17}}, 0)
+	mr := server.NewRouterWithRepos(m.Repos())
+	mr.GET("csrftoken", func(c *gin.Context) { c.String(http.StatusOK, csrf.GetToken(c)) })
+	get := func(url string, cookie string, want int) *httptest.ResponseRecorder {
+		t.Helper()
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			t.Fatalf("Bad Request: %v", err)
+		}
+		req.Header.Add("Cookie", cookie)
+		mr.ServeHTTP(w, req)
+		if w.Code != want {
+			t.Fatalf("%v: want %v got %v", url, want, w.Code)
+		}
+		return w
+	}
+
+	var d struct {
+		Player    scanners.Player   `json:"player"`
+		Weeks     []interface{}     `json:"weeks"`
+		Points    float64           `json:"points"`
+		Ownership []store.Ownership `json:"ownership"`
+	}
+	if err := json.Unmarshal(get("/player/1", "", http.StatusOK).Body.Bytes(), &d); err != nil {
+		t.Fatal(err)
+	}
+	if d.Player.Name != "Derrick Henry" || d.Weeks == nil || len(d.Ownership) != 0 {
+		t.Errorf("got %+v", d)
+	}
+	get("/player/2", "", http.StatusNotFound)
+
+	a, err := getCSRF(mr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	req, err := http.NewRequest("POST", "/register", strings.NewReader(`{"username":"larry","password":"test","email":"larry@mail.com"}`))
+	if err != nil {
+		t.Fatalf("Bad Request: %v", err)
+	}
+	req.Header.Add("X-CSRF-TOKEN", a.csrf)
+	req.Header.Add("Content-Type", "Application/JSON")
+	req.Header.Add("Cookie", a.cookie)
+	mr.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("register: want %v got %v %v", http.StatusOK, w.Code, w.Body.String())
+	}
+	cookie := w.Header().Get("Set-Cookie")
+
+	//Larry keeps Henry with the first pick of his league's draft.
+	repos := m.Repos()
+	league, err := repos.Leagues.Create("Memory League", 1, 2, 2022, "Larry's team")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = repos.Drafts.Start(league, []store.TeamSlot{{Team: 1, Slot: 1}}, []store.Pick{{Slot: 0, Player: 1, Team: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	stringID := strconv.FormatInt(league, 10)
+	d.Ownership = nil
+	if err = json.Unmarshal(get("/player/1?league="+stringID, cookie, http.StatusOK).Body.Bytes(), &d); err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Ownership) != 1 || d.Ownership[0].TeamName != "Larry's team" || d.Points == 0 {
+		t.Errorf("got ownership %+v and %v points", d.Ownership, d.Points)
+	}
+	get("/league/home/"+stringID, cookie, http.StatusOK)
+	get("/league/chat/"+stringID, cookie, http.StatusNotFound)
+}
+
 func TestAnonIndex(t *testing.T) {
 	w := httptest.NewRecorder()
 	req, err := http.NewRequest("GET", "/", nil)
@@ -74,6 +237,20 @@ func TestAnonIndex(t *testing.T) {
 	}
 }
 
+//Anonymous users should be turned away before we ever upgrade the connection.
+func TestAnonDraftSocket(t *testing.T) {
+	w := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/ws/draft/1", nil)
+	if err != nil {
+		t.Fatalf("Bad Request: %v", err)
+	}
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("want %v got %v", http.StatusUnauthorized, w.Code)
+	}
+}
+
 func TestRegister(t *testing.T) {
 	//Larry and better larry
 	larry := `{"username":"larry","password":"test","email":"larry@mail.com"}`
@@ -258,12 +435,20 @@ func TestInviteUnregistered(t *testing.T) {
 	}
 }
 
-//NOTE: Bad attempts to register trigger auto-increment.  I guess this is a feature, so an explanation in case someone was wondering why barryV2 has an
-//ID of 5.
+//NOTE: Bad attempts to register trigger auto-increment on MySQL and postgres, but not SQLite, so barry is user 5 on
+//the first two and 2 on SQLite.  userID looks it up rather than guess.
+func userID(t *testing.T, name string) string {
+	var ID int64
+	row := store.GetDB().QueryRow("SELECT ID FROM user WHERE name=?", name)
+	if err := row.Scan(&ID); err != nil {
+		t.Fatal(err)
+	}
+	return strconv.FormatInt(ID, 10)
+}
+
 func TestRegisterInvited(t *testing.T) {
 	//barry and better barry
 	barry := `{"username":"barry","password":"test","email":"barry@mail.com"}`
-	barryV2 := `{"ID":5,"name":"barry","email":"barry@mail.com"}`
 	//barry's client
 	a, err := getCSRF(r)
 	if err != nil {
@@ -274,6 +459,7 @@ func TestRegisterInvited(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	barryV2 := `{"ID":` + userID(t, "barry") + `,"name":"barry","email":"barry@mail.com"}`
 	if w.Body.String() != barryV2 {
 		t.Errorf("wanted %v got %v", barryV2, w.Body.String())
 	}
@@ -307,7 +493,7 @@ func TestInviteRevokeUnregistered(t *testing.T) {
 	if w.Code != http.StatusOK {
 		t.Errorf("want %v got %v", http.StatusOK, w.Code)
 	}
-	want = `{"invites":[{"ID":5,"name":"barry","email":"barry@mail.com"},{"ID":0,"name":"dairy@mail.com","email":"dairy@mail.com"}],"league":{"ID":1,"Name":"All Arry League","Commissioner":{"ID":1,"name":"larry","email":"larry@mail.com"},"State":"INIT","MaxOwner":4,"Kind":"TRAD"},"teams":[{"ID":1,"Name":"Lawrence of Arry-bia","Manager":{"ID":1,"name":"larry","email":"larry@mail.com"},"Slot":0}]}`
+	want = `{"invites":[{"ID":` + userID(t, "barry") + `,"name":"barry","email":"barry@mail.com"},{"ID":0,"name":"dairy@mail.com","email":"dairy@mail.com"}],"league":{"ID":1,"Name":"All Arry League","Commissioner":{"ID":1,"name":"larry","email":"larry@mail.com"},"State":"INIT","MaxOwner":4,"Kind":"TRAD"},"teams":[{"ID":1,"Name":"Lawrence of Arry-bia","Manager":{"ID":1,"name":"larry","email":"larry@mail.com"},"Slot":0}]}`
 	if want != w.Body.String() {
 		t.Errorf("want %v", want)
 		t.Errorf("got %v", w.Body.String())
@@ -384,7 +570,6 @@ func TestJoinLeague(t *testing.T) {
 func TestInviteRegistered(t *testing.T) {
 	//marry and better marry
 	marry := `{"username":"marry","password":"test","email":"marry@mail.com"}`
-	marryV2 := `{"ID":6,"name":"marry","email":"marry@mail.com"}`
 	//marry's client
 	a, err := getCSRF(r)
 	if err != nil {
@@ -395,6 +580,7 @@ func TestInviteRegistered(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	marryV2 := `{"ID":` + userID(t, "marry") + `,"name":"marry","email":"marry@mail.com"}`
 	if w.Body.String() != marryV2 {
 		t.Errorf("wanted %v got %v", marryV2, w.Body.String())
 	}
@@ -407,7 +593,7 @@ func TestInviteRegistered(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	want := `{"ID":6,"name":"marry","email":"marry@mail.com"}`
+	want := marryV2
 	if w.Body.String() != want {
 		t.Errorf("want %v got %v", want, w.Body.String())
 	}
@@ -437,7 +623,7 @@ func TestLeagueHome(t *testing.T) {
 	//Beyond how unwieldy this gets, we really want to track changes to this page as we have users join.
 	//There's probably a better way that's eluding me at the moment, but for some larger tests (like a max size league)
 	//We're going to want to compare views in a programmatic way.
-	want := `{"invites":null,"league":{"ID":1,"Name":"All Arry League","Commissioner":{"ID":1,"name":"larry","email":"larry@mail.com"},"State":"INIT","MaxOwner":4,"Kind":"TRAD"},"teams":[{"ID":1,"Name":"Lawrence of Arry-bia","Manager":{"ID":1,"name":"larry","email":"larry@mail.com"},"Slot":0},{"ID":2,"Name":"Barry good, Barry barry barry good","Manager":{"ID":5,"name":"barry","email":"barry@mail.com"},"Slot":0},{"ID":3,"Name":"Marry Christmas","Manager":{"ID":6,"name":"marry","email":"marry@mail.com"},"Slot":0}]}`
+	want := `{"invites":null,"league":{"ID":1,"Name":"All Arry League","Commissioner":{"ID":1,"name":"larry","email":"larry@mail.com"},"State":"INIT","MaxOwner":4,"Kind":"TRAD"},"teams":[{"ID":1,"Name":"Lawrence of Arry-bia","Manager":{"ID":1,"name":"larry","email":"larry@mail.com"},"Slot":0},{"ID":2,"Name":"Barry good, Barry barry barry good","Manager":{"ID":` + userID(t, "barry") + `,"name":"barry","email":"barry@mail.com"},"Slot":0},{"ID":3,"Name":"Marry Christmas","Manager":{"ID":` + userID(t, "marry") + `,"name":"marry","email":"marry@mail.com"},"Slot":0}]}`
 	if want != w.Body.String() {
 		t.Errorf("want %v", want)
 		t.Errorf("got %v", w.Body.String())
@@ -472,7 +658,7 @@ func TestEditTeamInfo(t *testing.T) {
 	//Beyond how unwieldy this gets, we really want to track changes to this page as we have users join.
 	//There's probably a better way that's eluding me at the moment, but for some larger tests (like a max size league)
 	//We're going to want to compare views in a programmatic way.
-	want = `{"invites":null,"league":{"ID":1,"Name":"All Arry League","Commissioner":{"ID":1,"name":"larry","email":"larry@mail.com"},"State":"INIT","MaxOwner":4,"Kind":"TRAD"},"teams":[{"ID":1,"Name":"Lawrence of Arry-bia","Manager":{"ID":1,"name":"larry","email":"larry@mail.com"},"Slot":0},{"ID":2,"Name":"Barry Good","Manager":{"ID":5,"name":"barry","email":"barry@mail.com"},"Slot":0},{"ID":3,"Name":"Marry Christmas","Manager":{"ID":6,"name":"marry","email":"marry@mail.com"},"Slot":0}]}`
+	want = `{"invites":null,"league":{"ID":1,"Name":"All Arry League","Commissioner":{"ID":1,"name":"larry","email":"larry@mail.com"},"State":"INIT","MaxOwner":4,"Kind":"TRAD"},"teams":[{"ID":1,"Name":"Lawrence of Arry-bia","Manager":{"ID":1,"name":"larry","email":"larry@mail.com"},"Slot":0},{"ID":2,"Name":"Barry Good","Manager":{"ID":` + userID(t, "barry") + `,"name":"barry","email":"barry@mail.com"},"Slot":0},{"ID":3,"Name":"Marry Christmas","Manager":{"ID":` + userID(t, "marry") + `,"name":"marry","email":"marry@mail.com"},"Slot":0}]}`
 	if want != w.Body.String() {
 		t.Errorf("want %v", want)
 		t.Errorf("got %v", w.Body.String())
@@ -521,7 +707,7 @@ func TestGetDraftSettings(t *testing.T) {
 	if w.Code != http.StatusOK {
 		t.Errorf("want %v got %v", http.StatusOK, w.Code)
 	}
-	want := `{"draft":{"ID":1,"Kind":"TRAD","DraftOrder":"SNAKE","Time":"0001-01-01T00:00:00Z","DraftClock":0,"Rounds":15},"positional":{"ID":1,"Kind":"TRAD","QB":1,"RB":2,"WR":2,"TE":1,"Flex":1,"Bench":6,"Superflex":0,"Def":1,"K":1},"scoring":{"offense":{"ID":1,"PassAttempt":0,"PassCompletion":0,"PassYard":0.04,"PassTouchdown":6,"PassInterception":-3,"PassSack":0,"RushAttempt":0,"RushYard":0.1,"RushTouchdown":6,"ReceivingTarget":0,"Reception":0,"ReceivingYard":0.1,"ReceivingTouchdown":6,"Fumble":-1,"FumbleLost":-2,"MiscTouchdown":6,"TwoPointConversion":2,"TwoPointPass":2},"defense":{"ID":1,"Touchdown":6,"Sack":1,"Interception":3,"Safety":2,"Shutout":10,"Points6":7,"Points13":4,"Points20":1,"Points27":0,"Points34":-1,"Points35":-4,"YardBonus":3,"Yards":-0.01},"special":{"ID":1,"Fg29":3,"Fg39":3,"Fg49":3,"Fg50":3,"ExtraPoint":1}}}`
+	want := `{"draft":{"ID":1,"Kind":"TRAD","DraftOrder":"SNAKE","Time":"0001-01-01T00:00:00Z","DraftClock":0,"Rounds":15,"Pace":"LIVE"},"positional":{"ID":1,"Kind":"TRAD","QB":1,"RB":2,"WR":2,"TE":1,"Flex":1,"Bench":6,"Superflex":0,"Def":1,"K":1},"scoring":{"offense":{"ID":1,"PassAttempt":0,"PassCompletion":0,"PassYard":0.04,"PassTouchdown":6,"PassInterception":-3,"PassSack":0,"RushAttempt":0,"RushYard":0.1,"RushTouchdown":6,"ReceivingTarget":0,"Reception":0,"ReceivingYard":0.1,"ReceivingTouchdown":6,"Fumble":-1,"FumbleLost":-2,"MiscTouchdown":6,"TwoPointConversion":2,"TwoPointPass":2},"defense":{"ID":1,"Touchdown":6,"Sack":1,"Interception":3,"Safety":2,"Shutout":10,"Points6":7,"Points13":4,"Points20":1,"Points27":0,"Points34":-1,"Points35":-4,"YardBonus":3,"Yards":-0.01},"special":{"ID":1,"Fg29":3,"Fg39":3,"Fg49":3,"Fg50":3,"ExtraPoint":1}}}`
 	if want != w.Body.String() {
 		t.Errorf("want %v", want)
 		t.Errorf("got %v", w.Body.String())
@@ -534,7 +720,7 @@ func TestSetDraftSettings(t *testing.T) {
 
 	w, err := postJSON(a,
 		"/league/settings/setdraft/1",
-		`{"draft":{"ID":1,"Kind":"TRAD","DraftOrder":"SNAKE","Time":"0001-01-01T00:00:00Z","DraftClock":1,"Rounds":15},"positional":{"ID":1,"Kind":"TRAD","QB":1,"RB":2,"WR":2,"TE":1,"Flex":1,"Bench":6,"Superflex":1,"Def":1,"K":1},"scoring":{"offense":{"ID":1,"PassAttempt":0,"PassCompletion":0,"PassYard":0.04,"PassTouchdown":8,"PassInterception":-3,"PassSack":0,"RushAttempt":0,"RushYard":0.1,"RushTouchdown":6,"ReceivingTarget":0,"Reception":0,"ReceivingYard":0.1,"ReceivingTouchdown":6,"Fumble":-1,"FumbleLost":-2,"MiscTouchdown":6,"TwoPointConversion":2,"TwoPointPass":2},"defense":{"ID":1,"Touchdown":6,"Sack":1,"Interception":3,"Safety":2,"Shutout":10,"Points6":7,"Points13":4,"Points20":1,"Points27":0,"Points34":-1,"Points35":-4,"YardBonus":5,"Yards":-0.01},"special":{"ID":1,"Fg29":3,"Fg39":3,"Fg49":3,"Fg50":3,"ExtraPoint":2}}}`,
+		`{"draft":{"ID":1,"Kind":"TRAD","DraftOrder":"SNAKE","Time":"0001-01-01T00:00:00Z","DraftClock":1,"Rounds":15,"Pace":"LIVE"},"positional":{"ID":1,"Kind":"TRAD","QB":1,"RB":2,"WR":2,"TE":1,"Flex":1,"Bench":6,"Superflex":1,"Def":1,"K":1},"scoring":{"offense":{"ID":1,"PassAttempt":0,"PassCompletion":0,"PassYard":0.04,"PassTouchdown":8,"PassInterception":-3,"PassSack":0,"RushAttempt":0,"RushYard":0.1,"RushTouchdown":6,"ReceivingTarget":0,"Reception":0,"ReceivingYard":0.1,"ReceivingTouchdown":6,"Fumble":-1,"FumbleLost":-2,"MiscTouchdown":6,"TwoPointConversion":2,"TwoPointPass":2},"defense":{"ID":1,"Touchdown":6,"Sack":1,"Interception":3,"Safety":2,"Shutout":10,"Points6":7,"Points13":4,"Points20":1,"Points27":0,"Points34":-1,"Points35":-4,"YardBonus":5,"Yards":-0.01},"special":{"ID":1,"Fg29":3,"Fg39":3,"Fg49":3,"Fg50":3,"ExtraPoint":2}}}`,
 		http.StatusOK)
 	if err != nil {
 		t.Errorf("bad request: %v", err)
@@ -553,7 +739,7 @@ func TestSetDraftSettings(t *testing.T) {
 	if w.Code != http.StatusOK {
 		t.Errorf("want %v got %v", http.StatusOK, w.Code)
 	}
-	want = `{"draft":{"ID":1,"Kind":"TRAD","DraftOrder":"SNAKE","Time":"0001-01-01T00:00:00Z","DraftClock":1,"Rounds":15},"positional":{"ID":1,"Kind":"TRAD","QB":1,"RB":2,"WR":2,"TE":1,"Flex":1,"Bench":6,"Superflex":1,"Def":1,"K":1},"scoring":{"offense":{"ID":1,"PassAttempt":0,"PassCompletion":0,"PassYard":0.04,"PassTouchdown":8,"PassInterception":-3,"PassSack":0,"RushAttempt":0,"RushYard":0.1,"RushTouchdown":6,"ReceivingTarget":0,"Reception":0,"ReceivingYard":0.1,"ReceivingTouchdown":6,"Fumble":-1,"FumbleLost":-2,"MiscTouchdown":6,"TwoPointConversion":2,"TwoPointPass":2},"defense":{"ID":1,"Touchdown":6,"Sack":1,"Interception":3,"Safety":2,"Shutout":10,"Points6":7,"Points13":4,"Points20":1,"Points27":0,"Points34":-1,"Points35":-4,"YardBonus":5,"Yards":-0.01},"special":{"ID":1,"Fg29":3,"Fg39":3,"Fg49":3,"Fg50":3,"ExtraPoint":2}}}`
+	want = `{"draft":{"ID":1,"Kind":"TRAD","DraftOrder":"SNAKE","Time":"0001-01-01T00:00:00Z","DraftClock":1,"Rounds":15,"Pace":"LIVE"},"positional":{"ID":1,"Kind":"TRAD","QB":1,"RB":2,"WR":2,"TE":1,"Flex":1,"Bench":6,"Superflex":1,"Def":1,"K":1},"scoring":{"offense":{"ID":1,"PassAttempt":0,"PassCompletion":0,"PassYard":0.04,"PassTouchdown":8,"PassInterception":-3,"PassSack":0,"RushAttempt":0,"RushYard":0.1,"RushTouchdown":6,"ReceivingTarget":0,"Reception":0,"ReceivingYard":0.1,"ReceivingTouchdown":6,"Fumble":-1,"FumbleLost":-2,"MiscTouchdown":6,"TwoPointConversion":2,"TwoPointPass":2},"defense":{"ID":1,"Touchdown":6,"Sack":1,"Interception":3,"Safety":2,"Shutout":10,"Points6":7,"Points13":4,"Points20":1,"Points27":0,"Points34":-1,"Points35":-4,"YardBonus":5,"Yards":-0.01},"special":{"ID":1,"Fg29":3,"Fg39":3,"Fg49":3,"Fg50":3,"ExtraPoint":2}}}`
 	if want != w.Body.String() {
 		t.Errorf("want %v", want)
 		t.Errorf("got %v", w.Body.String())
@@ -593,6 +779,366 @@ func TestGetEmptyHistory(t *testing.T) {
 	}
 }
 
+func TestDraftQueue(t *testing.T) {
+	a := larryClient
+	w, err := postJSON(a, "/league/queue/1", `{"players":[3,1,2]}`, http.StatusOK)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"players":[3,1,2],"team":1}`
+	if w.Body.String() != want {
+		t.Errorf("want %v got %v", want, w.Body.String())
+	}
+
+	//Queue should persist for the next visit
+	w = httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/league/queue/1", nil)
+	if err != nil {
+		t.Fatalf("Bad Request: %v", err)
+	}
+	req.Header.Add("Cookie", a.cookie)
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("want %v got %v", http.StatusOK, w.Code)
+	}
+	if w.Body.String() != want {
+		t.Errorf("want %v got %v", want, w.Body.String())
+	}
+
+	_, err = postJSON(a, "/league/queue/1", `{"players":[3,3]}`, http.StatusBadRequest)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestChatLog(t *testing.T) {
+	a := larryClient
+	w := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/league/chat/1", nil)
+	if err != nil {
+		t.Fatalf("Bad Request: %v", err)
+	}
+	req.Header.Add("Cookie", a.cookie)
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("want %v got %v", http.StatusOK, w.Code)
+	}
+	if w.Body.String() != "[]" {
+		t.Errorf("want empty log got %v", w.Body.String())
+	}
+}
+
+func TestDraftClockStatus(t *testing.T) {
+	a := larryClient
+	w := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/league/draft/clock/1", nil)
+	if err != nil {
+		t.Fatalf("Bad Request: %v", err)
+	}
+	req.Header.Add("Cookie", a.cookie)
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("want %v got %v", http.StatusOK, w.Code)
+	}
+	var clock struct {
+		Pick int64
+		Pace string
+	}
+	if err = json.Unmarshal(w.Body.Bytes(), &clock); err != nil {
+		t.Fatal(err)
+	}
+	if clock.Pace != "LIVE" {
+		t.Errorf("want LIVE got %v", clock.Pace)
+	}
+}
+
+//Larry hands out a link to league 1.  Barry's already in, a doctored link gets nowhere, Harry takes the one use it
+//has, and a revoked link stays dead.
+func TestInviteLinks(t *testing.T) {
+	a := larryClient
+	w, err := postJSON(a, "/league/invitelink/create", `{"league":1,"hours":24,"singleUse":true}`, http.StatusOK)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var link struct {
+		ID        int64
+		Link      string
+		SingleUse bool
+	}
+	if err = json.Unmarshal(w.Body.Bytes(), &link); err != nil {
+		t.Fatal(err)
+	}
+	if !link.SingleUse || !strings.HasPrefix(link.Link, "/invite/") {
+		t.Errorf("unexpected link %+v", link)
+	}
+
+	w = httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/league/invitelink/list/1", nil)
+	if err != nil {
+		t.Fatalf("Bad Request: %v", err)
+	}
+	req.Header.Add("Cookie", a.cookie)
+	r.ServeHTTP(w, req)
+	if !strings.Contains(w.Body.String(), link.Link) {
+		t.Errorf("link missing from list: %v", w.Body.String())
+	}
+
+	visit := func(c client, url string, status int) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			t.Fatalf("Bad Request: %v", err)
+		}
+		req.Header.Add("Cookie", c.cookie)
+		r.ServeHTTP(w, req)
+		if w.Code != status {
+			t.Errorf("%v: want %v got %v cause: %v", url, status, w.Code, w.Body.String())
+		}
+		return w
+	}
+	visit(barryClient, link.Link, http.StatusBadRequest)
+	visit(barryClient, strings.Replace(link.Link, "/invite/"+strconv.FormatInt(link.ID, 10)+".1.", "/invite/"+strconv.FormatInt(link.ID, 10)+".2.", 1), http.StatusBadRequest)
+
+	//Harry has to log in before he sees anything, and following the link only asks him.  Accepting takes the link's
+	//one use.
+	h, err := getCSRF(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w = visit(h, link.Link, http.StatusSeeOther)
+	if want := "/?next=" + url.QueryEscape(link.Link); w.Header().Get("Location") != want {
+		t.Errorf("want redirect to %v got %v", want, w.Header().Get("Location"))
+	}
+	w, err = postJSON(h, "/register", `{"username":"harry","password":"test","email":"harry@mail.com"}`, http.StatusOK)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.cookie = w.Header().Get("Set-Cookie")
+	var league string
+	store.GetDB().QueryRow("SELECT name FROM league WHERE ID=1").Scan(&league)
+	w = visit(h, link.Link, http.StatusOK)
+	if !strings.Contains(w.Body.String(), "Join "+league+"?") {
+		t.Errorf("want the league on the page got %v", w.Body.String())
+	}
+	var invited int
+	store.GetDB().QueryRow("SELECT COUNT(*) FROM invites WHERE league=1 AND user=?", userID(t, "harry")).Scan(&invited)
+	if invited != 0 {
+		t.Error("visiting the link invited harry")
+	}
+	if _, err = postJSON(h, link.Link, "", http.StatusSeeOther); err != nil {
+		t.Error(err)
+	}
+	store.GetDB().QueryRow("SELECT COUNT(*) FROM invites WHERE league=1 AND user=?", userID(t, "harry")).Scan(&invited)
+	if invited != 1 {
+		t.Error("accepting didn't invite harry")
+	}
+	visit(barryClient, link.Link, http.StatusGone)
+
+	if _, err = postJSON(barryClient, "/league/invitelink/revoke", `{"league":1,"link":`+strconv.FormatInt(link.ID, 10)+`}`, http.StatusForbidden); err != nil {
+		t.Error(err)
+	}
+	if _, err = postJSON(a, "/league/invitelink/revoke", `{"league":1,"link":`+strconv.FormatInt(link.ID, 10)+`}`, http.StatusOK); err != nil {
+		t.Error(err)
+	}
+	visit(barryClient, link.Link, http.StatusGone)
+}
+
+//Garry's a stranger to league 1 until Larry lets him watch.  As a co-commissioner he can only do what Larry lets him,
+//and never hand out roles.
+func TestLeagueRoles(t *testing.T) {
+	g, err := getCSRF(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := postJSON(g, "/register", `{"username":"garry","password":"test","email":"garry@mail.com"}`, http.StatusOK)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.cookie = w.Header().Get("Set-Cookie")
+	garryClient = g
+	garry := userID(t, "garry")
+
+	home := func(status int) {
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest("GET", "/league/home/1", nil)
+		if err != nil {
+			t.Fatalf("Bad Request: %v", err)
+		}
+		req.Header.Add("Cookie", g.cookie)
+		r.ServeHTTP(w, req)
+		if w.Code != status {
+			t.Errorf("want %v got %v cause: %v", status, w.Code, w.Body.String())
+		}
+	}
+	home(http.StatusForbidden)
+	if _, err = postJSON(g, "/league/join", `{"league":1}`, http.StatusForbidden); err != nil {
+		t.Error(err)
+	}
+	//League 1's scoring is for its members too, even on a player's page.
+	for cookie, status := range map[string]int{"": http.StatusUnauthorized, g.cookie: http.StatusForbidden, larryClient.cookie: http.StatusOK} {
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest("GET", "/player/1?league=1", nil)
+		if err != nil {
+			t.Fatalf("Bad Request: %v", err)
+		}
+		req.Header.Add("Cookie", cookie)
+		r.ServeHTTP(w, req)
+		if w.Code != status {
+			t.Errorf("player detail: want %v got %v cause: %v", status, w.Code, w.Body.String())
+		}
+	}
+
+	if _, err = postJSON(larryClient, "/league/roles", `{"league":1,"user":`+garry+`,"role":"SPECTATOR"}`, http.StatusOK); err != nil {
+		t.Fatal(err)
+	}
+	home(http.StatusOK)
+	if _, err = postJSON(g, "/league/lock", `{"league":1}`, http.StatusForbidden); err != nil {
+		t.Error(err)
+	}
+
+	if _, err = postJSON(larryClient, "/league/roles", `{"league":1,"user":`+garry+`,"role":"COCOMMISSIONER","permissions":["INVITES"]}`, http.StatusOK); err != nil {
+		t.Fatal(err)
+	}
+	w = httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/league/invitelink/list/1", nil)
+	if err != nil {
+		t.Fatalf("Bad Request: %v", err)
+	}
+	req.Header.Add("Cookie", g.cookie)
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("want %v got %v cause: %v", http.StatusOK, w.Code, w.Body.String())
+	}
+	if _, err = postJSON(g, "/league/lock", `{"league":1}`, http.StatusForbidden); err != nil {
+		t.Error(err)
+	}
+	if _, err = postJSON(g, "/league/roles", `{"league":1,"user":`+userID(t, "marry")+`,"role":"SPECTATOR"}`, http.StatusForbidden); err != nil {
+		t.Error(err)
+	}
+	if _, err = postJSON(marryClient, "/league/roles", `{"league":1,"user":`+garry+`,"role":""}`, http.StatusForbidden); err != nil {
+		t.Error(err)
+	}
+	if _, err = postJSON(larryClient, "/league/roles", `{"league":1,"user":`+garry+`,"role":""}`, http.StatusOK); err != nil {
+		t.Error(err)
+	}
+	home(http.StatusForbidden)
+}
+
+//Larry offers league 1 to Marry, who takes it, then gives it straight back.  Barry can't take an offer that wasn't
+//his, and all of it ends up in the league's history.
+func TestCommissionerTransfer(t *testing.T) {
+	marry, larry := userID(t, "marry"), userID(t, "larry")
+	if _, err := postJSON(marryClient, "/league/transfer", `{"league":1,"user":`+larry+`}`, http.StatusForbidden); err != nil {
+		t.Error(err)
+	}
+	if _, err := postJSON(larryClient, "/league/transfer", `{"league":1,"user":`+marry+`}`, http.StatusOK); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := postJSON(barryClient, "/league/transfer/accept", `{"league":1}`, http.StatusNotFound); err != nil {
+		t.Error(err)
+	}
+	if _, err := postJSON(marryClient, "/league/transfer/accept", `{"league":1}`, http.StatusOK); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := postJSON(larryClient, "/league/lock", `{"league":1}`, http.StatusForbidden); err != nil {
+		t.Error(err)
+	}
+	if _, err := postJSON(marryClient, "/league/transfer", `{"league":1,"user":`+larry+`}`, http.StatusOK); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := postJSON(larryClient, "/league/transfer/accept", `{"league":1}`, http.StatusOK); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/league/roles/1/history", nil)
+	if err != nil {
+		t.Fatalf("Bad Request: %v", err)
+	}
+	req.Header.Add("Cookie", larryClient.cookie)
+	r.ServeHTTP(w, req)
+	var history []struct {
+		Action string
+		Actor  struct{ Name string }
+		User   struct{ Name string }
+	}
+	if err = json.Unmarshal(w.Body.Bytes(), &history); err != nil {
+		t.Fatal(err)
+	}
+	if len(history) < 4 || history[0].Action != "ACCEPT" || history[0].Actor.Name != "larry" || history[0].User.Name != "marry" ||
+		history[1].Action != "OFFER" || history[2].Action != "ACCEPT" || history[3].Action != "OFFER" {
+		t.Errorf("unexpected history %+v", history)
+	}
+}
+
+//The schema the JS client builds against should match what the server speaks, and clients asking for a version we
+//don't have get turned away.
+func TestProtocol(t *testing.T) {
+	a := larryClient
+	w := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/ws/protocol", nil)
+	if err != nil {
+		t.Fatalf("Bad Request: %v", err)
+	}
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("want %v got %v", http.StatusOK, w.Code)
+	}
+	committed, err := os.ReadFile("../src/scripts/protocol.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(string(committed)) != strings.TrimSpace(w.Body.String()) {
+		t.Error("src/scripts/protocol.json is out of date, run go generate in server")
+	}
+
+	w = httptest.NewRecorder()
+	req, err = http.NewRequest("GET", "/ws/draft/1?v=99", nil)
+	if err != nil {
+		t.Fatalf("Bad Request: %v", err)
+	}
+	req.Header.Add("Cookie", a.cookie)
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("want %v got %v", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestCreateMock(t *testing.T) {
+	a := larryClient
+	w, err := postJSON(a, "/mock/create", `{"league":1,"slot":2,"teams":4,"rounds":2,"strategies":["need","random"]}`, http.StatusOK)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m struct {
+		Mock  string
+		Team  int64
+		Teams []struct {
+			ID       int64
+			Bot      bool
+			Strategy string
+		}
+	}
+	if err = json.Unmarshal(w.Body.Bytes(), &m); err != nil {
+		t.Fatal(err)
+	}
+	if m.Mock == "" || m.Team != 2 || len(m.Teams) != 4 {
+		t.Errorf("bad mock %v", w.Body.String())
+	}
+	if m.Teams[1].Bot || m.Teams[0].Strategy != "need" || m.Teams[2].Strategy != "random" {
+		t.Errorf("bad teams %v", m.Teams)
+	}
+
+	_, err = postJSON(a, "/mock/create", `{"strategies":["cheat"]}`, http.StatusBadRequest)
+	if err != nil {
+		t.Error(err)
+	}
+	_, err = postJSON(a, "/mock/create", `{"teams":2,"slot":3}`, http.StatusBadRequest)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
 //Final test we're going to use to allow our automated testing to access all main league states.
 func TestFrontendSetup(t *testing.T) {
 	a := marryClient
@@ -650,6 +1196,182 @@ func TestFrontendSetup(t *testing.T) {
 	}
 }
 
+//Garry's one man league finishes its draft and comes back for another season.  He keeps the player he drafted
+//first, who can't cost less than the first round, one from the fifth pick, and two he picked up off waivers, who
+//both cost the undrafted round and so end up a round apart.  This one comes after TestFrontendSetup, which counts
+//on the leagues before it.
+func TestRenewKeepers(t *testing.T) {
+	g := garryClient
+	w, err := postJSON(g, "/league/create", `{"maxOwner":2,"league":"Garry's Keepers","team":"Garrison"}`, http.StatusOK)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var created struct {
+		LeagueID int64 `json:"leagueID"`
+	}
+	if err = json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	old := strconv.FormatInt(created.LeagueID, 10)
+	if _, err = postJSON(g, "/league/renew", `{"league":`+old+`}`, http.StatusBadRequest); err != nil {
+		t.Error(err)
+	}
+
+	db := store.GetDB()
+	var team int64
+	if err = db.QueryRow("SELECT ID FROM teams WHERE league=?", created.LeagueID).Scan(&team); err != nil {
+		t.Fatal(err)
+	}
+	for _, q := range []string{
+		"INSERT INTO draft_picks (league, ID, player, team) VALUES (?, 0, 1, ?)",
+		"INSERT INTO draft_picks (league, ID, player, team) VALUES (?, 4, 2, ?)",
+		"INSERT INTO rosters (league, player, active, team) VALUES (?, 3, 1, ?)",
+		"INSERT INTO rosters (league, player, active, team) VALUES (?, 4, 1, ?)",
+	} {
+		if _, err = db.Exec(q, created.LeagueID, team); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err = db.Exec("UPDATE league SET state='COMPLETE' WHERE ID=?", created.LeagueID); err != nil {
+		t.Fatal(err)
+	}
+	//Larry helps run the league, and keeps doing so next season.
+	larry := userID(t, "larry")
+	if _, err = postJSON(g, "/league/roles", `{"league":`+old+`,"user":`+larry+`,"role":"COCOMMISSIONER","permissions":["INVITES"]}`, http.StatusOK); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err = postJSON(g, "/league/renew", `{"league":`+old+`}`, http.StatusOK)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var renewed struct {
+		LeagueID int64 `json:"leagueID"`
+	}
+	if err = json.Unmarshal(w.Body.Bytes(), &renewed); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = postJSON(g, "/league/renew", `{"league":`+old+`}`, http.StatusBadRequest); err != nil {
+		t.Error(err)
+	}
+
+	var role, permissions string
+	row := db.QueryRow("SELECT role, permissions FROM league_roles WHERE league=? AND user=?", renewed.LeagueID, larry)
+	if err = row.Scan(&role, &permissions); err != nil || role != "COCOMMISSIONER" || permissions != "INVITES" {
+		t.Errorf("renewed league has larry as %v %v (%v)", role, permissions, err)
+	}
+
+	next := strconv.FormatInt(renewed.LeagueID, 10)
+	if _, err = postJSON(g, "/league/settings/keepers/"+next, `{"keepers":4,"roundCost":1,"undraftedRound":10}`, http.StatusOK); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = postJSON(g, "/league/keepers/"+next, `{"players":[1,1]}`, http.StatusBadRequest); err != nil {
+		t.Error(err)
+	}
+	if _, err = postJSON(g, "/league/keepers/"+next, `{"players":[1,2,3,4,5]}`, http.StatusBadRequest); err != nil {
+		t.Error(err)
+	}
+	if _, err = postJSON(g, "/league/keepers/"+next, `{"players":[5]}`, http.StatusBadRequest); err != nil {
+		t.Error(err)
+	}
+	w, err = postJSON(g, "/league/keepers/"+next, `{"players":[1,2,3,4]}`, http.StatusOK)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keepers []struct {
+		Player int64
+		Round  int
+	}
+	if err = json.Unmarshal(w.Body.Bytes(), &keepers); err != nil {
+		t.Fatal(err)
+	}
+	want := map[int64]int{1: 1, 2: 4, 3: 10, 4: 9}
+	if len(keepers) != len(want) {
+		t.Fatalf("got %+v want rounds %v", keepers, want)
+	}
+	for _, k := range keepers {
+		if want[k.Player] != k.Round {
+			t.Errorf("got %+v want rounds %v", keepers, want)
+			break
+		}
+	}
+}
+
+//Supplemental drafts only take rookies, and until import-stats has run every player should count as one.
+func TestSupplementalDraft(t *testing.T) {
+	g := garryClient
+	w, err := postJSON(g, "/league/create", `{"maxOwner":2,"league":"Garry's Rookies","team":"Garrison"}`, http.StatusOK)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var created struct {
+		LeagueID int64 `json:"leagueID"`
+	}
+	if err = json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	league := strconv.FormatInt(created.LeagueID, 10)
+
+	db := store.GetDB()
+	var team int64
+	if err = db.QueryRow("SELECT ID FROM teams WHERE league=?", created.LeagueID).Scan(&team); err != nil {
+		t.Fatal(err)
+	}
+	standings := `{"league":` + league + `,"rounds":1,"standings":[` + strconv.FormatInt(team, 10) + `]}`
+	if _, err = postJSON(g, "/league/supplemental/create", standings, http.StatusBadRequest); err != nil {
+		t.Error(err)
+	}
+	if _, err = db.Exec("UPDATE league SET state='COMPLETE', season=2021 WHERE ID=?", created.LeagueID); err != nil {
+		t.Fatal(err)
+	}
+	//Player 1 played in 2020, so he's a veteran by 2021.  Player 2's stats start in 2021, which still makes him a rookie.
+	if _, err = db.Exec("INSERT INTO player_stats (player, season, week) VALUES (1, 2020, 0), (2, 2021, 0)"); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Exec("DELETE FROM player_stats WHERE player IN (1, 2)")
+	w, err = postJSON(g, "/league/supplemental/create", standings, http.StatusOK)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var supplemental struct {
+		Draft int64 `json:"draft"`
+	}
+	if err = json.Unmarshal(w.Body.Bytes(), &supplemental); err != nil {
+		t.Fatal(err)
+	}
+	start := `{"league":` + league + `,"draft":` + strconv.FormatInt(supplemental.Draft, 10) + `}`
+	if _, err = postJSON(g, "/league/supplemental/start", start, http.StatusOK); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = postJSON(g, "/league/supplemental/start", start, http.StatusBadRequest); err != nil {
+		t.Error(err)
+	}
+
+	w = httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/draftpool?league="+league+"&rookies=1&limit=5", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.ServeHTTP(w, req)
+	var page struct {
+		Players []scanners.Player
+	}
+	if err = json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusOK || len(page.Players) != 5 || page.Players[0].ID != 2 {
+		t.Errorf("got %v with %v rookies, want 5 starting from player 2", w.Code, page.Players)
+	}
+
+	//The pick path checks eligibility the same way.
+	for player, want := range map[int]int{1: 0, 2: 1} {
+		var eligible int
+		if err = db.QueryRow("SELECT COUNT(*) FROM player WHERE ID=? AND "+store.RookieClause, player, created.LeagueID).Scan(&eligible); err != nil || eligible != want {
+			t.Errorf("got %v, %v want %v for player %v", eligible, err, want, player)
+		}
+	}
+}
+
 /*
 	HELPERS
 */
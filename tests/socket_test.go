@@ -0,0 +1,469 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PhiloTFarnsworth/FantasySportsAF/store"
+	"github.com/gorilla/websocket"
+)
+
+//The draft room only happens over a websocket, so these tests serve the router for real and talk to it the way the
+//front end does.  They run after server_test.go, once Larry and Garry have accounts.
+
+var roomServer *httptest.Server
+
+//dialRoom connects a client to a draft room for the rest of the test.
+func dialRoom(t *testing.T, c client, path string) *websocket.Conn {
+	t.Helper()
+	if roomServer == nil {
+		roomServer = httptest.NewServer(r)
+	}
+	url := "ws" + strings.TrimPrefix(roomServer.URL, "http") + path
+	ws, _, err := websocket.DefaultDialer.Dial(url, http.Header{"Cookie": {c.cookie}})
+	if err != nil {
+		t.Fatalf("dial %v: %v", path, err)
+	}
+	t.Cleanup(func() { ws.Close() })
+	return ws
+}
+
+//send writes a frame to the room.
+func send(t *testing.T, ws *websocket.Conn, kind string, payload string) {
+	t.Helper()
+	frame := `{"Kind":"` + kind + `"}`
+	if payload != "" {
+		frame = `{"Kind":"` + kind + `","Payload":` + payload + `}`
+	}
+	if err := ws.WriteMessage(websocket.TextMessage, []byte(frame)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+//readFrame skips frames until one of kind turns up, and decodes it into v.
+func readFrame(t *testing.T, ws *websocket.Conn, kind string, v interface{}) {
+	t.Helper()
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		_, msg, err := ws.ReadMessage()
+		if err != nil {
+			t.Fatalf("waiting on %v: %v", kind, err)
+		}
+		var f struct{ Kind string }
+		if err = json.Unmarshal(msg, &f); err != nil {
+			t.Fatal(err)
+		}
+		if f.Kind == kind {
+			if err = json.Unmarshal(msg, v); err != nil {
+				t.Fatal(err)
+			}
+			return
+		}
+	}
+}
+
+//draftLeague has Garry start a two team draft with Larry, at a pace with clock seconds, or hours, on each pick.
+func draftLeague(t *testing.T, name string, pace string, clock int) int64 {
+	t.Helper()
+	g := garryClient
+	w, err := postJSON(g, "/league/create", `{"maxOwner":2,"league":"`+name+`","team":"Garrison"}`, http.StatusOK)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var created struct {
+		LeagueID int64 `json:"leagueID"`
+	}
+	if err = json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	league := strconv.FormatInt(created.LeagueID, 10)
+	if _, err = postJSON(g, "/league/invite", `{"invitee":"larry@mail.com","league":`+league+`}`, http.StatusOK); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = postJSON(larryClient, "/league/join", `{"league":`+league+`,"team":"Larry's Larks"}`, http.StatusOK); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = postJSON(g, "/league/lock", `{"league":`+league+`}`, http.StatusOK); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.GetDB().Exec("UPDATE draft_settings SET pace=?, draftClock=? WHERE ID=?", pace, clock, created.LeagueID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = postJSON(g, "/league/startdraft", `{"league":`+league+`}`, http.StatusOK); err != nil {
+		t.Fatal(err)
+	}
+	return created.LeagueID
+}
+
+type errorReply struct {
+	Code string
+}
+
+type draftReply struct {
+	Player int64
+	Team   int64
+	Pick   int64
+}
+
+type clockReply struct {
+	Pick     int64
+	Team     int64
+	Deadline time.Time
+}
+
+type pauseReply struct {
+	Paused bool
+}
+
+//Garry runs his draft from the room: only he can pause it, pausing keeps what's left on the clock, he can pick for
+//either team whoever is on the clock, and undoing takes back the latest pick on the board.
+func TestCommissionerControls(t *testing.T) {
+	league := draftLeague(t, "Garry's Controls", "LIVE", 600)
+	room := "/ws/draft/" + strconv.FormatInt(league, 10)
+	g := dialRoom(t, garryClient, room)
+	l := dialRoom(t, larryClient, room)
+
+	var clock clockReply
+	readFrame(t, g, "clock", &clock)
+	first := clock.Team
+	var other int64
+	if err := store.GetDB().QueryRow("SELECT ID FROM teams WHERE league=? AND ID<>?", league, clock.Team).Scan(&other); err != nil {
+		t.Fatal(err)
+	}
+
+	var e errorReply
+	send(t, l, "pause", "")
+	readFrame(t, l, "error", &e)
+	if e.Code != "not_commissioner" {
+		t.Errorf("larry paused: got %v", e.Code)
+	}
+
+	//Let some time come off the clock before pausing.
+	time.Sleep(1100 * time.Millisecond)
+	var pause pauseReply
+	send(t, g, "pause", "")
+	readFrame(t, g, "pause", &pause)
+	if !pause.Paused {
+		t.Error("draft didn't pause")
+	}
+	send(t, l, "pick", `{"Player":1,"Pick":0}`)
+	readFrame(t, l, "error", &e)
+	if e.Code != "draft_paused" {
+		t.Errorf("picked while paused: got %v", e.Code)
+	}
+	send(t, g, "resume", "")
+	readFrame(t, g, "pause", &pause)
+	if pause.Paused {
+		t.Error("draft didn't resume")
+	}
+	var resumed clockReply
+	readFrame(t, g, "clock", &resumed)
+	if resumed.Pick != clock.Pick || resumed.Deadline.Before(clock.Deadline) || time.Until(resumed.Deadline) > 599*time.Second {
+		t.Errorf("clock didn't keep its time: was %+v now %+v", clock, resumed)
+	}
+
+	var pick draftReply
+	send(t, g, "commishPick", `{"Player":10,"Team":`+strconv.FormatInt(other, 10)+`}`)
+	readFrame(t, g, "draft", &pick)
+	if pick != (draftReply{10, other, 1}) {
+		t.Errorf("picking ahead: got %+v", pick)
+	}
+	send(t, g, "commishPick", `{"Player":10,"Team":`+strconv.FormatInt(clock.Team, 10)+`}`)
+	readFrame(t, g, "error", &e)
+	if e.Code != "invalid_pick" {
+		t.Errorf("picked a taken player: got %v", e.Code)
+	}
+	send(t, g, "commishPick", `{"Player":11,"Team":`+strconv.FormatInt(clock.Team, 10)+`}`)
+	readFrame(t, g, "draft", &pick)
+	if pick != (draftReply{11, clock.Team, 0}) {
+		t.Errorf("picking on the clock: got %+v", pick)
+	}
+	readFrame(t, g, "clock", &clock)
+	if clock.Pick != 2 {
+		t.Errorf("want pick 2 on the clock got %+v", clock)
+	}
+
+	var rollback struct {
+		Picks []struct {
+			Slot   int64
+			Player int64
+		}
+	}
+	send(t, g, "undo", `{"Count":1}`)
+	readFrame(t, g, "rollback", &rollback)
+	if len(rollback.Picks) != 1 || rollback.Picks[0].Slot != 1 || rollback.Picks[0].Player != 10 {
+		t.Errorf("undo: got %+v", rollback.Picks)
+	}
+
+	//A pick made ahead of the clock is the latest on the board, so it's the next one undone.
+	send(t, g, "commishPick", `{"Player":12,"Team":`+strconv.FormatInt(first, 10)+`}`)
+	readFrame(t, g, "draft", &pick)
+	if pick != (draftReply{12, first, 3}) {
+		t.Errorf("picking ahead: got %+v", pick)
+	}
+	send(t, g, "undo", `{"Count":1}`)
+	readFrame(t, g, "rollback", &rollback)
+	if len(rollback.Picks) != 1 || rollback.Picks[0].Slot != 3 || rollback.Picks[0].Player != 12 {
+		t.Errorf("undo ahead: got %+v", rollback.Picks)
+	}
+	var picks int
+	store.GetDB().QueryRow("SELECT COUNT(*) FROM draft_picks WHERE league=?", league).Scan(&picks)
+	if picks != 1 {
+		t.Errorf("want 1 pick left got %v", picks)
+	}
+}
+
+type chatReply struct {
+	ID      int64
+	Payload string
+	Seq     int64
+}
+
+//Larry drops his connection while Garry chats, and syncs on a new one to catch up.  People coming and going aren't
+//worth replaying, so only the chat gets a Seq.
+func TestDraftReplay(t *testing.T) {
+	league := draftLeague(t, "Garry's Replay", "LIVE", 0)
+	room := "/ws/draft/" + strconv.FormatInt(league, 10)
+	g := dialRoom(t, garryClient, room)
+	dialRoom(t, larryClient, room)
+
+	var joined struct {
+		Kind string
+		Seq  int64
+	}
+	readFrame(t, g, "status", &joined)
+	if joined.Seq != 0 {
+		t.Errorf("status got Seq %v", joined.Seq)
+	}
+
+	var first, second chatReply
+	send(t, g, "message", `"first"`)
+	readFrame(t, g, "chat", &first)
+	send(t, g, "message", `"second"`)
+	readFrame(t, g, "chat", &second)
+	if second.Seq != first.Seq+1 {
+		t.Errorf("want Seq %v got %v", first.Seq+1, second.Seq)
+	}
+
+	l := dialRoom(t, larryClient, room)
+	send(t, l, "sync", `{"Seq":`+strconv.FormatInt(first.Seq-1, 10)+`}`)
+	for _, want := range []chatReply{first, second} {
+		var got chatReply
+		readFrame(t, l, "chat", &got)
+		if got != want {
+			t.Errorf("replayed %+v want %+v", got, want)
+		}
+	}
+	var synced struct {
+		Seq  int64
+		More bool
+	}
+	readFrame(t, l, "synced", &synced)
+	if synced.Seq != second.Seq || synced.More {
+		t.Errorf("got %+v want synced to %v", synced, second.Seq)
+	}
+
+	var statuses int
+	store.GetDB().QueryRow("SELECT COUNT(*) FROM draft_events WHERE league=? AND data LIKE '%status%'", league).Scan(&statuses)
+	if statuses != 0 {
+		t.Errorf("kept %v status events", statuses)
+	}
+}
+
+//Larry gets carried away in Garry's draft room.  Garry deletes a message and mutes him, which only lasts for the
+//main draft: Larry can still talk in the supplemental draft.  Late arrivals and the export only see what's left.
+func TestDraftChat(t *testing.T) {
+	league := draftLeague(t, "Garry's Chat", "LIVE", 0)
+	ID := strconv.FormatInt(league, 10)
+	larry := userID(t, "larry")
+	w, err := postJSON(garryClient, "/league/supplemental/create", `{"league":`+ID+`,"rounds":1,"standings":[`+
+		teamOf(t, league, "garry")+`,`+teamOf(t, league, "larry")+`]}`, http.StatusOK)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var supplemental struct {
+		Draft int64 `json:"draft"`
+	}
+	if err = json.Unmarshal(w.Body.Bytes(), &supplemental); err != nil {
+		t.Fatal(err)
+	}
+
+	g := dialRoom(t, garryClient, "/ws/draft/"+ID)
+	l := dialRoom(t, larryClient, "/ws/draft/"+ID)
+	var kept, deleted chatReply
+	send(t, l, "message", `"good luck"`)
+	readFrame(t, g, "chat", &kept)
+	send(t, l, "message", `"you'll need it"`)
+	readFrame(t, g, "chat", &deleted)
+
+	var gone struct{ ID int64 }
+	send(t, g, "deleteChat", `{"Message":`+strconv.FormatInt(deleted.ID, 10)+`}`)
+	readFrame(t, l, "chatDeleted", &gone)
+	if gone.ID != deleted.ID {
+		t.Errorf("want %v deleted got %v", deleted.ID, gone.ID)
+	}
+
+	var mute struct {
+		User  int64
+		Muted bool
+	}
+	send(t, g, "mute", `{"User":`+larry+`}`)
+	readFrame(t, l, "mute", &mute)
+	if !mute.Muted || strconv.FormatInt(mute.User, 10) != larry {
+		t.Errorf("got %+v", mute)
+	}
+	var e errorReply
+	send(t, l, "message", `"hey"`)
+	readFrame(t, l, "error", &e)
+	if e.Code != "muted" {
+		t.Errorf("muted larry chatted: got %v", e.Code)
+	}
+	s := dialRoom(t, larryClient, "/ws/draft/"+ID+"/supplemental/"+strconv.FormatInt(supplemental.Draft, 10))
+	var elsewhere chatReply
+	send(t, s, "message", `"rookies"`)
+	readFrame(t, s, "chat", &elsewhere)
+	if elsewhere.Payload != "rookies" {
+		t.Errorf("got %+v in the supplemental draft", elsewhere)
+	}
+
+	var history struct {
+		Messages []chatReply
+	}
+	readFrame(t, dialRoom(t, larryClient, "/ws/draft/"+ID), "history", &history)
+	if len(history.Messages) != 1 || history.Messages[0].Payload != "good luck" {
+		t.Errorf("got history %+v", history.Messages)
+	}
+
+	w = httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/league/chat/"+ID+"?format=text", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Add("Cookie", larryClient.cookie)
+	r.ServeHTTP(w, req)
+	log := w.Body.String()
+	if w.Code != http.StatusOK || !strings.Contains(log, "larry: good luck") || !strings.Contains(log, "larry: rookies") ||
+		strings.Contains(log, "need it") {
+		t.Errorf("got %v %v", w.Code, log)
+	}
+
+	send(t, g, "unmute", `{"User":`+larry+`}`)
+	readFrame(t, l, "mute", &mute)
+	send(t, l, "message", `"sorry"`)
+	readFrame(t, g, "chat", &kept)
+	if kept.Payload != "sorry" {
+		t.Errorf("unmuted larry got %+v", kept)
+	}
+
+	//Running the draft doesn't make a co-commissioner a chat moderator, that takes CHAT.
+	garry := userID(t, "garry")
+	role := func(permissions string) {
+		t.Helper()
+		_, err := postJSON(garryClient, "/league/roles", `{"league":`+ID+`,"user":`+larry+`,"role":"COCOMMISSIONER","permissions":`+permissions+`}`, http.StatusOK)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	role(`["DRAFT"]`)
+	defer postJSON(garryClient, "/league/roles", `{"league":`+ID+`,"user":`+larry+`,"role":""}`, http.StatusOK)
+	send(t, l, "mute", `{"User":`+garry+`}`)
+	readFrame(t, l, "error", &e)
+	if e.Code != "not_commissioner" {
+		t.Errorf("larry muted with DRAFT: got %v", e.Code)
+	}
+	role(`["CHAT"]`)
+	send(t, l, "deleteChat", `{"Message":`+strconv.FormatInt(kept.ID, 10)+`}`)
+	readFrame(t, g, "chatDeleted", &gone)
+	if gone.ID != kept.ID {
+		t.Errorf("want %v deleted got %v", kept.ID, gone.ID)
+	}
+}
+
+//teamOf finds a user's team in a league.
+func teamOf(t *testing.T, league int64, name string) string {
+	t.Helper()
+	var ID int64
+	row := store.GetDB().QueryRow("SELECT t.ID FROM teams AS t JOIN user AS u ON t.manager=u.ID WHERE t.league=? AND u.name=?", league, name)
+	if err := row.Scan(&ID); err != nil {
+		t.Fatal(err)
+	}
+	return strconv.FormatInt(ID, 10)
+}
+
+//Garry and Larry take a day over each pick.  Whoever comes on the clock hears about it by email, and picks go in
+//over REST as long as they're the team's to make and the draft isn't paused.
+func TestSlowDraft(t *testing.T) {
+	league := draftLeague(t, "Garry's Slow Draft", "SLOW", 24)
+	ID := strconv.FormatInt(league, 10)
+	subject := "You're on the clock in Garry's Slow Draft"
+
+	//The hub starts the clock once it hears the draft has started.
+	var clock struct {
+		Pick     int64
+		Team     int64
+		Deadline time.Time
+		Pace     string
+	}
+	for deadline := time.Now().Add(5 * time.Second); clock.Deadline.IsZero() && time.Now().Before(deadline); {
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest("GET", "/league/draft/clock/"+ID, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Add("Cookie", garryClient.cookie)
+		r.ServeHTTP(w, req)
+		if err = json.Unmarshal(w.Body.Bytes(), &clock); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if left := time.Until(clock.Deadline); clock.Pick != 0 || clock.Pace != "SLOW" || left < 23*time.Hour || left > 24*time.Hour {
+		t.Errorf("got clock %+v", clock)
+	}
+
+	//Sort out who's up first.
+	up, next := garryClient, larryClient
+	upMail, nextMail := "garry@mail.com", "larry@mail.com"
+	if teamOf(t, league, "garry") != strconv.FormatInt(clock.Team, 10) {
+		up, next = next, up
+		upMail, nextMail = nextMail, upMail
+	}
+	waitForMail(t, upMail, subject)
+
+	pick := "/league/draft/pick/" + ID
+	if _, err := postJSON(next, pick, `{"player":20,"pick":0}`, http.StatusBadRequest); err != nil {
+		t.Error(err)
+	}
+	if _, err := postJSON(up, pick, `{"player":20,"pick":1}`, http.StatusBadRequest); err != nil {
+		t.Error(err)
+	}
+	w, err := postJSON(up, pick, `{"player":20,"pick":0}`, http.StatusOK)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"pick":0,"player":20,"team":` + strconv.FormatInt(clock.Team, 10) + `}`; w.Body.String() != want {
+		t.Errorf("want %v got %v", want, w.Body.String())
+	}
+	waitForMail(t, nextMail, subject)
+
+	g := dialRoom(t, garryClient, "/ws/draft/"+ID)
+	var pause pauseReply
+	send(t, g, "pause", "")
+	readFrame(t, g, "pause", &pause)
+	w, err = postJSON(next, pick, `{"player":21,"pick":1}`, http.StatusBadRequest)
+	if err != nil {
+		t.Error(err)
+	} else if !strings.Contains(w.Body.String(), "paused") {
+		t.Errorf("got %v", w.Body.String())
+	}
+	send(t, g, "resume", "")
+	readFrame(t, g, "pause", &pause)
+	if _, err = postJSON(next, pick, `{"player":21,"pick":1}`, http.StatusOK); err != nil {
+		t.Error(err)
+	}
+}
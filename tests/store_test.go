@@ -1,16 +1,28 @@
 package tests
 
 import (
+	"os"
+	"path/filepath"
+	"strconv"
 	"testing"
 
 	"github.com/PhiloTFarnsworth/FantasySportsAF/store"
+	"github.com/PhiloTFarnsworth/FantasySportsAF/store/playerimport"
+	"github.com/PhiloTFarnsworth/FantasySportsAF/store/scanners"
 )
 
 //Let's start with an easy one.  We just want to be able to test that we can reach our test_simple
 //database, and that we can query it's contents.
 func TestExistence(t *testing.T) {
-	store.ConnectDB("test_simple")
-	db := store.GetDB()
+	if store.CurrentDialect() != store.MySQL {
+		t.Skip("test_simple is a MySQL database")
+	}
+	//A handle of its own, so the server tests keep theirs.
+	db, err := store.Open("test_simple")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
 	var got int64
 	row := db.QueryRow("SELECT * FROM existence")
 	if err := row.Scan(&got); err != nil {
@@ -20,3 +32,295 @@ func TestExistence(t *testing.T) {
 		t.Errorf("Got %v want 1", got)
 	}
 }
+
+//Older databases have a set of tables per league.  We build one the old way and check it folds into the shared
+//tables, then clean up so the frontend tests see the same leagues as before.
+func TestFoldLeagueTables(t *testing.T) {
+	if store.CurrentDialect() != store.MySQL {
+		t.Skip("Only MySQL databases had per league tables")
+	}
+	db := store.GetDB()
+	res, err := db.Exec("INSERT INTO league (name, commissioner, maxOwner) VALUES ('Old Times', 1, 2)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	league, err := res.LastInsertId()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Exec("DELETE FROM league WHERE ID=?", league)
+
+	stringID := strconv.FormatInt(league, 10)
+	//These are the tables createLeague used to make, from before teams had autodraft.
+	old := []string{
+		"CREATE TABLE teams_" + stringID + ` (ID INT AUTO_INCREMENT NOT NULL UNIQUE PRIMARY KEY, name VARCHAR(128) NOT NULL,
+			manager INT NOT NULL, slot INT NOT NULL DEFAULT 0,
+			FOREIGN KEY (manager) REFERENCES user(ID) ON UPDATE CASCADE ON DELETE CASCADE)`,
+		"CREATE TABLE draft_" + stringID + ` (ID INT NOT NULL UNIQUE PRIMARY KEY, player INT NOT NULL UNIQUE, team INT NOT NULL,
+			FOREIGN KEY (team) REFERENCES teams_` + stringID + `(ID) ON UPDATE CASCADE ON DELETE CASCADE)`,
+		"CREATE TABLE roster_" + stringID + ` (player INT NOT NULL UNIQUE, active BOOL DEFAULT 0, team INT NOT NULL,
+			FOREIGN KEY (team) REFERENCES teams_` + stringID + `(ID) ON UPDATE CASCADE ON DELETE CASCADE,
+			FOREIGN KEY (player) REFERENCES player(ID) ON UPDATE CASCADE ON DELETE CASCADE)`,
+		"CREATE TABLE transactions_" + stringID + ` (ID INT AUTO_INCREMENT NOT NULL UNIQUE PRIMARY KEY, player INT NOT NULL,
+			team INT NOT NULL, source INT NOT NULL, associated INT DEFAULT 0, initiated TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (player) REFERENCES player(ID) ON UPDATE CASCADE ON DELETE CASCADE)`,
+		"CREATE TABLE league_" + stringID + "_invites (user INT NOT NULL)",
+		"INSERT INTO teams_" + stringID + " (name, manager) VALUES ('Larry Legend', 1), ('Barry Bonus', 5)",
+		"INSERT INTO draft_" + stringID + " (ID, player, team) VALUES (0, 1, 2)",
+		"INSERT INTO roster_" + stringID + " (player, team) VALUES (1, 2)",
+		"INSERT INTO league_" + stringID + "_invites (user) VALUES (0), (6)",
+	}
+	for _, s := range old {
+		if _, err = db.Exec(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err = store.FoldLeagueTables(db); err != nil {
+		t.Fatal(err)
+	}
+
+	var teams, team, rostered, invited, left int
+	row := db.QueryRow("SELECT COUNT(*) FROM teams WHERE league=? AND autodraft=0", league)
+	if err = row.Scan(&teams); err != nil || teams != 2 {
+		t.Errorf("want 2 teams got %v (%v)", teams, err)
+	}
+	row = db.QueryRow("SELECT team FROM draft_picks WHERE league=? AND ID=0", league)
+	if err = row.Scan(&team); err != nil || team != 2 {
+		t.Errorf("want pick 0 for team 2 got %v (%v)", team, err)
+	}
+	row = db.QueryRow("SELECT team FROM rosters WHERE league=? AND player=1", league)
+	if err = row.Scan(&rostered); err != nil || rostered != 2 {
+		t.Errorf("want player 1 on team 2 got %v (%v)", rostered, err)
+	}
+	row = db.QueryRow("SELECT COUNT(*) FROM invites WHERE league=?", league)
+	if err = row.Scan(&invited); err != nil || invited != 1 {
+		t.Errorf("want 1 invite got %v (%v)", invited, err)
+	}
+	row = db.QueryRow("SELECT COUNT(*) FROM information_schema.tables WHERE table_schema=DATABASE() AND table_name IN (?,?,?,?,?)",
+		"teams_"+stringID, "draft_"+stringID, "roster_"+stringID, "transactions_"+stringID, "league_"+stringID+"_invites")
+	if err = row.Scan(&left); err != nil || left != 0 {
+		t.Errorf("want old tables dropped, %v left (%v)", left, err)
+	}
+}
+
+//The splitter has to leave semicolons alone inside strings and comments, and follow DELIMITER changes.
+func TestSplitSQL(t *testing.T) {
+	script := `-- a comment; with a semicolon
+INSERT INTO t VALUES ('a;b', "it\"s;", 'don''t;'); # another; one
+/* block; comment */ SELECT 1;
+DELIMITER //
+CREATE TRIGGER x BEFORE INSERT ON t FOR EACH ROW BEGIN SET @a = 1; END//
+DELIMITER ;
+/*!40101 SET NAMES utf8 */;
+SELECT 2`
+	want := []string{
+		`INSERT INTO t VALUES ('a;b', "it\"s;", 'don''t;')`,
+		`SELECT 1`,
+		`CREATE TRIGGER x BEFORE INSERT ON t FOR EACH ROW BEGIN SET @a = 1; END`,
+		`/*!40101 SET NAMES utf8 */`,
+		`SELECT 2`,
+	}
+	got := store.SplitSQL(script)
+	if len(got) != len(want) {
+		t.Fatalf("Got %d statements %q want %d", len(got), got, len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Statement %d got %q want %q", i, got[i], want[i])
+		}
+	}
+}
+
+//TestMain migrated the test database, so every migration should be applied and clean, and migrating again
+//shouldn't do anything.
+func TestMigrations(t *testing.T) {
+	db := store.GetDB()
+	if err := store.Migrate(db, 0); err != nil {
+		t.Fatal(err)
+	}
+	states, err := store.MigrationStatus(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(states) == 0 {
+		t.Fatal("No migrations")
+	}
+	for _, s := range states {
+		if s.Applied.IsZero() || s.Dirty {
+			t.Errorf("Got %v applied %v dirty %v", s.Migration, s.Applied, s.Dirty)
+		}
+	}
+}
+
+//The in-memory repositories should behave like the SQL ones, from signing up through to starting a draft.
+func TestCanRoster(t *testing.T) {
+	settings := scanners.PositionalSettings{QB: 1, RB: 1, WR: 1, TE: 1, Flex: 1, Bench: 1, Def: 1, K: 1}
+	superflex := settings
+	superflex.Superflex = 1
+	tests := []struct {
+		settings  scanners.PositionalSettings
+		positions []string
+		want      bool
+	}{
+		{settings, nil, true},
+		{settings, []string{"QB", "QB"}, true},
+		{settings, []string{"QB", "QB", "QB"}, false},
+		{superflex, []string{"QB", "QB", "QB"}, true},
+		{settings, []string{"RB", "RB", "RB"}, true},
+		{settings, []string{"RB", "RB", "RB", "RB"}, false},
+		{settings, []string{"RB", "WR", "WR", "TE"}, true},
+		{settings, []string{"K", "K"}, true},
+		{settings, []string{"K", "K", "K"}, false},
+		//Kickers can't flex, so the extra one takes the bench from the quarterback.
+		{settings, []string{"K", "K", "QB", "QB"}, false},
+		{settings, []string{"rb", "rb"}, true},
+		{settings, []string{"P"}, true},
+		{settings, []string{"P", "P"}, false},
+	}
+	for _, test := range tests {
+		if got := test.settings.CanRoster(test.positions); got != test.want {
+			t.Errorf("%v with superflex %v: got %v want %v", test.positions, test.settings.Superflex, got, test.want)
+		}
+	}
+}
+
+func TestMemoryRepos(t *testing.T) {
+	m := store.NewMemory()
+	repos := m.Repos()
+	for i, name := range []string{"Adams", "Brady", "Cousins"} {
+		m.AddPlayer(scanners.Player{ID: int64(i + 1), Name: name, Position: "QB", Age: uint8(30 - i)}, 2020+i)
+	}
+
+	larry, err := repos.Users.Create("larry", "larry@mail.com", []byte("hash"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = repos.Users.Create("larry", "other@mail.com", nil); err == nil {
+		t.Error("Created a second larry")
+	}
+	league, err := repos.Leagues.Create("Memory League", larry.ID, 2, 2022, "Larry's team")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//An invite by email waits until someone signs up with it.
+	invitee, err := repos.Invites.Invite(league, "barry@mail.com")
+	if err != nil || invitee.ID != 0 {
+		t.Fatalf("Got %v %v want an unregistered invite", invitee, err)
+	}
+	barry, err := repos.Users.Create("barry", "barry@mail.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	invited, err := repos.Leagues.Invited(barry.ID)
+	if err != nil || len(invited) != 1 || invited[0].Commissioner != "larry" {
+		t.Fatalf("Got %v %v want barry invited to larry's league", invited, err)
+	}
+	if err = repos.Teams.Join(league, "Barry's team", barry.ID); err != nil {
+		t.Fatal(err)
+	}
+	if invites, _ := repos.Invites.List(league); len(invites) != 0 {
+		t.Errorf("Got invites %v after joining", invites)
+	}
+
+	marry, _ := repos.Users.Create("marry", "marry@mail.com", nil)
+	if err = repos.Teams.Join(league, "Marry's team", marry.ID); err != store.ErrLeagueFull {
+		t.Errorf("Got %v want %v", err, store.ErrLeagueFull)
+	}
+	if err = repos.Leagues.Update(store.LeagueSettings{ID: league, Name: "Smaller", MaxOwner: 1}); err != store.ErrTooManyTeams {
+		t.Errorf("Got %v want %v", err, store.ErrTooManyTeams)
+	}
+
+	//Barry keeps Cousins with his first round pick, which is the second pick when he picks second.
+	m.AddKeeper(league, store.Keeper{Team: 2, Player: 3, Round: 1})
+	keepers, _ := repos.Drafts.Keepers(league)
+	err = repos.Drafts.Start(league, []store.TeamSlot{{Team: 1, Slot: 1}, {Team: 2, Slot: 2}},
+		[]store.Pick{{Slot: 2, Player: keepers[0].Player, Team: keepers[0].Team}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l, _ := repos.Leagues.Get(league); l.State != "DRAFT" {
+		t.Errorf("Got state %v want DRAFT", l.State)
+	}
+
+	//Cousins is gone, so sorting by age leaves Adams and Brady, youngest first.
+	pool, err := repos.Players.Pool(store.PoolQuery{League: league, Sort: "age", Limit: 1})
+	if err != nil || len(pool) != 1 || pool[0].Name != "Brady" {
+		t.Fatalf("Got %v %v want Brady", pool, err)
+	}
+	pool, _ = repos.Players.Pool(store.PoolQuery{League: league, Sort: "age", After: &store.PoolCursor{ID: 2, Value: float64(29)}})
+	if len(pool) != 1 || pool[0].Name != "Adams" {
+		t.Errorf("Got %v want Adams after the cursor", pool)
+	}
+	pool, _ = repos.Players.Pool(store.PoolQuery{Search: "cou", Rookies: true, League: league})
+	if len(pool) != 0 {
+		t.Errorf("Got %v want nobody", pool)
+	}
+
+	//Resetting the draft throws out the keeper's pick and the order, and goes back to PREDRAFT.
+	if err = repos.Drafts.Reset(league); err != nil {
+		t.Fatal(err)
+	}
+	picks, _ := repos.Drafts.Picks(league)
+	teams, _ := repos.Teams.List(league)
+	if l, _ := repos.Leagues.Get(league); l.State != "PREDRAFT" || len(picks) != 0 || teams[1].Slot != 0 {
+		t.Errorf("Got state %v picks %v slot %v after reset", l.State, picks, teams[1].Slot)
+	}
+
+	//Handing the league to Barry only takes once he accepts, and gets logged along the way.
+	if err = repos.Roles.Grant(league, larry.ID, barry.ID, "COCOMMISSIONER", []string{"DRAFT"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = repos.Roles.Offer(league, larry.ID, barry.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err = repos.Roles.Accept(league, marry.ID); err != store.ErrNotFound {
+		t.Errorf("Got %v want ErrNotFound accepting someone else's offer", err)
+	}
+	if err = repos.Roles.Accept(league, barry.ID); err != nil {
+		t.Fatal(err)
+	}
+	member, _ := repos.Roles.Member(league, barry.ID)
+	history, _ := repos.Roles.History(league)
+	if !member.Commissioner || member.Role != "" || len(history) != 3 || history[0].Action != store.ActionAccept || history[0].User.ID != larry.ID {
+		t.Errorf("Got %+v and history %+v after the transfer", member, history)
+	}
+}
+
+//Stats import by pfbr_name or ID, and importing a row again corrects it rather than adding another.
+func TestImportStats(t *testing.T) {
+	db := store.GetDB()
+	var henry int64
+	if err := db.QueryRow("SELECT ID FROM player WHERE pfbr_name='HenrDe00'").Scan(&henry); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Exec("DELETE FROM player_stats WHERE player=?", henry)
+
+	csv := filepath.Join(t.TempDir(), "stats.csv")
+	write := func(content string) {
+		if err := os.WriteFile(csv, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("pfbr_name,season,week,rush_yards,rush_touchdowns\nHenrDe00,2020,1,116,0\nHenrDe00,2020,2,84,")
+	if n, err := playerimport.ImportStats(db, csv); err != nil || n != 2 {
+		t.Fatalf("Got %v %v want 2 rows", n, err)
+	}
+	write("player,season,week,rush_yards\n" + strconv.FormatInt(henry, 10) + ",2020,2,94")
+	if n, err := playerimport.ImportStats(db, csv); err != nil || n != 1 {
+		t.Fatalf("Got %v %v want 1 row", n, err)
+	}
+
+	var rows, yards int
+	db.QueryRow("SELECT COUNT(*) FROM player_stats WHERE player=?", henry).Scan(&rows)
+	db.QueryRow("SELECT rush_yards FROM player_stats WHERE player=? AND season=2020 AND week=2", henry).Scan(&yards)
+	if rows != 2 || yards != 94 {
+		t.Errorf("Got %v rows and %v yards in week 2, want 2 and 94", rows, yards)
+	}
+
+	write("pfbr_name,season,salary\nHenrDe00,2020,12")
+	if _, err := playerimport.ImportStats(db, csv); err == nil {
+		t.Error("Imported an unknown column")
+	}
+}